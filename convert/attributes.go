@@ -0,0 +1,218 @@
+package convert
+
+import (
+	"fmt"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Typical height of a single storey in metres
+const levelHeight = 3.0
+
+const metresPerFoot = 0.3048
+
+var buildingClasses = map[string]layers.BuildingClass{
+	"residential":        layers.ResidentialBuilding,
+	"house":              layers.ResidentialBuilding,
+	"apartments":         layers.ResidentialBuilding,
+	"detached":           layers.ResidentialBuilding,
+	"semidetached_house": layers.ResidentialBuilding,
+	"terrace":            layers.ResidentialBuilding,
+	"dormitory":          layers.ResidentialBuilding,
+	"bungalow":           layers.ResidentialBuilding,
+	"cabin":              layers.ResidentialBuilding,
+	"farm":               layers.ResidentialBuilding,
+	"houseboat":          layers.ResidentialBuilding,
+	"static_caravan":     layers.ResidentialBuilding,
+	"commercial":         layers.CommercialBuilding,
+	"retail":             layers.CommercialBuilding,
+	"office":             layers.CommercialBuilding,
+	"supermarket":        layers.CommercialBuilding,
+	"kiosk":              layers.CommercialBuilding,
+	"hotel":              layers.CommercialBuilding,
+	"industrial":         layers.IndustrialBuilding,
+	"warehouse":          layers.IndustrialBuilding,
+	"factory":            layers.IndustrialBuilding,
+	"manufacture":        layers.IndustrialBuilding,
+	"service":            layers.IndustrialBuilding,
+	"storage_tank":       layers.IndustrialBuilding,
+	"religious":          layers.ReligiousBuilding,
+	"church":             layers.ReligiousBuilding,
+	"cathedral":          layers.ReligiousBuilding,
+	"chapel":             layers.ReligiousBuilding,
+	"mosque":             layers.ReligiousBuilding,
+	"synagogue":          layers.ReligiousBuilding,
+	"temple":             layers.ReligiousBuilding,
+	"shrine":             layers.ReligiousBuilding,
+	"monastery":          layers.ReligiousBuilding,
+	"civic":              layers.CivicBuilding,
+	"public":             layers.CivicBuilding,
+	"government":         layers.CivicBuilding,
+	"school":             layers.CivicBuilding,
+	"university":         layers.CivicBuilding,
+	"college":            layers.CivicBuilding,
+	"kindergarten":       layers.CivicBuilding,
+	"hospital":           layers.CivicBuilding,
+	"fire_station":       layers.CivicBuilding,
+	"train_station":      layers.CivicBuilding,
+	"transportation":     layers.CivicBuilding,
+	"stadium":            layers.CivicBuilding,
+	"sports_hall":        layers.CivicBuilding,
+	"barn":               layers.AgriculturalBuilding,
+	"farm_auxiliary":     layers.AgriculturalBuilding,
+	"greenhouse":         layers.AgriculturalBuilding,
+	"stable":             layers.AgriculturalBuilding,
+	"cowshed":            layers.AgriculturalBuilding,
+	"sty":                layers.AgriculturalBuilding,
+	"garage":             layers.MinorBuilding,
+	"garages":            layers.MinorBuilding,
+	"shed":               layers.MinorBuilding,
+	"carport":            layers.MinorBuilding,
+	"hut":                layers.MinorBuilding,
+	"roof":               layers.MinorBuilding,
+	"toilets":            layers.MinorBuilding,
+}
+
+// Height in metres used when a building has neither a height nor a levels tag
+var defaultHeights = map[layers.BuildingClass]float64{
+	layers.UnknownBuilding:      6.0,
+	layers.ResidentialBuilding:  6.0,
+	layers.CommercialBuilding:   9.0,
+	layers.IndustrialBuilding:   8.0,
+	layers.ReligiousBuilding:    15.0,
+	layers.CivicBuilding:        10.0,
+	layers.AgriculturalBuilding: 5.0,
+	layers.MinorBuilding:        3.0,
+}
+
+var roofShapes = map[string]layers.RoofShape{
+	"flat":        layers.FlatRoof,
+	"gabled":      layers.GabledRoof,
+	"hipped":      layers.HippedRoof,
+	"half-hipped": layers.HalfHippedRoof,
+	"pyramidal":   layers.PyramidalRoof,
+	"skillion":    layers.SkillionRoof,
+	"gambrel":     layers.GambrelRoof,
+	"mansard":     layers.MansardRoof,
+	"dome":        layers.DomeRoof,
+	"onion":       layers.OnionRoof,
+	"round":       layers.RoundRoof,
+}
+
+// Reads the building related tags of an element, falling back to defaults for tags that can't be understood
+func parseBuildingAttributes(elementType ElementType, osmId uint64, tags *overpass.Tags) (a *layers.BuildingAttributes, d []*Diagnostic) {
+	a = &layers.BuildingAttributes{RoofShape: layers.FlatRoof}
+	if tags == nil {
+		tags = new(overpass.Tags)
+	}
+
+	invalidTag := func(key, value string) {
+		d = append(d, droppedDiagnostic(elementType, osmId, InvalidTagCode, fmt.Sprintf("ignored %s=%q: not a valid value", key, value)))
+	}
+
+	a.Type = tags.Building
+	a.Class = buildingClasses[tags.Building]
+
+	height, heightOk := parseLength(tags.Height)
+	if tags.Height != "" && !heightOk {
+		invalidTag("height", tags.Height)
+	}
+
+	if tags.MinHeight != "" {
+		minHeight, ok := parseLength(tags.MinHeight)
+		if ok {
+			a.MinHeight = minHeight
+		} else {
+			invalidTag("min_height", tags.MinHeight)
+		}
+	}
+
+	levels, levelsOk := parseLevels(tags.Levels)
+	if tags.Levels != "" && !levelsOk {
+		invalidTag("building:levels", tags.Levels)
+	}
+
+	switch {
+	case heightOk:
+		a.Height = height
+	case levelsOk:
+		a.Height = a.MinHeight + float64(levels)*levelHeight
+	default:
+		a.Height = a.MinHeight + defaultHeights[a.Class]
+		a.HeightEstimated = true
+	}
+
+	if levelsOk {
+		a.Levels = levels
+	} else {
+		a.Levels = int(math.Max(1, math.Round((a.Height-a.MinHeight)/levelHeight)))
+	}
+
+	if tags.RoofShape != "" {
+		shape, ok := roofShapes[tags.RoofShape]
+		if !ok {
+			shape = layers.OtherRoof
+		}
+
+		a.RoofShape = shape
+	}
+
+	return a, d
+}
+
+// Parses an OSM length such as 12, 12 m, 12ft or 12'6" into metres
+func parseLength(value string) (metres float64, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(value, "ft"):
+		value, scale = strings.TrimSpace(strings.TrimSuffix(value, "ft")), metresPerFoot
+	case strings.HasSuffix(value, "m"):
+		value = strings.TrimSpace(strings.TrimSuffix(value, "m"))
+	case strings.Contains(value, "'"):
+		parts := strings.SplitN(strings.TrimSuffix(value, "\""), "'", 2)
+		feet, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return 0, false
+		}
+
+		inches := 0.0
+		if parts[1] != "" {
+			inches, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return 0, false
+			}
+		}
+
+		value, scale = strconv.FormatFloat(feet+inches/12, 'f', -1, 64), metresPerFoot
+	}
+
+	length, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(length) || math.IsInf(length, 0) || length < 0 {
+		return 0, false
+	}
+
+	return length * scale, true
+}
+
+func parseLevels(value string) (levels int, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	// Half levels for attics are common so round them off
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || f < 0 || f > 1000 {
+		return 0, false
+	}
+
+	return int(math.Round(f)), true
+}
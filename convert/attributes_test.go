@@ -0,0 +1,81 @@
+package convert
+
+import (
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseBuildingAttributes(t *testing.T) {
+	test := func(tags *overpass.Tags, expected *layers.BuildingAttributes, expectedCodes []Code) {
+		a, d := parseBuildingAttributes(WayElement, 1, tags)
+		require.Equal(t, expected, a)
+
+		codes := make([]Code, 0)
+		for _, diagnostic := range d {
+			require.Equal(t, uint64(1), diagnostic.OsmId)
+			codes = append(codes, diagnostic.Code)
+		}
+		require.Equal(t, expectedCodes, codes)
+	}
+
+	test(nil, &layers.BuildingAttributes{Height: 6.0, Levels: 2, RoofShape: layers.FlatRoof, HeightEstimated: true}, []Code{})
+
+	test(&overpass.Tags{Building: "shed"}, &layers.BuildingAttributes{
+		Type: "shed", Class: layers.MinorBuilding, Height: 3.0, Levels: 1, RoofShape: layers.FlatRoof, HeightEstimated: true,
+	}, []Code{})
+
+	test(&overpass.Tags{Building: "apartments", Height: "95.5 m", Levels: "30", RoofShape: "hipped"}, &layers.BuildingAttributes{
+		Type: "apartments", Class: layers.ResidentialBuilding, Height: 95.5, Levels: 30, RoofShape: layers.HippedRoof,
+	}, []Code{})
+
+	test(&overpass.Tags{Building: "commercial", Levels: "3", MinHeight: "4"}, &layers.BuildingAttributes{
+		Type: "commercial", Class: layers.CommercialBuilding, Height: 13.0, MinHeight: 4.0, Levels: 3, RoofShape: layers.FlatRoof,
+	}, []Code{})
+
+	test(&overpass.Tags{Building: "industrial", Height: "30", MinHeight: "3"}, &layers.BuildingAttributes{
+		Type: "industrial", Class: layers.IndustrialBuilding, Height: 30.0, MinHeight: 3.0, Levels: 9, RoofShape: layers.FlatRoof,
+	}, []Code{})
+
+	test(&overpass.Tags{Building: "yes", Height: "-4", Levels: "many", MinHeight: "low", RoofShape: "dome"}, &layers.BuildingAttributes{
+		Type: "yes", Height: 6.0, Levels: 2, RoofShape: layers.DomeRoof, HeightEstimated: true,
+	}, []Code{InvalidTagCode, InvalidTagCode, InvalidTagCode})
+}
+
+func TestParseLength(t *testing.T) {
+	test := func(value string, expected float64, expectedOk bool) {
+		metres, ok := parseLength(value)
+		require.Equal(t, expectedOk, ok, value)
+		require.InDelta(t, expected, metres, 0.000001, value)
+	}
+
+	test("", 0, false)
+	test("12", 12, true)
+	test(" 12.5 ", 12.5, true)
+	test("12m", 12, true)
+	test("12 m", 12, true)
+	test("10ft", 3.048, true)
+	test("10 ft", 3.048, true)
+	test("10'", 3.048, true)
+	test("10'6\"", 3.2004, true)
+	test("ten", 0, false)
+	test("-3", 0, false)
+	test("NaN", 0, false)
+	test("x'6\"", 0, false)
+	test("10'x\"", 0, false)
+}
+
+func TestParseLevels(t *testing.T) {
+	test := func(value string, expected int, expectedOk bool) {
+		levels, ok := parseLevels(value)
+		require.Equal(t, expectedOk, ok, value)
+		require.Equal(t, expected, levels, value)
+	}
+
+	test("", 0, false)
+	test("3", 3, true)
+	test(" 2.5", 3, true)
+	test("2;3", 0, false)
+	test("-1", 0, false)
+}
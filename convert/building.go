@@ -3,10 +3,61 @@ package convert
 import (
 	"errors"
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
 )
 
-func convertBuildings(metadata *world.Metadata, buildingElements []*overpass.Way) (b []*world.Building, err error) {
+type buildingOutput struct {
+	buildings   []*world.Building
+	holes       map[world.Id][][]*world.Node
+	attributes  map[world.Id]*layers.BuildingAttributes
+	diagnostics []*Diagnostic
+	// The nodes of every building in the output
+	nodes *nodePool
+}
+
+func newBuildingOutput(capacity int) *buildingOutput {
+	o := new(buildingOutput)
+	o.buildings = make([]*world.Building, 0, capacity)
+	o.holes = make(map[world.Id][][]*world.Node)
+	o.attributes = make(map[world.Id]*layers.BuildingAttributes, capacity)
+	o.diagnostics = make([]*Diagnostic, 0)
+	o.nodes = newNodePool(ids.BuildingNodeNamespace)
+	return o
+}
+
+// Adds the buildings from other that aren't already in this output. Their nodes are swapped for the ones this output
+// already has so that buildings converted separately still share nodes.
+func (o *buildingOutput) merge(other *buildingOutput) {
+	o.diagnostics = append(o.diagnostics, other.diagnostics...)
+
+	for _, b := range other.buildings {
+		id := b.Id()
+		if _, ok := o.attributes[id]; ok {
+			ref := ids.Lookup(id)
+			o.diagnostics = append(o.diagnostics, rejectedDiagnostic(ref.ElementType, ref.OsmId, DuplicateIdCode, "building",
+				"way appears more than once"))
+			continue
+		}
+
+		if points, swapped := o.nodes.share(b.Points()); swapped {
+			b = world.NewBuilding(id, points)
+		}
+
+		o.buildings = append(o.buildings, b)
+		o.attributes[id] = other.attributes[id]
+		if holes, ok := other.holes[id]; ok {
+			for i, hole := range holes {
+				holes[i], _ = o.nodes.share(hole)
+			}
+
+			o.holes[id] = holes
+		}
+	}
+}
+
+func convertBuildings(metadata *world.Metadata, buildingElements []*overpass.Way) (o *buildingOutput, err error) {
 	if buildingElements == nil {
 		return nil, errors.New("building elements be nil")
 	}
@@ -16,27 +67,39 @@ func convertBuildings(metadata *world.Metadata, buildingElements []*overpass.Way
 		return nil, err
 	}
 
-	buildings := make([]*world.Building, 0, len(buildingElements))
+	output := newBuildingOutput(len(buildingElements))
 
 	for _, e := range buildingElements {
-		id, err := world.NewId(e.Id, world.BuildingType)
+		id, err := ids.New(ids.BuildingWayNamespace, e.Id, world.BuildingType)
 		if err != nil {
-			return nil, err
+			output.diagnostics = append(output.diagnostics, rejectedDiagnostic(WayElement, e.Id, InvalidIdCode, "building", err.Error()))
+			continue
+		} else if _, ok := output.attributes[id]; ok {
+			output.diagnostics = append(output.diagnostics, rejectedDiagnostic(WayElement, e.Id, DuplicateIdCode, "building",
+				"way appears more than once"))
+			continue
 		}
 
-		points := make([]*world.Node, 0, len(e.Nodes)-1)
-		for i, nodeId := range e.Nodes {
-			id, err := world.NewId(nodeId, world.NodeType)
-			if err != nil {
-				return nil, err
-			}
+		points, err := convertNodes(toGameCoords, output.nodes, e.Nodes, e.Geometry)
+		if err != nil {
+			output.diagnostics = append(output.diagnostics, rejectedDiagnostic(WayElement, e.Id, nodesCode(err), "building", err.Error()))
+			continue
+		}
 
-			x, y := toGameCoords(e.Geometry[i].Lat, e.Geometry[i].Lon)
-			points = append(points, world.NewNode(id, x, y))
+		ring, removed, err := normalizeRing(points)
+		if err != nil {
+			output.diagnostics = append(output.diagnostics, rejectedDiagnostic(WayElement, e.Id, polygonCode(err), "building", err.Error()))
+			continue
+		} else if removed > 0 {
+			output.diagnostics = append(output.diagnostics, repairedDiagnostic(WayElement, e.Id, removed))
 		}
 
-		buildings = append(buildings, world.NewBuilding(id, points))
+		attributes, diagnostics := parseBuildingAttributes(WayElement, e.Id, e.Tags)
+		output.diagnostics = append(output.diagnostics, diagnostics...)
+		output.attributes[id] = attributes
+
+		output.buildings = append(output.buildings, world.NewBuilding(id, ring))
 	}
 
-	return buildings, nil
+	return output, nil
 }
@@ -2,6 +2,8 @@ package convert
 
 import (
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -38,30 +40,72 @@ func TestConvertBuildings(t *testing.T) {
 				{0.6, 1.0},
 				{1.0, 1.0},
 			},
+			Tags: &overpass.Tags{Building: "church", Height: "tall", Levels: "2"},
+		},
+		{
+			Id:     2,
+			Bounds: &overpass.Bounds{MinLat: 0.0, MinLon: 0.6, MaxLat: 0.4, MaxLon: 1.0},
+			Nodes:  []uint64{7, 8, 9, 10, 7},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.0, Lon: 0.6},
+				{Lat: 0.4, Lon: 1.0},
+				{Lat: 0.0, Lon: 1.0},
+				{Lat: 0.4, Lon: 0.6},
+				{Lat: 0.0, Lon: 0.6},
+			},
+			Tags: &overpass.Tags{Building: "yes"},
+		},
+		{
+			Id:     3,
+			Bounds: &overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 0.0, MaxLon: 0.2},
+			Nodes:  []uint64{11, 12, 13},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.0, Lon: 0.0},
+				{Lat: 0.0, Lon: 0.1},
+				{Lat: 0.0, Lon: 0.2},
+			},
 			Tags: &overpass.Tags{Building: "yes"},
 		},
 	}
 
-	makeId := func(baseId uint64, idType world.Type) world.Id {
-		id, err := world.NewId(baseId, idType)
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
 		require.NoError(t, err)
 		return id
 	}
 
-	node0 := world.NewNode(makeId(0, world.NodeType), 0, 0)
-	node1 := world.NewNode(makeId(1, world.NodeType), 0, 50)
-	node2 := world.NewNode(makeId(2, world.NodeType), 50, 50)
-	node3 := world.NewNode(makeId(3, world.NodeType), 50, 0)
-	node4 := world.NewNode(makeId(4, world.NodeType), 60, 60)
-	node5 := world.NewNode(makeId(5, world.NodeType), 100, 60)
-	node6 := world.NewNode(makeId(6, world.NodeType), 100, 100)
+	node0 := world.NewNode(makeId(ids.BuildingNodeNamespace, 0, world.NodeType), 0, 0)
+	node1 := world.NewNode(makeId(ids.BuildingNodeNamespace, 1, world.NodeType), 0, 50)
+	node2 := world.NewNode(makeId(ids.BuildingNodeNamespace, 2, world.NodeType), 50, 50)
+	node3 := world.NewNode(makeId(ids.BuildingNodeNamespace, 3, world.NodeType), 50, 0)
+	node4 := world.NewNode(makeId(ids.BuildingNodeNamespace, 4, world.NodeType), 60, 60)
+	node5 := world.NewNode(makeId(ids.BuildingNodeNamespace, 5, world.NodeType), 100, 60)
+	node6 := world.NewNode(makeId(ids.BuildingNodeNamespace, 6, world.NodeType), 100, 100)
 
 	expectedBuildings := []*world.Building{
-		world.NewBuilding(makeId(0, world.BuildingType), []*world.Node{node0, node1, node2, node3}),
-		world.NewBuilding(makeId(1, world.BuildingType), []*world.Node{node4, node5, node6}),
+		world.NewBuilding(makeId(ids.BuildingWayNamespace, 0, world.BuildingType), []*world.Node{node0, node3, node2, node1}),
+		world.NewBuilding(makeId(ids.BuildingWayNamespace, 1, world.BuildingType), []*world.Node{node4, node5, node6}),
+	}
+
+	expectedAttributes := map[world.Id]*layers.BuildingAttributes{
+		makeId(ids.BuildingWayNamespace, 0, world.BuildingType): {
+			Type: "yes", Class: layers.UnknownBuilding, Height: 6.0, Levels: 2, RoofShape: layers.FlatRoof, HeightEstimated: true,
+		},
+		makeId(ids.BuildingWayNamespace, 1, world.BuildingType): {
+			Type: "church", Class: layers.ReligiousBuilding, Height: 6.0, Levels: 2, RoofShape: layers.FlatRoof,
+		},
+	}
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 1, ElementType: WayElement, Code: InvalidTagCode, Severity: Warning, Message: `ignored height="tall": not a valid value`},
+		{OsmId: 2, ElementType: WayElement, Code: SelfIntersectingCode, Severity: Error, Message: "building rejected: polygon intersects itself"},
+		{OsmId: 3, ElementType: WayElement, Code: TooFewPointsCode, Severity: Error, Message: "building rejected: polygon has fewer than 3 distinct non-collinear points"},
 	}
 
-	buildings, err := convertBuildings(metadata, buildingElements)
+	output, err := convertBuildings(metadata, buildingElements)
 	require.NoError(t, err)
-	require.ElementsMatch(t, buildings, expectedBuildings)
+	require.ElementsMatch(t, output.buildings, expectedBuildings)
+	require.Equal(t, expectedAttributes, output.attributes)
+	require.Empty(t, output.holes)
+	require.Equal(t, expectedDiagnostics, output.diagnostics)
 }
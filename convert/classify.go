@@ -0,0 +1,119 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+)
+
+var (
+	errUnclassified    = errors.New("element does not have type")
+	errConflictingTags = errors.New("element is both building and highway type")
+)
+
+// What a way is converted into. Any other value is a custom type that is converted by its Handler.
+type WayType int
+
+const (
+	BuildingType WayType = iota
+	HighwayType
+	AreaType
+	LineType
+	// The way is left out of the world without a diagnostic
+	IgnoredType
+)
+
+func (t WayType) String() string {
+	switch t {
+	case BuildingType:
+		return "building"
+	case HighwayType:
+		return "highway"
+	case AreaType:
+		return "area"
+	case LineType:
+		return "line"
+	case IgnoredType:
+		return "ignored"
+	default:
+		return fmt.Sprintf("custom %d", int(t))
+	}
+}
+
+func (t WayType) builtIn() bool {
+	return BuildingType <= t && t <= IgnoredType
+}
+
+type Classification struct {
+	Type      WayType
+	AreaClass layers.AreaClass
+	LineClass layers.LineClass
+}
+
+// Decides what a way is converted into. A nil classification with no error passes the way on to the next classifier.
+type Classifier interface {
+	Classify(w *overpass.Way) (c *Classification, err error)
+}
+
+type ClassifierFunc func(w *overpass.Way) (c *Classification, err error)
+
+func (f ClassifierFunc) Classify(w *overpass.Way) (c *Classification, err error) {
+	return f(w)
+}
+
+// Converts the ways that a classifier gave a custom type, once the built-in layers are in the world
+type Handler interface {
+	Handle(w *layers.World, ways []*overpass.Way) (d []*Diagnostic, err error)
+}
+
+type HandlerFunc func(w *layers.World, ways []*overpass.Way) (d []*Diagnostic, err error)
+
+func (f HandlerFunc) Handle(w *layers.World, ways []*overpass.Way) (d []*Diagnostic, err error) {
+	return f(w, ways)
+}
+
+// Classifies ways by their building, highway, area and line tags. It runs after any other classifiers.
+var DefaultClassifier Classifier = ClassifierFunc(classify)
+
+type classifiedWay struct {
+	*overpass.Way
+	*Classification
+}
+
+func classify(e *overpass.Way) (c *Classification, err error) {
+	if e.Tags == nil {
+		return nil, errUnclassified
+	}
+
+	if e.Tags.Building != "" {
+		if e.Tags.Highway != "" {
+			return nil, errConflictingTags
+		}
+
+		return &Classification{Type: BuildingType}, nil
+	} else if e.Tags.Highway != "" {
+		return &Classification{Type: HighwayType}, nil
+	}
+
+	// Closed ways can be areas but also rings of railway or canals so fall back to checking for a line
+	if area, ok := areaClass(e.Tags); ok && isClosed(e) {
+		return &Classification{Type: AreaType, AreaClass: area}, nil
+	} else if line, ok := lineClass(e.Tags); ok {
+		return &Classification{Type: LineType, LineClass: line}, nil
+	}
+
+	return nil, errUnclassified
+}
+
+// Runs each classifier in turn until one of them recognises the way
+func classifyWith(classifiers []Classifier, e *overpass.Way) (c *Classification, err error) {
+	for _, classifier := range classifiers {
+		c, err := classifier.Classify(e)
+		if err != nil || c != nil {
+			return c, err
+		}
+	}
+
+	return nil, errUnclassified
+}
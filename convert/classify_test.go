@@ -0,0 +1,100 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	test := func(tags overpass.Tags, expected WayType, shouldError bool) {
+		w := overpass.Way{
+			Id:       0,
+			Bounds:   nil,
+			Nodes:    nil,
+			Geometry: nil,
+			Tags:     &tags,
+		}
+
+		c, err := classify(&w)
+		if shouldError {
+			require.Error(t, err)
+			require.Nil(t, c)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, expected, c.Type)
+		}
+	}
+
+	test(overpass.Tags{Building: "", Highway: ""}, 0, true)
+	test(overpass.Tags{Building: "yes", Highway: ""}, BuildingType, false)
+	test(overpass.Tags{Building: "", Highway: "primary"}, HighwayType, false)
+	test(overpass.Tags{Building: "yes", Highway: "primary"}, 0, true)
+	test(overpass.Tags{Building: "yes", Landuse: "residential"}, BuildingType, false)
+	test(overpass.Tags{Highway: "primary", Railway: "tram"}, HighwayType, false)
+	test(overpass.Tags{Waterway: "canal"}, LineType, false)
+	test(overpass.Tags{Natural: "tree_row"}, 0, true)
+
+	_, err := classify(&overpass.Way{})
+	require.Error(t, err, "ways without tags can't be classified")
+
+	// Areas have to be closed
+	closed := overpass.Way{Nodes: []uint64{0, 1, 2, 0}, Tags: &overpass.Tags{Leisure: "park"}}
+	c, err := classify(&closed)
+	require.NoError(t, err)
+	require.Equal(t, &Classification{Type: AreaType, AreaClass: layers.ParkArea}, c)
+
+	open := overpass.Way{Nodes: []uint64{0, 1, 2}, Tags: &overpass.Tags{Leisure: "park"}}
+	_, err = classify(&open)
+	require.Error(t, err)
+
+	closedRailway := overpass.Way{Nodes: []uint64{0, 1, 2, 0}, Tags: &overpass.Tags{Railway: "rail"}}
+	c, err = classify(&closedRailway)
+	require.NoError(t, err)
+	require.Equal(t, &Classification{Type: LineType, LineClass: layers.RailLine}, c)
+}
+
+func TestClassifyWith(t *testing.T) {
+	parking := ClassifierFunc(func(w *overpass.Way) (*Classification, error) {
+		if w.Tags != nil && w.Tags.Amenity == "parking" {
+			return &Classification{Type: AreaType, AreaClass: layers.CommercialArea}, nil
+		}
+
+		return nil, nil
+	})
+
+	roofs := ClassifierFunc(func(w *overpass.Way) (*Classification, error) {
+		if w.Tags != nil && w.Tags.Building == "roof" {
+			return &Classification{Type: IgnoredType}, nil
+		}
+
+		return nil, nil
+	})
+
+	failing := ClassifierFunc(func(w *overpass.Way) (*Classification, error) {
+		return nil, errors.New("failed")
+	})
+
+	classifiers := []Classifier{parking, roofs, DefaultClassifier}
+
+	c, err := classifyWith(classifiers, &overpass.Way{Tags: &overpass.Tags{Amenity: "parking"}})
+	require.NoError(t, err)
+	require.Equal(t, AreaType, c.Type)
+	require.True(t, c.AreaClass.Buildable())
+
+	c, err = classifyWith(classifiers, &overpass.Way{Tags: &overpass.Tags{Building: "roof"}})
+	require.NoError(t, err)
+	require.Equal(t, IgnoredType, c.Type)
+
+	c, err = classifyWith(classifiers, &overpass.Way{Tags: &overpass.Tags{Building: "house"}})
+	require.NoError(t, err)
+	require.Equal(t, BuildingType, c.Type, "unrecognised ways should fall through to the default classifier")
+
+	_, err = classifyWith([]Classifier{failing, DefaultClassifier}, &overpass.Way{Tags: &overpass.Tags{Building: "house"}})
+	require.Error(t, err, "errors should stop classification")
+
+	_, err = classifyWith([]Classifier{parking}, &overpass.Way{Tags: &overpass.Tags{Building: "house"}})
+	require.Equal(t, errUnclassified, err)
+}
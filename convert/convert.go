@@ -3,64 +3,253 @@ package convert
 import (
 	"errors"
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
+	"runtime"
 )
 
-type elementType int
+var errGeometryMismatch = errors.New("number of nodes does not match the number of points in the geometry")
 
-const (
-	BuildingType elementType = iota
-	HighwayType
-)
+// Converts the result of an overpass query into a world. Elements that can't be converted are left out and described
+// in the report, unless the mode is strict.
+func Convert(meta *world.Metadata, result *overpass.Result, opts ...Option) (w *layers.World, report *Report, err error) {
+	if result == nil {
+		return nil, nil, errors.New("result cannot be nil")
+	}
+
+	options := &Options{Workers: runtime.GOMAXPROCS(0)}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if err := options.checkHandlers(); err != nil {
+		return nil, nil, err
+	}
+
+	report = NewReport()
+	check := func(diagnostics []*Diagnostic) error {
+		report.Add(diagnostics...)
+		return options.check(diagnostics)
+	}
+
+	buildingRelations := make([]*overpass.Relation, 0)
+	areaRelations := make([]*overpass.Relation, 0)
+	members := make(map[uint64]bool)
+	// Outer ways of building relations, which are converted along with the relation
+	outlines := make(map[uint64]bool)
+	for _, r := range result.Relations {
+		if r == nil {
+			continue
+		} else if isBuildingMultipolygon(r) {
+			buildingRelations = append(buildingRelations, r)
+		} else if isAreaMultipolygon(r) {
+			areaRelations = append(areaRelations, r)
+		} else {
+			continue
+		}
+
+		for _, m := range r.Members {
+			if m != nil && m.Type == "way" {
+				members[m.Ref] = true
+				if m.Role != "inner" && isBuildingMultipolygon(r) {
+					outlines[m.Ref] = true
+				}
+			}
+		}
+	}
+
+	elements := separate(result.Elements, members, outlines, append(options.Classifiers, DefaultClassifier), options.Handlers)
+	if err := check(elements.diagnostics); err != nil {
+		return nil, nil, err
+	}
+
+	// Roads don't depend on buildings so they are converted at the same time
+	var roads []*world.Road
+	var roadClasses map[world.Id]layers.RoadClass
+	var roadDiagnostics []*Diagnostic
+	var roadErr error
+	roadsDone := make(chan struct{})
+	go func() {
+		defer close(roadsDone)
+		roads, roadClasses, roadDiagnostics, roadErr = convertRoads(meta, elements.highways)
+	}()
+
+	buildings, err := convertBuildingsConcurrently(meta, elements.buildings, options.Workers)
+	<-roadsDone
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ways := make(map[uint64]*overpass.Way, len(result.Elements))
+	for _, e := range result.Elements {
+		if e != nil {
+			ways[e.Id] = e
+		}
+	}
+
+	multipolygons, err := convertMultipolygons(meta, buildingRelations, ways)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buildings.merge(multipolygons)
+	if err := check(buildings.diagnostics); err != nil {
+		return nil, nil, err
+	}
+
+	if roadErr != nil {
+		return nil, nil, roadErr
+	} else if err := check(roadDiagnostics); err != nil {
+		return nil, nil, err
+	}
 
-func Convert(meta *world.Metadata, result *overpass.Result) (w *world.Container, err error) {
-	buildingElements, highwayElements := separate(result.Elements)
+	// Areas from ways and from relations share their nodes
+	areaNodes := newNodePool(ids.AreaNodeNamespace)
+	areas, areaDiagnostics, err := convertAreas(meta, elements.areas, areaNodes)
+	if err != nil {
+		return nil, nil, err
+	} else if err := check(areaDiagnostics); err != nil {
+		return nil, nil, err
+	}
+
+	relationAreas, relationDiagnostics, err := convertAreaRelations(meta, areaRelations, ways, areaNodes)
+	if err != nil {
+		return nil, nil, err
+	} else if err := check(relationDiagnostics); err != nil {
+		return nil, nil, err
+	}
 
-	buildings, err := convertBuildings(meta, buildingElements)
+	lines, lineDiagnostics, err := convertLines(meta, elements.lines)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	} else if err := check(lineDiagnostics); err != nil {
+		return nil, nil, err
+	}
+
+	nodeElements := result.Nodes
+	if nodeElements == nil {
+		nodeElements = make([]*overpass.Node, 0)
 	}
 
-	roads, err := convertRoads(meta, highwayElements)
+	pois, poiDiagnostics, err := convertPois(meta, nodeElements)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	} else if err := check(poiDiagnostics); err != nil {
+		return nil, nil, err
+	}
+
+	w = layers.NewWorld(world.NewContainer(meta, roads, buildings.buildings))
+	w.Holes = buildings.holes
+	w.Attributes = buildings.attributes
+	w.RoadClasses = roadClasses
+	w.Areas = append(areas, relationAreas...)
+	w.Lines = lines
+	w.Pois = pois
+
+	for _, t := range options.handledTypes() {
+		if len(elements.custom[t]) == 0 {
+			continue
+		}
+
+		handlerDiagnostics, err := options.Handlers[t].Handle(w, elements.custom[t])
+		if err != nil {
+			return nil, nil, err
+		} else if err := check(handlerDiagnostics); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return world.NewContainer(meta, roads, buildings), nil
+	w.LinkPois()
+
+	return w, report, nil
 }
 
-func separate(elements []*overpass.Way) (buildings, highways []*overpass.Way) {
-	buildingElements := make([]*overpass.Way, 0, 10)
-	highwayElements := make([]*overpass.Way, 0, len(elements)) // Most elements will end up being roads
+// The ways of a result sorted by what they will be converted into
+type separated struct {
+	buildings   []*overpass.Way
+	highways    []*overpass.Way
+	areas       []*classifiedWay
+	lines       []*classifiedWay
+	custom      map[WayType][]*overpass.Way // Ways with a custom type that has a handler, keyed by the type
+	diagnostics []*Diagnostic
+}
+
+// Sorts the ways by what they will be converted into. Relation members without a type are skipped silently and the
+// outlines of building relations are left to the relation.
+func separate(elements []*overpass.Way, members, outlines map[uint64]bool, classifiers []Classifier, handlers map[WayType]Handler) *separated {
+	s := &separated{
+		buildings:   make([]*overpass.Way, 0, 10),
+		highways:    make([]*overpass.Way, 0, len(elements)), // Most elements will end up being roads
+		areas:       make([]*classifiedWay, 0),
+		lines:       make([]*classifiedWay, 0),
+		custom:      make(map[WayType][]*overpass.Way),
+		diagnostics: make([]*Diagnostic, 0),
+	}
 
 	for _, e := range elements {
-		t, err := classify(e)
-		if err != nil {
-			// Overpass data might not be perfect so just ignore this error
+		if e == nil {
 			continue
 		}
 
-		switch t {
+		c, err := classifyWith(classifiers, e)
+		if err == errConflictingTags {
+			s.diagnostics = append(s.diagnostics, rejectedDiagnostic(WayElement, e.Id, ConflictingTagsCode, "way", err.Error()))
+			continue
+		} else if err != nil {
+			if !members[e.Id] {
+				s.diagnostics = append(s.diagnostics, rejectedDiagnostic(WayElement, e.Id, UnclassifiedCode, "way", err.Error()))
+			}
+			continue
+		} else if c.Type == IgnoredType {
+			continue
+		}
+
+		if problems := e.Validate(); len(problems) > 0 {
+			s.diagnostics = append(s.diagnostics, rejectedDiagnostic(WayElement, e.Id, MalformedCode, "way", validationMessage(problems)))
+			continue
+		}
+
+		switch c.Type {
 		case BuildingType:
-			buildingElements = append(buildingElements, e)
+			if !outlines[e.Id] {
+				s.buildings = append(s.buildings, e)
+			}
 		case HighwayType:
-			highwayElements = append(highwayElements, e)
+			s.highways = append(s.highways, e)
+		case AreaType:
+			s.areas = append(s.areas, &classifiedWay{e, c})
+		case LineType:
+			s.lines = append(s.lines, &classifiedWay{e, c})
+		default:
+			if handlers[c.Type] == nil {
+				s.diagnostics = append(s.diagnostics, rejectedDiagnostic(WayElement, e.Id, UnclassifiedCode, "way",
+					"classified as "+c.Type.String()+" which has no handler"))
+			} else {
+				s.custom[c.Type] = append(s.custom[c.Type], e)
+			}
 		}
 	}
 
-	return buildingElements, highwayElements
+	return s
 }
 
-func classify(e *overpass.Way) (t elementType, err error) {
-	if e.Tags.Building != "" {
-		if e.Tags.Highway != "" {
-			return -1, errors.New("element is both building and highway type")
+// Finds or creates a node in game coordinates in the pool for each of the OSM nodes
+func convertNodes(toGameCoords world.LatLonToGameFunc, pool *nodePool, nodeIds []uint64, geometry []*overpass.LatLon) (n []*world.Node, err error) {
+	if len(nodeIds) != len(geometry) {
+		return nil, errGeometryMismatch
+	}
+
+	points := make([]*world.Node, 0, len(nodeIds))
+	for i, nodeId := range nodeIds {
+		x, y := toGameCoords(geometry[i].Lat, geometry[i].Lon)
+		node, err := pool.node(nodeId, x, y)
+		if err != nil {
+			return nil, err
 		}
 
-		return BuildingType, nil
-	} else if e.Tags.Highway != "" {
-		return HighwayType, nil
+		points = append(points, node)
 	}
 
-	return -1, errors.New("element does not have type")
+	return points, nil
 }
@@ -1,9 +1,16 @@
 package convert
 
 import (
+	"encoding/json"
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
 	"testing"
 )
 
@@ -34,60 +41,311 @@ func TestConvert(t *testing.T) {
 				},
 				Tags: &overpass.Tags{Building: "yes"},
 			},
+			{
+				Id:    2,
+				Nodes: []uint64{6, 7, 8, 6},
+				Geometry: []*overpass.LatLon{
+					{Lat: 0.0, Lon: 0.6}, {Lat: 0.0, Lon: 1.0}, {Lat: 0.4, Lon: 1.0}, {Lat: 0.0, Lon: 0.6},
+				},
+				Tags: &overpass.Tags{Natural: "water"},
+			},
+			{
+				Id:       3,
+				Nodes:    []uint64{9, 10},
+				Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.5}, {Lat: 0.5, Lon: 0.0}},
+				Tags:     &overpass.Tags{Railway: "rail"},
+			},
+		},
+		Nodes: []*overpass.Node{
+			{Id: 11, Lat: 0.7, Lon: 0.7, Tags: &overpass.Tags{Shop: "bakery"}},
 		},
 	}
 
-	makeId := func(baseId uint64, idType world.Type) world.Id {
-		id, err := world.NewId(baseId, idType)
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
 		require.NoError(t, err)
 		return id
 	}
 
 	expectedRoads := []*world.Road{
-		world.NewRoad(makeId(0, world.RoadType), world.NewNode(makeId(0, world.NodeType), 0, 0)),
-		world.NewRoad(makeId(1, world.RoadType), world.NewNode(makeId(1, world.NodeType), 50, 50)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 0, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 0, world.NodeType), 0, 0)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 1, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 1, world.NodeType), 50, 50)),
 	}
 
 	expectedRoads[0].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{expectedRoads[1]}})
 	expectedRoads[1].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{expectedRoads[0]}})
 
 	expectedBuildings := []*world.Building{
-		world.NewBuilding(makeId(1, world.BuildingType), []*world.Node{
-			world.NewNode(makeId(2, world.NodeType), 60, 60),
-			world.NewNode(makeId(3, world.NodeType), 60, 100),
-			world.NewNode(makeId(4, world.NodeType), 100, 100),
-			world.NewNode(makeId(5, world.NodeType), 100, 60),
+		world.NewBuilding(makeId(ids.BuildingWayNamespace, 1, world.BuildingType), []*world.Node{
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 2, world.NodeType), 60, 60),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 5, world.NodeType), 100, 60),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 4, world.NodeType), 100, 100),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 3, world.NodeType), 60, 100),
 		}),
 	}
 
 	expectedContainer := world.NewContainer(metadata, expectedRoads, expectedBuildings)
 
-	container, err := Convert(metadata, &result)
+	w, report, err := Convert(metadata, &result)
+	require.NoError(t, err)
+	require.Empty(t, report.Diagnostics)
+	require.Equal(t, expectedContainer, w.Container)
+	require.Empty(t, w.Holes)
+	require.Len(t, w.Attributes, 1)
+
+	expectedAreas := []*layers.Area{
+		{
+			OsmId: 2,
+			Class: layers.WaterArea,
+			Outer: []*world.Node{
+				world.NewNode(makeId(ids.AreaNodeNamespace, 6, world.NodeType), 60, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 7, world.NodeType), 100, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 8, world.NodeType), 100, 40),
+			},
+		},
+	}
+	require.Equal(t, expectedAreas, w.Areas)
+
+	expectedLines := []*layers.Line{
+		{
+			OsmId: 3,
+			Class: layers.RailLine,
+			Points: []*world.Node{
+				world.NewNode(makeId(ids.LineNodeNamespace, 9, world.NodeType), 50, 0),
+				world.NewNode(makeId(ids.LineNodeNamespace, 10, world.NodeType), 0, 50),
+			},
+		},
+	}
+	require.Equal(t, expectedLines, w.Lines)
+
+	require.Len(t, w.Pois, 1)
+	require.Equal(t, layers.ShopPoi, w.Pois[0].Category)
+	require.Same(t, w.Buildings()[0], w.Pois[0].Building, "the bakery is inside of the building")
+}
+
+func TestSeparate(t *testing.T) {
+	nodes := []uint64{0, 1}
+	geometry := []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.5, Lon: 0.5}}
+
+	elements := []*overpass.Way{
+		{Id: 0, Nodes: nodes, Geometry: geometry, Tags: &overpass.Tags{Highway: "primary"}},
+		{Id: 1, Nodes: nodes, Geometry: geometry, Tags: &overpass.Tags{Building: "yes", Highway: "primary"}},
+		{Id: 2, Nodes: nodes, Geometry: geometry, Tags: &overpass.Tags{Natural: "tree_row"}},
+		{Id: 3, Nodes: nodes, Geometry: geometry, Tags: nil},
+		{Id: 4, Nodes: nodes, Geometry: geometry, Tags: &overpass.Tags{Building: "yes"}},
+		nil,
+		{Id: 5, Nodes: nodes, Geometry: geometry[:1], Tags: &overpass.Tags{Highway: "primary"}},
+	}
+
+	s := separate(elements, map[uint64]bool{3: true}, nil, []Classifier{DefaultClassifier}, nil)
+	require.Equal(t, []*overpass.Way{elements[4]}, s.buildings)
+	require.Equal(t, []*overpass.Way{elements[0]}, s.highways)
+	require.Empty(t, s.areas)
+	require.Empty(t, s.lines)
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 1, ElementType: WayElement, Code: ConflictingTagsCode, Severity: Error,
+			Message: "way rejected: element is both building and highway type"},
+		{OsmId: 2, ElementType: WayElement, Code: UnclassifiedCode, Severity: Error,
+			Message: "way rejected: element does not have type"},
+		{OsmId: 5, ElementType: WayElement, Code: MalformedCode, Severity: Error,
+			Message: "way rejected: geometry has 1 points for 2 nodes"},
+	}
+	require.Equal(t, expectedDiagnostics, s.diagnostics, "relation members should be skipped without a diagnostic")
+}
+
+func TestConvert_TaggedBuildingOutline(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	// The outer way of the relation is tagged as a building as well
+	outline := &overpass.Way{
+		Id:    1,
+		Nodes: []uint64{2, 3, 4, 5, 2},
+		Geometry: []*overpass.LatLon{
+			{Lat: 0.2, Lon: 0.2}, {Lat: 0.8, Lon: 0.2}, {Lat: 0.8, Lon: 0.8}, {Lat: 0.2, Lon: 0.8}, {Lat: 0.2, Lon: 0.2},
+		},
+		Tags: &overpass.Tags{Building: "yes"},
+	}
+
+	courtyard := &overpass.Way{
+		Id:    6,
+		Nodes: []uint64{7, 8, 9, 10, 7},
+		Geometry: []*overpass.LatLon{
+			{Lat: 0.4, Lon: 0.4}, {Lat: 0.6, Lon: 0.4}, {Lat: 0.6, Lon: 0.6}, {Lat: 0.4, Lon: 0.6}, {Lat: 0.4, Lon: 0.4},
+		},
+	}
+
+	result := &overpass.Result{
+		Elements: []*overpass.Way{outline, courtyard},
+		Relations: []*overpass.Relation{{
+			Id: 11,
+			Members: []*overpass.Member{
+				{Type: "way", Ref: 1, Role: "outer"},
+				{Type: "way", Ref: 6, Role: "inner"},
+			},
+			Tags: &overpass.Tags{Building: "yes", Type: "multipolygon"},
+		}},
+	}
+
+	w, report, err := Convert(metadata, result)
 	require.NoError(t, err)
-	require.Equal(t, expectedContainer, container)
+	require.Empty(t, report.Diagnostics)
+	require.Len(t, w.Buildings(), 1, "the outline should only be converted as part of the relation")
+	require.Equal(t, ids.MultipolygonWayNamespace, ids.Lookup(w.Buildings()[0].Id()).Namespace)
+	require.Len(t, w.Holes[w.Buildings()[0].Id()], 1)
 }
 
-func TestClassify(t *testing.T) {
-	test := func(tags overpass.Tags, expected elementType, shouldError bool) {
-		w := overpass.Way{
-			Id:       0,
-			Bounds:   nil,
-			Nodes:    nil,
-			Geometry: nil,
-			Tags:     &tags,
+// Builds a result that is mostly made of plausible elements but with a good chance of nil entries, geometry that
+// doesn't match the nodes, missing tags and coordinates that aren't numbers
+func randomResult(r *rand.Rand) *overpass.Result {
+	tagChoices := []*overpass.Tags{
+		nil,
+		{},
+		{Highway: "primary"},
+		{Building: "yes", Height: "tall", Levels: "2"},
+		{Building: "yes", Highway: "service"},
+		{Landuse: "grass"},
+		{Natural: "water"},
+		{Waterway: "river"},
+		{Railway: "tram"},
+		{Amenity: "bank"},
+	}
+
+	randomId := func() uint64 {
+		if r.Intn(20) == 0 {
+			return 1 << 50
 		}
 
-		r, err := classify(&w)
-		if shouldError {
-			require.Error(t, err)
-		} else {
-			require.NoError(t, err)
-			require.Equal(t, expected, r)
+		return uint64(r.Intn(20))
+	}
+
+	randomPoint := func() *overpass.LatLon {
+		switch r.Intn(20) {
+		case 0:
+			return nil
+		case 1:
+			return &overpass.LatLon{Lat: math.NaN(), Lon: math.Inf(1)}
 		}
+
+		return &overpass.LatLon{Lat: r.Float64(), Lon: r.Float64()}
 	}
 
-	test(overpass.Tags{Building: "", Highway: ""}, -1, true)
-	test(overpass.Tags{Building: "yes", Highway: ""}, BuildingType, false)
-	test(overpass.Tags{Building: "", Highway: "primary"}, HighwayType, false)
-	test(overpass.Tags{Building: "yes", Highway: "primary"}, -1, true)
+	result := new(overpass.Result)
+	for i := r.Intn(15); i > 0; i-- {
+		if r.Intn(20) == 0 {
+			result.Elements = append(result.Elements, nil)
+			continue
+		}
+
+		w := &overpass.Way{Id: randomId(), Tags: tagChoices[r.Intn(len(tagChoices))]}
+		for j := r.Intn(8); j > 0; j-- {
+			w.Nodes = append(w.Nodes, randomId())
+		}
+
+		if len(w.Nodes) > 2 && r.Intn(2) == 0 {
+			w.Nodes = append(w.Nodes, w.Nodes[0])
+		}
+
+		geometryLength := len(w.Nodes)
+		if r.Intn(5) == 0 {
+			geometryLength = r.Intn(10)
+		}
+
+		for j := 0; j < geometryLength; j++ {
+			w.Geometry = append(w.Geometry, randomPoint())
+		}
+
+		result.Elements = append(result.Elements, w)
+	}
+
+	for i := r.Intn(4); i > 0; i-- {
+		if r.Intn(10) == 0 {
+			result.Relations = append(result.Relations, nil)
+			continue
+		}
+
+		relation := &overpass.Relation{Id: randomId(), Tags: tagChoices[r.Intn(len(tagChoices))]}
+		if relation.Tags != nil {
+			tags := *relation.Tags
+			tags.Type = "multipolygon"
+			relation.Tags = &tags
+		}
+
+		for j := r.Intn(5); j > 0; j-- {
+			if r.Intn(10) == 0 {
+				relation.Members = append(relation.Members, nil)
+				continue
+			}
+
+			roles := []string{"outer", "inner", ""}
+			relation.Members = append(relation.Members, &overpass.Member{Type: "way", Ref: randomId(), Role: roles[r.Intn(len(roles))]})
+		}
+
+		result.Relations = append(result.Relations, relation)
+	}
+
+	for i := r.Intn(5); i > 0; i-- {
+		if r.Intn(10) == 0 {
+			result.Nodes = append(result.Nodes, nil)
+			continue
+		}
+
+		p := randomPoint()
+		if p == nil {
+			p = &overpass.LatLon{}
+		}
+
+		result.Nodes = append(result.Nodes, &overpass.Node{Id: randomId(), Lat: p.Lat, Lon: p.Lon, Tags: tagChoices[r.Intn(len(tagChoices))]})
+	}
+
+	return result
+}
+
+func TestConvertRandomResults(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+	r := rand.New(rand.NewSource(34))
+
+	for i := 0; i < 2000; i++ {
+		result := randomResult(r)
+
+		require.NotPanics(t, func() {
+			w, report, err := Convert(metadata, result)
+			require.NoError(t, err, "lenient conversion should skip bad elements")
+			require.NotNil(t, w)
+			require.NotNil(t, report)
+
+			_, _, _ = Convert(metadata, result, WithMode(Strict))
+		}, "iteration %d", i)
+	}
+}
+
+// Replays the go-fuzz corpus, which holds the seed inputs and any crashers found by Fuzz
+func TestConvertFuzzCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "fuzz", "corpus", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+
+			result := new(overpass.Result)
+			require.NoError(t, json.Unmarshal(data, result))
+
+			require.NotPanics(t, func() {
+				w, report, err := Convert(metadata, result)
+				require.NoError(t, err, "lenient conversion should skip bad elements")
+				require.NotNil(t, w)
+
+				// Strict mode may only fail on an element that lenient mode reported
+				_, _, err = Convert(metadata, result, WithMode(Strict))
+				if err != nil {
+					require.IsType(t, &ElementError{}, err)
+					require.Contains(t, report.Diagnostics, err.(*ElementError).Diagnostic)
+				}
+			})
+		})
+	}
 }
@@ -0,0 +1,196 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/overpass"
+	"io"
+	"strings"
+)
+
+// Identifies why an element could not be converted as-is
+type Code string
+
+const (
+	TooFewPointsCode     Code = "too-few-points"
+	ZeroAreaCode         Code = "zero-area"
+	SelfIntersectingCode Code = "self-intersecting"
+	MissingMemberCode    Code = "missing-member"
+	UnclosedRingCode     Code = "unclosed-ring"
+	OrphanInnerRingCode  Code = "orphan-inner-ring"
+	InvalidTagCode       Code = "invalid-tag"
+	UnclassifiedCode     Code = "unclassified"
+	ConflictingTagsCode  Code = "conflicting-tags"
+	RepairedGeometryCode Code = "repaired-geometry"
+	MalformedCode        Code = "malformed"
+	InvalidIdCode        Code = "invalid-id"
+	DuplicateIdCode      Code = "duplicate-id"
+)
+
+// How much of an element made it into the world
+type Severity int
+
+const (
+	// The element was repaired and kept
+	Info Severity = iota
+	// The element was kept but some of its data was dropped or replaced with a default
+	Warning
+	// The element was left out of the world
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*s = Info
+	case "warning":
+		*s = Warning
+	case "error":
+		*s = Error
+	default:
+		return errors.New("unknown severity: " + string(text))
+	}
+
+	return nil
+}
+
+// The kind of OSM element a diagnostic refers to
+type ElementType = ids.ElementType
+
+const (
+	NodeElement     = ids.NodeElement
+	WayElement      = ids.WayElement
+	RelationElement = ids.RelationElement
+)
+
+// Describes a problem with a single OSM element that was found during conversion
+type Diagnostic struct {
+	OsmId       uint64      `json:"osmId"`
+	ElementType ElementType `json:"elementType"`
+	Code        Code        `json:"code"`
+	Severity    Severity    `json:"severity"`
+	Message     string      `json:"message"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s %s/%d: %s: %s", d.Severity, d.ElementType, d.OsmId, d.Code, d.Message)
+}
+
+// Counts of the diagnostics in a report
+type Summary struct {
+	Total      int              `json:"total"`
+	BySeverity map[Severity]int `json:"bySeverity"`
+	ByCode     map[Code]int     `json:"byCode"`
+}
+
+// Every diagnostic produced while converting a single result
+type Report struct {
+	Diagnostics []*Diagnostic `json:"diagnostics"`
+}
+
+func NewReport() *Report {
+	r := new(Report)
+	r.Diagnostics = make([]*Diagnostic, 0)
+	return r
+}
+
+func (r *Report) Add(diagnostics ...*Diagnostic) {
+	r.Diagnostics = append(r.Diagnostics, diagnostics...)
+}
+
+func (r *Report) Summary() *Summary {
+	s := &Summary{BySeverity: make(map[Severity]int), ByCode: make(map[Code]int)}
+	for _, d := range r.Diagnostics {
+		s.Total++
+		s.BySeverity[d.Severity]++
+		s.ByCode[d.Code]++
+	}
+
+	return s
+}
+
+// All of the diagnostics with at least the given severity
+func (r *Report) AtLeast(severity Severity) []*Diagnostic {
+	matching := make([]*Diagnostic, 0)
+	for _, d := range r.Diagnostics {
+		if d.Severity >= severity {
+			matching = append(matching, d)
+		}
+	}
+
+	return matching
+}
+
+// Writes the summary followed by every diagnostic as a single JSON object
+func (r *Report) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Summary     *Summary      `json:"summary"`
+		Diagnostics []*Diagnostic `json:"diagnostics"`
+	}{r.Summary(), r.Diagnostics})
+}
+
+// An element that was left out of the world, where kind is what it would have become
+func rejectedDiagnostic(elementType ElementType, osmId uint64, code Code, kind, reason string) *Diagnostic {
+	return &Diagnostic{OsmId: osmId, ElementType: elementType, Code: code, Severity: Error, Message: kind + " rejected: " + reason}
+}
+
+// An element that was kept without some of its data
+func droppedDiagnostic(elementType ElementType, osmId uint64, code Code, message string) *Diagnostic {
+	return &Diagnostic{OsmId: osmId, ElementType: elementType, Code: code, Severity: Warning, Message: message}
+}
+
+// An element with points that had to be removed before it could be used
+func repairedDiagnostic(elementType ElementType, osmId uint64, removed int) *Diagnostic {
+	return &Diagnostic{OsmId: osmId, ElementType: elementType, Code: RepairedGeometryCode, Severity: Info,
+		Message: fmt.Sprintf("removed %d duplicate or collinear points", removed)}
+}
+
+// Joins the problems with a way or node into a single message
+func validationMessage(problems []*overpass.ValidationError) string {
+	messages := make([]string, 0, len(problems))
+	for _, p := range problems {
+		messages = append(messages, p.Field+" "+p.Problem)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// The code describing why convertNodes failed
+func nodesCode(err error) Code {
+	if err == errGeometryMismatch {
+		return MalformedCode
+	}
+
+	return InvalidIdCode
+}
+
+// The code describing why normalizeRing failed
+func polygonCode(err error) Code {
+	switch err {
+	case errZeroArea:
+		return ZeroAreaCode
+	case errSelfIntersecting:
+		return SelfIntersectingCode
+	default:
+		return TooFewPointsCode
+	}
+}
@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+	report.Add(
+		repairedDiagnostic(WayElement, 1, 2),
+		droppedDiagnostic(RelationElement, 2, OrphanInnerRingCode, "inner ring dropped"),
+		rejectedDiagnostic(WayElement, 3, TooFewPointsCode, "building", "too small"),
+		rejectedDiagnostic(WayElement, 4, TooFewPointsCode, "area", "too small"),
+	)
+
+	summary := report.Summary()
+	require.Equal(t, 4, summary.Total)
+	require.Equal(t, map[Severity]int{Info: 1, Warning: 1, Error: 2}, summary.BySeverity)
+	require.Equal(t, map[Code]int{RepairedGeometryCode: 1, OrphanInnerRingCode: 1, TooFewPointsCode: 2}, summary.ByCode)
+
+	require.Len(t, report.AtLeast(Info), 4)
+	require.Equal(t, report.Diagnostics[1:], report.AtLeast(Warning))
+	require.Equal(t, report.Diagnostics[2:], report.AtLeast(Error))
+
+	require.Equal(t, "error way/3: too-few-points: building rejected: too small", report.Diagnostics[2].String())
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	report := NewReport()
+	report.Add(droppedDiagnostic(WayElement, 7, InvalidTagCode, "ignored height"))
+
+	var buffer bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buffer))
+
+	require.JSONEq(t, `{
+		"summary": {"total": 1, "bySeverity": {"warning": 1}, "byCode": {"invalid-tag": 1}},
+		"diagnostics": [{"osmId": 7, "elementType": "way", "code": "invalid-tag", "severity": "warning", "message": "ignored height"}]
+	}`, buffer.String())
+
+	var decoded struct {
+		Diagnostics []*Diagnostic `json:"diagnostics"`
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	require.Equal(t, report.Diagnostics, decoded.Diagnostics)
+}
+
+func TestSeverityText(t *testing.T) {
+	for _, s := range []Severity{Info, Warning, Error} {
+		text, err := s.MarshalText()
+		require.NoError(t, err)
+
+		var decoded Severity
+		require.NoError(t, decoded.UnmarshalText(text))
+		require.Equal(t, s, decoded)
+	}
+
+	var s Severity
+	require.Error(t, s.UnmarshalText([]byte("fatal")))
+}
@@ -0,0 +1,225 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+)
+
+var areaLanduses = map[string]layers.AreaClass{
+	"reservoir":               layers.WaterArea,
+	"basin":                   layers.WaterArea,
+	"recreation_ground":       layers.ParkArea,
+	"village_green":           layers.ParkArea,
+	"forest":                  layers.ForestArea,
+	"grass":                   layers.GrassArea,
+	"meadow":                  layers.GrassArea,
+	"farmland":                layers.FarmlandArea,
+	"farmyard":                layers.FarmlandArea,
+	"orchard":                 layers.FarmlandArea,
+	"vineyard":                layers.FarmlandArea,
+	"allotments":              layers.FarmlandArea,
+	"greenhouse_horticulture": layers.FarmlandArea,
+	"residential":             layers.ResidentialArea,
+	"commercial":              layers.CommercialArea,
+	"retail":                  layers.CommercialArea,
+	"industrial":              layers.IndustrialArea,
+	"railway":                 layers.RailwayArea,
+	"cemetery":                layers.CemeteryArea,
+}
+
+var areaNaturals = map[string]layers.AreaClass{
+	"water":     layers.WaterArea,
+	"wetland":   layers.WetlandArea,
+	"wood":      layers.ForestArea,
+	"scrub":     layers.ForestArea,
+	"grassland": layers.GrassArea,
+	"heath":     layers.GrassArea,
+}
+
+var areaLeisures = map[string]layers.AreaClass{
+	"park":   layers.ParkArea,
+	"garden": layers.ParkArea,
+}
+
+var lineWaterways = map[string]layers.LineClass{
+	"river":  layers.RiverLine,
+	"canal":  layers.RiverLine,
+	"stream": layers.StreamLine,
+	"brook":  layers.StreamLine,
+	"ditch":  layers.StreamLine,
+	"drain":  layers.StreamLine,
+}
+
+var lineRailways = map[string]layers.LineClass{
+	"rail":         layers.RailLine,
+	"light_rail":   layers.RailLine,
+	"narrow_gauge": layers.RailLine,
+	"subway":       layers.RailLine,
+	"monorail":     layers.RailLine,
+	"preserved":    layers.RailLine,
+	"tram":         layers.TramLine,
+}
+
+// Works out which kind of area the tags describe, preferring water, then leisure, natural and landuse tags
+func areaClass(tags *overpass.Tags) (c layers.AreaClass, ok bool) {
+	if tags == nil {
+		return 0, false
+	}
+
+	if tags.Natural == "water" || tags.Waterway == "riverbank" {
+		return layers.WaterArea, true
+	}
+
+	if c, ok := areaLeisures[tags.Leisure]; ok {
+		return c, true
+	}
+
+	if c, ok := areaNaturals[tags.Natural]; ok {
+		return c, true
+	}
+
+	if tags.Landuse != "" {
+		// Any land use is better than nothing so unknown values still make an area
+		return areaLanduses[tags.Landuse], true
+	}
+
+	return 0, false
+}
+
+// Works out which kind of line the tags describe
+func lineClass(tags *overpass.Tags) (c layers.LineClass, ok bool) {
+	if tags == nil {
+		return 0, false
+	}
+
+	if c, ok := lineWaterways[tags.Waterway]; ok {
+		return c, true
+	}
+
+	c, ok = lineRailways[tags.Railway]
+	return c, ok
+}
+
+func isClosed(e *overpass.Way) bool {
+	return len(e.Nodes) >= 4 && e.Nodes[0] == e.Nodes[len(e.Nodes)-1]
+}
+
+func isAreaMultipolygon(r *overpass.Relation) bool {
+	if r.Tags == nil || r.Tags.Type != "multipolygon" || r.Tags.Building != "" {
+		return false
+	}
+
+	_, ok := areaClass(r.Tags)
+	return ok
+}
+
+func convertAreas(metadata *world.Metadata, areaElements []*classifiedWay, nodes *nodePool) (a []*layers.Area, d []*Diagnostic, err error) {
+	if areaElements == nil {
+		return nil, nil, errors.New("area elements cannot be nil")
+	}
+
+	toGameCoords, _, err := world.CreateConverters(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	areas := make([]*layers.Area, 0, len(areaElements))
+	diagnostics := make([]*Diagnostic, 0)
+
+	for _, e := range areaElements {
+		points, err := convertNodes(toGameCoords, nodes, e.Nodes, e.Geometry)
+		if err != nil {
+			diagnostics = append(diagnostics, rejectedDiagnostic(WayElement, e.Id, nodesCode(err), "area", err.Error()))
+			continue
+		}
+
+		ring, removed, err := normalizeRing(points)
+		if err != nil {
+			diagnostics = append(diagnostics, rejectedDiagnostic(WayElement, e.Id, polygonCode(err), "area", err.Error()))
+			continue
+		} else if removed > 0 {
+			diagnostics = append(diagnostics, repairedDiagnostic(WayElement, e.Id, removed))
+		}
+
+		areas = append(areas, &layers.Area{OsmId: e.Id, Class: e.AreaClass, Outer: ring})
+	}
+
+	return areas, diagnostics, nil
+}
+
+// Converts multipolygon relations into an area for each outer ring, all sharing the id of the relation
+func convertAreaRelations(metadata *world.Metadata, relations []*overpass.Relation, ways map[uint64]*overpass.Way, nodes *nodePool) (a []*layers.Area, d []*Diagnostic, err error) {
+	if relations == nil {
+		return nil, nil, errors.New("relations cannot be nil")
+	}
+
+	toGameCoords, _, err := world.CreateConverters(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	areas := make([]*layers.Area, 0, len(relations))
+	diagnostics := make([]*Diagnostic, 0)
+
+	for _, r := range relations {
+		class, _ := areaClass(r.Tags)
+
+		polygons, polygonDiagnostics, err := assembleMultipolygon(toGameCoords, "area", nodes, r, ways)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		diagnostics = append(diagnostics, polygonDiagnostics...)
+		for _, p := range polygons {
+			areas = append(areas, &layers.Area{OsmId: r.Id, Class: class, Outer: p.outer, Holes: p.holes})
+		}
+	}
+
+	return areas, diagnostics, nil
+}
+
+func convertLines(metadata *world.Metadata, lineElements []*classifiedWay) (l []*layers.Line, d []*Diagnostic, err error) {
+	if lineElements == nil {
+		return nil, nil, errors.New("line elements cannot be nil")
+	}
+
+	toGameCoords, _, err := world.CreateConverters(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := make([]*layers.Line, 0, len(lineElements))
+	diagnostics := make([]*Diagnostic, 0)
+	nodes := newNodePool(ids.LineNodeNamespace)
+
+	for _, e := range lineElements {
+		points, err := convertNodes(toGameCoords, nodes, e.Nodes, e.Geometry)
+		if err != nil {
+			diagnostics = append(diagnostics, rejectedDiagnostic(WayElement, e.Id, nodesCode(err), "line", err.Error()))
+			continue
+		}
+
+		// Points that end up on top of each other after rounding to game coordinates add nothing to the line
+		deduplicated := make([]*world.Node, 0, len(points))
+		for _, p := range points {
+			if len(deduplicated) == 0 || !samePoint(deduplicated[len(deduplicated)-1], p) {
+				deduplicated = append(deduplicated, p)
+			}
+		}
+
+		if len(deduplicated) < 2 {
+			diagnostics = append(diagnostics, rejectedDiagnostic(WayElement, e.Id, TooFewPointsCode, "line",
+				"line has fewer than 2 distinct points"))
+			continue
+		} else if len(deduplicated) < len(points) {
+			diagnostics = append(diagnostics, repairedDiagnostic(WayElement, e.Id, len(points)-len(deduplicated)))
+		}
+
+		lines = append(lines, &layers.Line{OsmId: e.Id, Class: e.LineClass, Points: deduplicated})
+	}
+
+	return lines, diagnostics, nil
+}
@@ -0,0 +1,259 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAreaClass(t *testing.T) {
+	test := func(tags *overpass.Tags, expected layers.AreaClass, expectedOk bool) {
+		c, ok := areaClass(tags)
+		require.Equal(t, expectedOk, ok)
+		if expectedOk {
+			require.Equal(t, expected, c)
+		}
+	}
+
+	test(nil, 0, false)
+	test(&overpass.Tags{}, 0, false)
+	test(&overpass.Tags{Natural: "water"}, layers.WaterArea, true)
+	test(&overpass.Tags{Waterway: "riverbank"}, layers.WaterArea, true)
+	test(&overpass.Tags{Landuse: "reservoir"}, layers.WaterArea, true)
+	test(&overpass.Tags{Leisure: "park"}, layers.ParkArea, true)
+	test(&overpass.Tags{Natural: "wood"}, layers.ForestArea, true)
+	test(&overpass.Tags{Landuse: "forest"}, layers.ForestArea, true)
+	test(&overpass.Tags{Landuse: "industrial"}, layers.IndustrialArea, true)
+	test(&overpass.Tags{Landuse: "quarry"}, layers.OtherArea, true)
+	test(&overpass.Tags{Leisure: "park", Landuse: "grass"}, layers.ParkArea, true)
+	test(&overpass.Tags{Leisure: "park", Natural: "water"}, layers.WaterArea, true)
+	test(&overpass.Tags{Natural: "tree_row"}, 0, false)
+	test(&overpass.Tags{Leisure: "pitch"}, 0, false)
+}
+
+func TestLineClass(t *testing.T) {
+	test := func(tags *overpass.Tags, expected layers.LineClass, expectedOk bool) {
+		c, ok := lineClass(tags)
+		require.Equal(t, expectedOk, ok)
+		if expectedOk {
+			require.Equal(t, expected, c)
+		}
+	}
+
+	test(nil, 0, false)
+	test(&overpass.Tags{}, 0, false)
+	test(&overpass.Tags{Waterway: "river"}, layers.RiverLine, true)
+	test(&overpass.Tags{Waterway: "stream"}, layers.StreamLine, true)
+	test(&overpass.Tags{Waterway: "riverbank"}, 0, false)
+	test(&overpass.Tags{Railway: "rail"}, layers.RailLine, true)
+	test(&overpass.Tags{Railway: "tram"}, layers.TramLine, true)
+	test(&overpass.Tags{Railway: "abandoned"}, 0, false)
+}
+
+func TestIsAreaMultipolygon(t *testing.T) {
+	require.True(t, isAreaMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Natural: "water", Type: "multipolygon"}}))
+	require.False(t, isAreaMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Natural: "water", Type: "site"}}))
+	require.False(t, isAreaMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Landuse: "retail", Building: "yes", Type: "multipolygon"}}))
+	require.False(t, isAreaMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Type: "multipolygon"}}))
+	require.False(t, isAreaMultipolygon(&overpass.Relation{}))
+}
+
+// Classifies the ways with the default classifier so that they can be passed straight to a converter
+func classifyAll(t *testing.T, ways []*overpass.Way) []*classifiedWay {
+	classified := make([]*classifiedWay, 0, len(ways))
+	for _, w := range ways {
+		c, err := classify(w)
+		require.NoError(t, err)
+		classified = append(classified, &classifiedWay{w, c})
+	}
+
+	return classified
+}
+
+func TestConvertAreas(t *testing.T) {
+	_, _, err := convertAreas(nil, []*classifiedWay{}, newNodePool(ids.AreaNodeNamespace))
+	require.Error(t, err, "nil metadata should error")
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	_, _, err = convertAreas(metadata, nil, newNodePool(ids.AreaNodeNamespace))
+	require.Error(t, err, "nil area elements should error")
+
+	areaElements := []*overpass.Way{
+		{
+			Id:    0,
+			Nodes: []uint64{0, 1, 2, 0},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.5}, {Lat: 0.5, Lon: 0.5}, {Lat: 0.0, Lon: 0.0},
+			},
+			Tags: &overpass.Tags{Natural: "water"},
+		},
+		{
+			Id:    1,
+			Nodes: []uint64{3, 4, 5, 3},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.5}, {Lat: 0.0, Lon: 1.0}, {Lat: 0.0, Lon: 0.0},
+			},
+			Tags: &overpass.Tags{Leisure: "park"},
+		},
+	}
+
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
+		require.NoError(t, err)
+		return id
+	}
+
+	expectedAreas := []*layers.Area{
+		{
+			OsmId: 0,
+			Class: layers.WaterArea,
+			Outer: []*world.Node{
+				world.NewNode(makeId(ids.AreaNodeNamespace, 0, world.NodeType), 0, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 1, world.NodeType), 50, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 2, world.NodeType), 50, 50),
+			},
+		},
+	}
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 1, ElementType: WayElement, Code: TooFewPointsCode, Severity: Error, Message: "area rejected: polygon has fewer than 3 distinct non-collinear points"},
+	}
+
+	areas, diagnostics, err := convertAreas(metadata, classifyAll(t, areaElements), newNodePool(ids.AreaNodeNamespace))
+	require.NoError(t, err)
+	require.Equal(t, expectedAreas, areas)
+	require.Equal(t, expectedDiagnostics, diagnostics)
+}
+
+func TestConvertAreaRelations(t *testing.T) {
+	_, _, err := convertAreaRelations(nil, []*overpass.Relation{}, nil, newNodePool(ids.AreaNodeNamespace))
+	require.Error(t, err, "nil metadata should error")
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	_, _, err = convertAreaRelations(metadata, nil, nil, newNodePool(ids.AreaNodeNamespace))
+	require.Error(t, err, "nil relations should error")
+
+	ways := map[uint64]*overpass.Way{
+		10: {
+			Id:    10,
+			Nodes: []uint64{0, 1, 2, 3, 0},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.6}, {Lat: 0.6, Lon: 0.6}, {Lat: 0.6, Lon: 0.0}, {Lat: 0.0, Lon: 0.0},
+			},
+		},
+		11: {
+			Id:    11,
+			Nodes: []uint64{4, 5, 6, 4},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.2, Lon: 0.2}, {Lat: 0.2, Lon: 0.4}, {Lat: 0.4, Lon: 0.4}, {Lat: 0.2, Lon: 0.2},
+			},
+		},
+	}
+
+	relations := []*overpass.Relation{
+		{
+			Id: 1,
+			Members: []*overpass.Member{
+				{Type: "way", Ref: 10, Role: "outer"},
+				{Type: "way", Ref: 11, Role: "inner"},
+			},
+			Tags: &overpass.Tags{Natural: "water", Type: "multipolygon"},
+		},
+		{
+			Id:      2,
+			Members: []*overpass.Member{{Type: "way", Ref: 12, Role: "outer"}},
+			Tags:    &overpass.Tags{Landuse: "forest", Type: "multipolygon"},
+		},
+	}
+
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
+		require.NoError(t, err)
+		return id
+	}
+
+	expectedAreas := []*layers.Area{
+		{
+			OsmId: 1,
+			Class: layers.WaterArea,
+			Outer: []*world.Node{
+				world.NewNode(makeId(ids.AreaNodeNamespace, 0, world.NodeType), 0, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 1, world.NodeType), 60, 0),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 2, world.NodeType), 60, 60),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 3, world.NodeType), 0, 60),
+			},
+			Holes: [][]*world.Node{{
+				world.NewNode(makeId(ids.AreaNodeNamespace, 4, world.NodeType), 20, 20),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 6, world.NodeType), 40, 40),
+				world.NewNode(makeId(ids.AreaNodeNamespace, 5, world.NodeType), 40, 20),
+			}},
+		},
+	}
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 2, ElementType: RelationElement, Code: MissingMemberCode, Severity: Error, Message: "area rejected: member way is missing from the result"},
+	}
+
+	areas, diagnostics, err := convertAreaRelations(metadata, relations, ways, newNodePool(ids.AreaNodeNamespace))
+	require.NoError(t, err)
+	require.Equal(t, expectedAreas, areas)
+	require.Equal(t, expectedDiagnostics, diagnostics)
+}
+
+func TestConvertLines(t *testing.T) {
+	_, _, err := convertLines(nil, []*classifiedWay{})
+	require.Error(t, err, "nil metadata should error")
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	_, _, err = convertLines(metadata, nil)
+	require.Error(t, err, "nil line elements should error")
+
+	lineElements := []*overpass.Way{
+		{
+			Id:       0,
+			Nodes:    []uint64{0, 1, 2},
+			Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.001}, {Lat: 0.5, Lon: 0.5}},
+			Tags:     &overpass.Tags{Waterway: "river"},
+		},
+		{
+			Id:       1,
+			Nodes:    []uint64{3, 4},
+			Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.001}},
+			Tags:     &overpass.Tags{Railway: "rail"},
+		},
+	}
+
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
+		require.NoError(t, err)
+		return id
+	}
+
+	expectedLines := []*layers.Line{
+		{
+			OsmId: 0,
+			Class: layers.RiverLine,
+			Points: []*world.Node{
+				world.NewNode(makeId(ids.LineNodeNamespace, 0, world.NodeType), 0, 0),
+				world.NewNode(makeId(ids.LineNodeNamespace, 2, world.NodeType), 50, 50),
+			},
+		},
+	}
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 0, ElementType: WayElement, Code: RepairedGeometryCode, Severity: Info,
+			Message: "removed 1 duplicate or collinear points"},
+		{OsmId: 1, ElementType: WayElement, Code: TooFewPointsCode, Severity: Error, Message: "line rejected: line has fewer than 2 distinct points"},
+	}
+
+	lines, diagnostics, err := convertLines(metadata, classifyAll(t, lineElements))
+	require.NoError(t, err)
+	require.Equal(t, expectedLines, lines)
+	require.Equal(t, expectedDiagnostics, diagnostics)
+}
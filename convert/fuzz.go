@@ -0,0 +1,35 @@
+//go:build gofuzz
+// +build gofuzz
+
+package convert
+
+import (
+	"encoding/json"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/overpass"
+)
+
+// Entry point for go-fuzz, run from this directory with
+//
+//	go-fuzz-build && go-fuzz -workdir testdata/fuzz
+//
+// New crashers should be minimized and added to testdata/fuzz/corpus.
+func Fuzz(data []byte) int {
+	result := new(overpass.Result)
+	if err := json.Unmarshal(data, result); err != nil {
+		return 0
+	}
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+	if _, _, err := Convert(metadata, result); err != nil {
+		panic("lenient conversion failed: " + err.Error())
+	}
+
+	if _, _, err := Convert(metadata, result, WithMode(Strict)); err != nil {
+		if _, ok := err.(*ElementError); !ok {
+			panic("strict conversion failed: " + err.Error())
+		}
+	}
+
+	return 1
+}
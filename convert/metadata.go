@@ -0,0 +1,96 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/overpass"
+	"math"
+)
+
+// Mean radius of the earth in metres
+const earthRadius = 6371008.8
+
+const degreeToRad = math.Pi / 180
+
+// Creates metadata for the bounds with unitsPerMetre game units to every metre on the ground
+func MetadataFromResolution(bounds *overpass.Bounds, unitsPerMetre float64) (meta *world.Metadata, err error) {
+	if math.IsNaN(unitsPerMetre) || math.IsInf(unitsPerMetre, 0) || unitsPerMetre <= 0 {
+		return nil, errors.New("units per metre must be a positive finite number")
+	}
+
+	lonSpan, latSpan, err := projectedSpans(bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scale the projected width so that it matches the ground distance across the middle of the bounds
+	midLat := (bounds.MinLat + bounds.MaxLat) / 2
+	width := lonSpan * earthRadius * math.Cos(midLat*degreeToRad) * unitsPerMetre
+	height := width * (latSpan / lonSpan)
+
+	return newSizedMetadata(bounds, width, height)
+}
+
+// Creates metadata for the bounds where the longer side is maxDimension game units
+func MetadataFromMaxDimension(bounds *overpass.Bounds, maxDimension int) (meta *world.Metadata, err error) {
+	if maxDimension <= 0 {
+		return nil, errors.New("max dimension must be positive")
+	}
+
+	lonSpan, latSpan, err := projectedSpans(bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := float64(maxDimension), float64(maxDimension)
+	if lonSpan > latSpan {
+		height = width * (latSpan / lonSpan)
+	} else {
+		width = height * (lonSpan / latSpan)
+	}
+
+	return newSizedMetadata(bounds, width, height)
+}
+
+// Returns the width and height of the bounds in the mercator projection on a unit sphere
+func projectedSpans(bounds *overpass.Bounds) (lonSpan, latSpan float64, err error) {
+	if bounds == nil {
+		return 0, 0, errors.New("bounds cannot be nil")
+	}
+
+	for _, lat := range []float64{bounds.MinLat, bounds.MaxLat} {
+		// The mercator projection goes to infinity at the poles
+		if math.IsNaN(lat) || -90.0 >= lat || lat >= 90.0 {
+			return 0, 0, errors.New("latitude must be in range -90 to 90 exclusive")
+		}
+	}
+
+	for _, lon := range []float64{bounds.MinLon, bounds.MaxLon} {
+		if math.IsNaN(lon) || -180.0 > lon || lon > 180.0 {
+			return 0, 0, errors.New("longitude must be in range -180 to 180 inclusive")
+		}
+	}
+
+	lonSpan = math.Abs(bounds.MaxLon-bounds.MinLon) * degreeToRad
+	latSpan = math.Abs(mercatorY(bounds.MaxLat) - mercatorY(bounds.MinLat))
+	if lonSpan == 0 || latSpan == 0 {
+		return 0, 0, errors.New("bounds must have a non-zero width and height")
+	}
+
+	return lonSpan, latSpan, nil
+}
+
+func mercatorY(lat float64) float64 {
+	return math.Log(math.Tan((math.Pi / 4) + (lat * degreeToRad / 2)))
+}
+
+func newSizedMetadata(bounds *overpass.Bounds, width, height float64) (*world.Metadata, error) {
+	w, h := math.Round(width), math.Round(height)
+	if w > math.MaxInt32 || h > math.MaxInt32 {
+		return nil, errors.New("bounds are too large for the requested size")
+	}
+
+	// Very thin bounds should still produce a world that is at least one unit across
+	return world.NewMetadata(int(math.Max(w, 1)), int(math.Max(h, 1)),
+		bounds.MinLat, bounds.MinLon, bounds.MaxLat, bounds.MaxLon), nil
+}
@@ -0,0 +1,71 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestMetadataFromResolution(t *testing.T) {
+	bounds := &overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 0.001, MaxLon: 0.001}
+
+	_, err := MetadataFromResolution(nil, 1.0)
+	require.Error(t, err, "nil bounds should error")
+
+	_, err = MetadataFromResolution(bounds, 0.0)
+	require.Error(t, err, "zero resolution should error")
+
+	_, err = MetadataFromResolution(bounds, math.NaN())
+	require.Error(t, err, "nan resolution should error")
+
+	_, err = MetadataFromResolution(&overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 0.0, MaxLon: 0.001}, 1.0)
+	require.Error(t, err, "zero height bounds should error")
+
+	_, err = MetadataFromResolution(&overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 90.0, MaxLon: 0.001}, 1.0)
+	require.Error(t, err, "bounds touching the pole should error")
+
+	// 0.001 degrees at the equator is roughly 111.2 metres
+	meta, err := MetadataFromResolution(bounds, 10.0)
+	require.NoError(t, err)
+	require.Equal(t, 1112, meta.Width())
+	require.Equal(t, 1112, meta.Height())
+	require.Equal(t, 0.001, meta.Lat2())
+	require.Equal(t, 0.001, meta.Lon2())
+
+	// Further from the equator the same longitude span covers less ground
+	meta, err = MetadataFromResolution(&overpass.Bounds{MinLat: 59.9995, MinLon: 0.0, MaxLat: 60.0005, MaxLon: 0.001}, 10.0)
+	require.NoError(t, err)
+	require.Equal(t, 556, meta.Width())
+	require.Equal(t, 1112, meta.Height())
+}
+
+func TestMetadataFromMaxDimension(t *testing.T) {
+	_, err := MetadataFromMaxDimension(nil, 1000)
+	require.Error(t, err, "nil bounds should error")
+
+	_, err = MetadataFromMaxDimension(&overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 1.0, MaxLon: 1.0}, 0)
+	require.Error(t, err, "zero max dimension should error")
+
+	_, err = MetadataFromMaxDimension(&overpass.Bounds{MinLat: 0.0, MinLon: 181.0, MaxLat: 1.0, MaxLon: 1.0}, 1000)
+	require.Error(t, err, "invalid longitude should error")
+
+	test := func(bounds *overpass.Bounds, expectedWidth, expectedHeight int) {
+		meta, err := MetadataFromMaxDimension(bounds, 1000)
+		require.NoError(t, err)
+		require.Equal(t, expectedWidth, meta.Width())
+		require.Equal(t, expectedHeight, meta.Height())
+
+		// The projection should not stretch the world so the far corner lands on the far corner of the game world
+		x, y, err := world.LatLonToGame(meta, bounds.MaxLat, bounds.MaxLon)
+		require.NoError(t, err)
+		require.Equal(t, meta.Width(), x)
+		require.Equal(t, meta.Height(), y)
+	}
+
+	test(&overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 0.01, MaxLon: 0.02}, 1000, 500)
+	test(&overpass.Bounds{MinLat: 0.0, MinLon: 0.0, MaxLat: 0.02, MaxLon: 0.01}, 500, 1000)
+	test(&overpass.Bounds{MinLat: 60.0, MinLon: 0.0, MaxLat: 60.01, MaxLon: 0.01}, 500, 1000)
+	test(&overpass.Bounds{MinLat: 60.0, MinLon: 0.0, MaxLat: 60.01, MaxLon: 0.02}, 1000, 1000)
+}
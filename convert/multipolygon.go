@@ -0,0 +1,272 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+)
+
+var errUnclosedRing = errors.New("member ways do not join up into closed rings")
+
+// A sequence of nodes built up by joining member ways end to end
+type chain struct {
+	firstWay uint64
+	nodes    []uint64
+	geometry []*overpass.LatLon
+}
+
+func (c *chain) closed() bool {
+	return len(c.nodes) >= 2 && c.nodes[0] == c.nodes[len(c.nodes)-1]
+}
+
+func (c *chain) appendWay(w *overpass.Way, reversed bool) {
+	for i := 1; i < len(w.Nodes); i++ {
+		j := i
+		if reversed {
+			j = len(w.Nodes) - 1 - i
+		}
+
+		c.nodes = append(c.nodes, w.Nodes[j])
+		c.geometry = append(c.geometry, w.Geometry[j])
+	}
+}
+
+type polygon struct {
+	firstWay uint64
+	outer    []*world.Node
+	holes    [][]*world.Node
+}
+
+func isBuildingMultipolygon(r *overpass.Relation) bool {
+	return r.Tags != nil && r.Tags.Building != "" && r.Tags.Type == "multipolygon"
+}
+
+// Converts building relations into a building for each outer ring, with the inner rings as its holes
+func convertMultipolygons(metadata *world.Metadata, relations []*overpass.Relation, ways map[uint64]*overpass.Way) (o *buildingOutput, err error) {
+	if relations == nil {
+		return nil, errors.New("relations cannot be nil")
+	}
+
+	toGameCoords, _, err := world.CreateConverters(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	output := newBuildingOutput(len(relations))
+
+	for _, r := range relations {
+		polygons, diagnostics, err := assembleMultipolygon(toGameCoords, "building", output.nodes, r, ways)
+		if err != nil {
+			return nil, err
+		}
+
+		output.diagnostics = append(output.diagnostics, diagnostics...)
+		if len(polygons) == 0 {
+			continue
+		}
+
+		// All of the parts of the building share the tags of the relation
+		attributes, diagnostics := parseBuildingAttributes(RelationElement, r.Id, r.Tags)
+		output.diagnostics = append(output.diagnostics, diagnostics...)
+
+		for _, p := range polygons {
+			id, err := ids.New(ids.MultipolygonWayNamespace, p.firstWay, world.BuildingType)
+			if err != nil {
+				output.diagnostics = append(output.diagnostics, rejectedDiagnostic(RelationElement, r.Id, InvalidIdCode, "building", err.Error()))
+				continue
+			} else if _, ok := output.attributes[id]; ok {
+				output.diagnostics = append(output.diagnostics, rejectedDiagnostic(RelationElement, r.Id, DuplicateIdCode, "building",
+					fmt.Sprintf("way %d is already used by another multipolygon", p.firstWay)))
+				continue
+			}
+
+			if len(p.holes) > 0 {
+				output.holes[id] = p.holes
+			}
+
+			output.attributes[id] = attributes
+			output.buildings = append(output.buildings, world.NewBuilding(id, p.outer))
+		}
+	}
+
+	return output, nil
+}
+
+// Stitches the member ways of a relation into normalized rings. A bad ring is dropped on its own while a problem with
+// the whole relation returns no polygons.
+func assembleMultipolygon(toGameCoords world.LatLonToGameFunc, kind string, nodes *nodePool, r *overpass.Relation, ways map[uint64]*overpass.Way) (p []*polygon, d []*Diagnostic, err error) {
+	outerWays, innerWays, missing := memberWays(r, ways)
+	if missing != 0 {
+		return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, MissingMemberCode, kind,
+			"member way is missing from the result")}, nil
+	}
+
+	for _, members := range [][]*overpass.Way{outerWays, innerWays} {
+		for _, w := range members {
+			if problems := w.Validate(); len(problems) > 0 {
+				return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, MalformedCode, kind,
+					fmt.Sprintf("member way %d: %s", w.Id, validationMessage(problems)))}, nil
+			}
+		}
+	}
+
+	outerChains, err := stitchRings(outerWays)
+	if err != nil {
+		return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, UnclosedRingCode, kind, err.Error())}, nil
+	}
+
+	innerChains, err := stitchRings(innerWays)
+	if err != nil {
+		return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, UnclosedRingCode, kind, err.Error())}, nil
+	}
+
+	polygons := make([]*polygon, 0, len(outerChains))
+	diagnostics := make([]*Diagnostic, 0)
+	totalRemoved := 0
+
+	var lastErr error
+	for _, c := range outerChains {
+		points, err := convertNodes(toGameCoords, nodes, c.nodes, c.geometry)
+		if err != nil {
+			return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, nodesCode(err), kind, err.Error())}, nil
+		}
+
+		ring, removed, err := normalizeRing(points)
+		if err != nil {
+			lastErr = err
+			diagnostics = append(diagnostics, droppedDiagnostic(RelationElement, r.Id, polygonCode(err), "outer ring dropped: "+err.Error()))
+			continue
+		}
+
+		totalRemoved += removed
+		polygons = append(polygons, &polygon{firstWay: c.firstWay, outer: ring})
+	}
+
+	if len(polygons) == 0 {
+		code, reason := TooFewPointsCode, "relation has no outer rings"
+		if lastErr != nil {
+			code, reason = polygonCode(lastErr), "none of the outer rings are usable"
+		}
+
+		return nil, append(diagnostics, rejectedDiagnostic(RelationElement, r.Id, code, kind, reason)), nil
+	}
+
+	for _, c := range innerChains {
+		points, err := convertNodes(toGameCoords, nodes, c.nodes, c.geometry)
+		if err != nil {
+			return nil, []*Diagnostic{rejectedDiagnostic(RelationElement, r.Id, nodesCode(err), kind, err.Error())}, nil
+		}
+
+		ring, removed, err := normalizeRing(points)
+		if err != nil {
+			diagnostics = append(diagnostics, droppedDiagnostic(RelationElement, r.Id, polygonCode(err), "inner ring dropped: "+err.Error()))
+			continue
+		}
+
+		// Holes are wound the opposite way to the outer ring
+		reverseRing(ring)
+
+		outer := containingPolygon(polygons, ring)
+		if outer == nil {
+			diagnostics = append(diagnostics, droppedDiagnostic(RelationElement, r.Id, OrphanInnerRingCode,
+				"inner ring dropped: it is not inside any outer ring"))
+			continue
+		}
+
+		totalRemoved += removed
+		outer.holes = append(outer.holes, ring)
+	}
+
+	if totalRemoved > 0 {
+		diagnostics = append(diagnostics, repairedDiagnostic(RelationElement, r.Id, totalRemoved))
+	}
+
+	return polygons, diagnostics, nil
+}
+
+// Splits the member ways of a relation by role, treating members without a role as outer ways
+func memberWays(r *overpass.Relation, ways map[uint64]*overpass.Way) (outer, inner []*overpass.Way, missing int) {
+	for _, m := range r.Members {
+		if m == nil || m.Type != "way" {
+			continue
+		}
+
+		w := ways[m.Ref]
+		if w == nil {
+			missing++
+			continue
+		}
+
+		if m.Role == "inner" {
+			inner = append(inner, w)
+		} else {
+			outer = append(outer, w)
+		}
+	}
+
+	return outer, inner, missing
+}
+
+// Joins ways that share end nodes into closed rings, reversing ways where needed
+func stitchRings(ways []*overpass.Way) (rings []*chain, err error) {
+	remaining := make([]*overpass.Way, len(ways))
+	copy(remaining, ways)
+
+	rings = make([]*chain, 0)
+	for len(remaining) > 0 {
+		first := remaining[0]
+		remaining = remaining[1:]
+		if len(first.Nodes) == 0 {
+			return nil, errUnclosedRing
+		}
+
+		c := &chain{firstWay: first.Id, nodes: []uint64{first.Nodes[0]}, geometry: []*overpass.LatLon{first.Geometry[0]}}
+		c.appendWay(first, false)
+
+		for !c.closed() {
+			end := c.nodes[len(c.nodes)-1]
+
+			joined := false
+			for i, w := range remaining {
+				if len(w.Nodes) == 0 {
+					continue
+				}
+
+				if w.Nodes[0] == end {
+					c.appendWay(w, false)
+				} else if w.Nodes[len(w.Nodes)-1] == end {
+					c.appendWay(w, true)
+				} else {
+					continue
+				}
+
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				joined = true
+				break
+			}
+
+			if !joined {
+				return nil, errUnclosedRing
+			}
+		}
+
+		rings = append(rings, c)
+	}
+
+	return rings, nil
+}
+
+func containingPolygon(polygons []*polygon, hole []*world.Node) *polygon {
+	for _, p := range polygons {
+		for _, n := range hole {
+			if layers.RingContains(p.outer, n.Point) {
+				return p
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConvertMultipolygons(t *testing.T) {
+	_, err := convertMultipolygons(nil, []*overpass.Relation{}, nil)
+	require.Error(t, err, "nil metadata should error")
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	_, err = convertMultipolygons(metadata, nil, nil)
+	require.Error(t, err, "nil relations should error")
+
+	ways := map[uint64]*overpass.Way{
+		// The outer ring is split across two ways with the second one pointing the wrong way
+		10: {
+			Id:       10,
+			Nodes:    []uint64{0, 1, 2},
+			Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.0, Lon: 0.6}, {Lat: 0.6, Lon: 0.6}},
+		},
+		11: {
+			Id:       11,
+			Nodes:    []uint64{0, 3, 2},
+			Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.6, Lon: 0.0}, {Lat: 0.6, Lon: 0.6}},
+		},
+		12: {
+			Id:    12,
+			Nodes: []uint64{4, 5, 6, 7, 4},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.2, Lon: 0.2}, {Lat: 0.2, Lon: 0.4}, {Lat: 0.4, Lon: 0.4}, {Lat: 0.4, Lon: 0.2}, {Lat: 0.2, Lon: 0.2},
+			},
+		},
+		13: {
+			Id:       13,
+			Nodes:    []uint64{8, 9, 10},
+			Geometry: []*overpass.LatLon{{Lat: 0.8, Lon: 0.8}, {Lat: 0.8, Lon: 0.9}, {Lat: 0.9, Lon: 0.9}},
+		},
+		14: {
+			Id:    14,
+			Nodes: []uint64{11, 12, 13, 11},
+			Geometry: []*overpass.LatLon{
+				{Lat: 0.7, Lon: 0.7}, {Lat: 0.7, Lon: 0.8}, {Lat: 0.8, Lon: 0.8}, {Lat: 0.7, Lon: 0.7},
+			},
+		},
+	}
+
+	tags := &overpass.Tags{Building: "school", Type: "multipolygon", Levels: "4", RoofShape: "sawtooth"}
+	relations := []*overpass.Relation{
+		{
+			Id: 1,
+			Members: []*overpass.Member{
+				{Type: "way", Ref: 10, Role: "outer"},
+				{Type: "way", Ref: 12, Role: "inner"},
+				{Type: "way", Ref: 11, Role: "outer"},
+				{Type: "node", Ref: 0, Role: "entrance"},
+			},
+			Tags: tags,
+		},
+		{
+			Id:      2,
+			Members: []*overpass.Member{{Type: "way", Ref: 13, Role: "outer"}},
+			Tags:    tags,
+		},
+		{
+			Id:      3,
+			Members: []*overpass.Member{{Type: "way", Ref: 99, Role: "outer"}},
+			Tags:    tags,
+		},
+		{
+			Id: 4,
+			Members: []*overpass.Member{
+				{Type: "way", Ref: 14, Role: "outer"},
+				{Type: "way", Ref: 12, Role: "inner"},
+			},
+			Tags: tags,
+		},
+	}
+
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
+		require.NoError(t, err)
+		return id
+	}
+
+	expectedBuildings := []*world.Building{
+		world.NewBuilding(makeId(ids.MultipolygonWayNamespace, 10, world.BuildingType), []*world.Node{
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 0, world.NodeType), 0, 0),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 1, world.NodeType), 60, 0),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 2, world.NodeType), 60, 60),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 3, world.NodeType), 0, 60),
+		}),
+		world.NewBuilding(makeId(ids.MultipolygonWayNamespace, 14, world.BuildingType), []*world.Node{
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 11, world.NodeType), 70, 70),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 12, world.NodeType), 80, 70),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 13, world.NodeType), 80, 80),
+		}),
+	}
+
+	expectedHoles := map[world.Id][][]*world.Node{
+		makeId(ids.MultipolygonWayNamespace, 10, world.BuildingType): {{
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 4, world.NodeType), 20, 20),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 7, world.NodeType), 20, 40),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 6, world.NodeType), 40, 40),
+			world.NewNode(makeId(ids.BuildingNodeNamespace, 5, world.NodeType), 40, 20),
+		}},
+	}
+
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 2, ElementType: RelationElement, Code: UnclosedRingCode, Severity: Error, Message: "building rejected: member ways do not join up into closed rings"},
+		{OsmId: 3, ElementType: RelationElement, Code: MissingMemberCode, Severity: Error, Message: "building rejected: member way is missing from the result"},
+		{OsmId: 4, ElementType: RelationElement, Code: OrphanInnerRingCode, Severity: Warning, Message: "inner ring dropped: it is not inside any outer ring"},
+	}
+
+	schoolAttributes := &layers.BuildingAttributes{
+		Type: "school", Class: layers.CivicBuilding, Height: 12.0, Levels: 4, RoofShape: layers.OtherRoof,
+	}
+	expectedAttributes := map[world.Id]*layers.BuildingAttributes{
+		makeId(ids.MultipolygonWayNamespace, 10, world.BuildingType): schoolAttributes,
+		makeId(ids.MultipolygonWayNamespace, 14, world.BuildingType): schoolAttributes,
+	}
+
+	output, err := convertMultipolygons(metadata, relations, ways)
+	require.NoError(t, err)
+	require.Equal(t, expectedBuildings, output.buildings)
+	require.Equal(t, expectedHoles, output.holes)
+	require.Equal(t, expectedAttributes, output.attributes)
+	require.Equal(t, expectedDiagnostics, output.diagnostics)
+}
+
+func TestIsBuildingMultipolygon(t *testing.T) {
+	require.True(t, isBuildingMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Building: "yes", Type: "multipolygon"}}))
+	require.False(t, isBuildingMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Building: "yes", Type: "route"}}))
+	require.False(t, isBuildingMultipolygon(&overpass.Relation{Tags: &overpass.Tags{Type: "multipolygon"}}))
+	require.False(t, isBuildingMultipolygon(&overpass.Relation{}))
+}
@@ -0,0 +1,49 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+)
+
+// The nodes created so far in a namespace, so that an OSM node used by several ways becomes a single node
+type nodePool struct {
+	namespace ids.Namespace
+	nodes     map[world.Id]*world.Node
+}
+
+func newNodePool(namespace ids.Namespace) *nodePool {
+	return &nodePool{namespace: namespace, nodes: make(map[world.Id]*world.Node)}
+}
+
+// The node for the OSM node, which is created at the position if it isn't in the pool yet
+func (p *nodePool) node(osmId uint64, x, y int) (*world.Node, error) {
+	id, err := ids.New(p.namespace, osmId, world.NodeType)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := p.nodes[id]
+	if !ok {
+		n = world.NewNode(id, x, y)
+		p.nodes[id] = n
+	}
+
+	return n, nil
+}
+
+// Swaps each of the nodes for the node in the pool with the same id, adding the ones that aren't in the pool yet
+func (p *nodePool) share(nodes []*world.Node) (shared []*world.Node, swapped bool) {
+	shared = make([]*world.Node, len(nodes))
+	for i, n := range nodes {
+		pooled, ok := p.nodes[n.Id()]
+		if !ok {
+			pooled = n
+			p.nodes[n.Id()] = n
+		}
+
+		shared[i] = pooled
+		swapped = swapped || pooled != n
+	}
+
+	return shared, swapped
+}
@@ -0,0 +1,103 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConvertSharedNodes(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	square := []*overpass.LatLon{
+		{Lat: 0.1, Lon: 0.1}, {Lat: 0.1, Lon: 0.4}, {Lat: 0.4, Lon: 0.4}, {Lat: 0.4, Lon: 0.1}, {Lat: 0.1, Lon: 0.1},
+	}
+	result := &overpass.Result{
+		Elements: []*overpass.Way{
+			{Id: 1, Nodes: []uint64{1, 2, 3, 4, 1}, Geometry: square, Tags: &overpass.Tags{Building: "yes"}},
+			// A footway that runs along one of the walls of the building
+			{Id: 2, Nodes: []uint64{1, 2}, Geometry: square[:2], Tags: &overpass.Tags{Highway: "footway"}},
+			{Id: 1, Nodes: []uint64{1, 2, 3, 4, 1}, Geometry: square, Tags: &overpass.Tags{Building: "yes"}},
+			{Id: ids.MaxOsmId + 1, Nodes: []uint64{1, 2, 3, 4, 1}, Geometry: square, Tags: &overpass.Tags{Building: "yes"}},
+		},
+	}
+
+	w, report, err := Convert(metadata, result)
+	require.NoError(t, err)
+	require.Len(t, w.Buildings(), 1)
+	require.Len(t, w.Roads(), 2)
+
+	buildingNodes := make(map[world.Id]bool)
+	for _, n := range w.Buildings()[0].Points() {
+		buildingNodes[n.Id()] = true
+	}
+
+	for _, r := range w.Roads() {
+		require.False(t, buildingNodes[r.Node.Id()], "road and building nodes should have different ids")
+		require.Equal(t, ids.RoadNodeNamespace, ids.Lookup(r.Node.Id()).Namespace)
+	}
+
+	require.Equal(t, &ids.OsmRef{Namespace: ids.BuildingWayNamespace, ElementType: WayElement, OsmId: 1}, ids.Lookup(w.Buildings()[0].Id()))
+
+	require.Len(t, report.Diagnostics, 2)
+	require.Equal(t, DuplicateIdCode, report.Diagnostics[0].Code)
+	require.Equal(t, InvalidIdCode, report.Diagnostics[1].Code)
+	require.Equal(t, uint64(ids.MaxOsmId+1), report.Diagnostics[1].OsmId)
+}
+
+func TestConvertSharedWall(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	// Two terraced houses that share the wall between nodes 2 and 3
+	left := &overpass.Way{
+		Id:    1,
+		Nodes: []uint64{1, 2, 3, 4, 1},
+		Geometry: []*overpass.LatLon{
+			{Lat: 0.1, Lon: 0.1}, {Lat: 0.1, Lon: 0.3}, {Lat: 0.3, Lon: 0.3}, {Lat: 0.3, Lon: 0.1}, {Lat: 0.1, Lon: 0.1},
+		},
+		Tags: &overpass.Tags{Building: "terrace"},
+	}
+	right := &overpass.Way{
+		Id:    2,
+		Nodes: []uint64{2, 5, 6, 3, 2},
+		Geometry: []*overpass.LatLon{
+			{Lat: 0.1, Lon: 0.3}, {Lat: 0.1, Lon: 0.5}, {Lat: 0.3, Lon: 0.5}, {Lat: 0.3, Lon: 0.3}, {Lat: 0.1, Lon: 0.3},
+		},
+		Tags: &overpass.Tags{Building: "terrace"},
+	}
+
+	requireUniqueIds := func(buildings []*world.Building) {
+		require.Len(t, buildings, 2)
+
+		nodes := make(map[world.Id]*world.Node)
+		for _, b := range buildings {
+			for _, n := range b.Points() {
+				if existing, ok := nodes[n.Id()]; ok {
+					require.Same(t, existing, n, "nodes with the same id should be the same node")
+				}
+
+				nodes[n.Id()] = n
+			}
+		}
+
+		require.Len(t, nodes, 6)
+	}
+
+	w, _, err := Convert(metadata, &overpass.Result{Elements: []*overpass.Way{left, right}})
+	require.NoError(t, err)
+	requireUniqueIds(w.Buildings())
+
+	// Buildings converted in different shards are only joined up when they are merged
+	first, err := convertBuildings(metadata, []*overpass.Way{left})
+	require.NoError(t, err)
+	second, err := convertBuildings(metadata, []*overpass.Way{right})
+	require.NoError(t, err)
+
+	merged := newBuildingOutput(2)
+	merged.merge(first)
+	merged.merge(second)
+	requireUniqueIds(merged.buildings)
+	require.Same(t, first.buildings[0], merged.buildings[0], "buildings without shared nodes should be kept as they are")
+}
@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Decides what happens to elements that can't be converted
+type Mode int
+
+const (
+	// Elements that can't be converted are left out of the world and described in the report
+	Lenient Mode = iota
+	// The first element that can't be converted fails the whole conversion
+	Strict
+)
+
+type Options struct {
+	Mode        Mode
+	Classifiers []Classifier
+	Handlers    map[WayType]Handler
+	// Goroutines used to convert buildings, where values less than 1 are treated as 1
+	Workers int
+}
+
+type Option func(o *Options)
+
+func WithMode(mode Mode) Option {
+	return func(o *Options) {
+		o.Mode = mode
+	}
+}
+
+func WithWorkers(workers int) Option {
+	return func(o *Options) {
+		o.Workers = workers
+	}
+}
+
+// Adds a classifier that runs before the default classifier and any classifiers added after it
+func WithClassifier(classifier Classifier) Option {
+	return func(o *Options) {
+		o.Classifiers = append(o.Classifiers, classifier)
+	}
+}
+
+// Registers the handler for ways of a custom type, replacing any earlier handler for it
+func WithHandler(t WayType, handler Handler) Option {
+	return func(o *Options) {
+		if o.Handlers == nil {
+			o.Handlers = make(map[WayType]Handler)
+		}
+
+		o.Handlers[t] = handler
+	}
+}
+
+func (o *Options) checkHandlers() error {
+	for t := range o.Handlers {
+		if t.builtIn() {
+			return fmt.Errorf("way type %s is converted by Convert and can't have a handler", t)
+		}
+	}
+
+	return nil
+}
+
+// The custom types with a handler in ascending order so that handlers run in the same order every time
+func (o *Options) handledTypes() []WayType {
+	types := make([]WayType, 0, len(o.Handlers))
+	for t := range o.Handlers {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types
+}
+
+// Returned in strict mode for the first element that would have been left out of the world
+type ElementError struct {
+	Diagnostic *Diagnostic
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("%s %d: %s: %s", e.Diagnostic.ElementType, e.Diagnostic.OsmId, e.Diagnostic.Code,
+		e.Diagnostic.Message)
+}
+
+// Returns an error for the first rejected element when converting in strict mode
+func (o *Options) check(diagnostics []*Diagnostic) error {
+	if o.Mode != Strict {
+		return nil
+	}
+
+	for _, d := range diagnostics {
+		// Overpass returns every way with some of the tags, not just the values that are classified
+		if d.Severity == Error && d.Code != UnclassifiedCode {
+			return &ElementError{Diagnostic: d}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConvertModes(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	result := &overpass.Result{
+		Elements: []*overpass.Way{
+			{
+				Id:       0,
+				Nodes:    []uint64{0, 1},
+				Geometry: []*overpass.LatLon{{Lat: 0.0, Lon: 0.0}, {Lat: 0.5, Lon: 0.5}},
+				Tags:     &overpass.Tags{Highway: "primary"},
+			},
+			{
+				Id:       1,
+				Nodes:    []uint64{2, 3, 4, 2},
+				Geometry: []*overpass.LatLon{{Lat: 0.6, Lon: 0.6}, {Lat: 1.0, Lon: 0.6}},
+				Tags:     &overpass.Tags{Building: "yes"},
+			},
+		},
+	}
+
+	for _, options := range [][]Option{nil, {WithMode(Lenient)}} {
+		w, report, err := Convert(metadata, result, options...)
+		require.NoError(t, err)
+		require.Len(t, w.Roads(), 2)
+		require.Empty(t, w.Buildings(), "the malformed building should be skipped")
+		require.Len(t, report.AtLeast(Error), 1)
+		require.Equal(t, uint64(1), report.Diagnostics[0].OsmId)
+		require.Equal(t, MalformedCode, report.Diagnostics[0].Code)
+	}
+
+	w, report, err := Convert(metadata, result, WithMode(Strict))
+	require.Nil(t, w)
+	require.Nil(t, report)
+	require.IsType(t, &ElementError{}, err)
+	require.Equal(t, uint64(1), err.(*ElementError).Diagnostic.OsmId)
+	require.Equal(t, "way 1: malformed: way rejected: geometry has 2 points for 4 nodes",
+		err.Error())
+
+	// Only rejected elements fail a strict conversion
+	result.Elements[1] = &overpass.Way{
+		Id:    1,
+		Nodes: []uint64{2, 3, 4, 2},
+		Geometry: []*overpass.LatLon{
+			{Lat: 0.6, Lon: 0.6}, {Lat: 1.0, Lon: 0.6}, {Lat: 1.0, Lon: 1.0}, {Lat: 0.6, Lon: 0.6},
+		},
+		Tags: &overpass.Tags{Building: "yes", Height: "tall"},
+	}
+	w, report, err = Convert(metadata, result, WithMode(Strict))
+	require.NoError(t, err)
+	require.Len(t, w.Buildings(), 1)
+	require.Len(t, report.AtLeast(Warning), 1)
+
+	// Well formed ways that aren't classified are skipped rather than failing a strict conversion
+	result.Elements = append(result.Elements, &overpass.Way{
+		Id:       2,
+		Nodes:    []uint64{5, 6},
+		Geometry: []*overpass.LatLon{{Lat: 0.2, Lon: 0.8}, {Lat: 0.4, Lon: 0.8}},
+		Tags:     &overpass.Tags{Natural: "tree_row"},
+	})
+	w, report, err = Convert(metadata, result, WithMode(Strict))
+	require.NoError(t, err)
+	require.Len(t, w.Roads(), 2)
+	require.Empty(t, w.Lines)
+	require.Equal(t, 1, report.Summary().ByCode[UnclassifiedCode])
+}
+
+func TestConvertWithClassifier(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	square := []*overpass.LatLon{
+		{Lat: 0.1, Lon: 0.1}, {Lat: 0.1, Lon: 0.4}, {Lat: 0.4, Lon: 0.4}, {Lat: 0.4, Lon: 0.1}, {Lat: 0.1, Lon: 0.1},
+	}
+	result := &overpass.Result{
+		Elements: []*overpass.Way{
+			{Id: 0, Nodes: []uint64{0, 1, 2, 3, 0}, Geometry: square, Tags: &overpass.Tags{Amenity: "parking"}},
+			{Id: 1, Nodes: []uint64{4, 5, 6, 7, 4}, Geometry: square, Tags: &overpass.Tags{Building: "roof"}},
+		},
+	}
+
+	w, report, err := Convert(metadata, result)
+	require.NoError(t, err)
+	require.Empty(t, w.Areas)
+	require.Len(t, w.Buildings(), 1)
+	require.Len(t, report.Diagnostics, 1, "parking isn't classified by default")
+
+	plots := ClassifierFunc(func(w *overpass.Way) (*Classification, error) {
+		if w.Tags.Amenity == "parking" {
+			return &Classification{Type: AreaType, AreaClass: layers.CommercialArea}, nil
+		} else if w.Tags.Building == "roof" {
+			return &Classification{Type: IgnoredType}, nil
+		}
+
+		return nil, nil
+	})
+
+	w, report, err = Convert(metadata, result, WithClassifier(plots))
+	require.NoError(t, err)
+	require.Len(t, w.Areas, 1)
+	require.Equal(t, layers.CommercialArea, w.Areas[0].Class)
+	require.Empty(t, w.Buildings())
+	require.Empty(t, report.Diagnostics)
+}
+
+func TestConvertWithHandler(t *testing.T) {
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	// A car park as Overpass returns it for the way[amenity] part of the query
+	body := `{"elements":[{"type":"way","id":7,"nodes":[1,2,3,4,1],"geometry":[{"lat":0.1,"lon":0.1},` +
+		`{"lat":0.1,"lon":0.4},{"lat":0.4,"lon":0.4},{"lat":0.4,"lon":0.1},{"lat":0.1,"lon":0.1}],` +
+		`"tags":{"amenity":"parking"}}]}`
+	result := new(overpass.Result)
+	require.NoError(t, json.Unmarshal([]byte(body), result))
+
+	const plotType WayType = 100
+	classified := make([]*overpass.Way, 0)
+	plots := ClassifierFunc(func(w *overpass.Way) (*Classification, error) {
+		if w.Tags.Amenity != "" {
+			classified = append(classified, w)
+		}
+
+		if w.Tags.Amenity == "parking" {
+			return &Classification{Type: plotType}, nil
+		}
+
+		return nil, nil
+	})
+
+	handled := make([]*overpass.Way, 0)
+	handler := HandlerFunc(func(w *layers.World, ways []*overpass.Way) ([]*Diagnostic, error) {
+		handled = append(handled, ways...)
+		w.Areas = append(w.Areas, &layers.Area{OsmId: ways[0].Id, Class: layers.CommercialArea})
+		return nil, nil
+	})
+
+	w, report, err := Convert(metadata, result, WithClassifier(plots), WithHandler(plotType, handler))
+	require.NoError(t, err)
+	require.Equal(t, []*overpass.Way{result.Elements[0]}, classified, "the classifier should receive the car park")
+	require.Equal(t, []*overpass.Way{result.Elements[0]}, handled)
+	require.Len(t, w.Areas, 1)
+	require.Empty(t, report.Diagnostics)
+
+	// Custom types without a handler are rejected
+	w, report, err = Convert(metadata, result, WithClassifier(plots))
+	require.NoError(t, err)
+	require.Empty(t, w.Areas)
+	require.Len(t, report.Diagnostics, 1)
+	require.Equal(t, UnclassifiedCode, report.Diagnostics[0].Code)
+	require.Equal(t, "way rejected: classified as custom 100 which has no handler", report.Diagnostics[0].Message)
+
+	_, _, err = Convert(metadata, result, WithHandler(AreaType, handler))
+	require.Error(t, err, "built-in types can't have a handler")
+
+	// Diagnostics from handlers are checked like any other
+	rejecting := HandlerFunc(func(w *layers.World, ways []*overpass.Way) ([]*Diagnostic, error) {
+		return []*Diagnostic{rejectedDiagnostic(WayElement, ways[0].Id, InvalidTagCode, "plot", "too small")}, nil
+	})
+
+	_, _, err = Convert(metadata, result, WithClassifier(plots), WithHandler(plotType, rejecting), WithMode(Strict))
+	require.IsType(t, &ElementError{}, err)
+
+	_, _, err = Convert(metadata, result, WithClassifier(plots), WithHandler(plotType, HandlerFunc(
+		func(w *layers.World, ways []*overpass.Way) ([]*Diagnostic, error) {
+			return nil, errors.New("failed")
+		})))
+	require.Error(t, err)
+}
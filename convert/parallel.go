@@ -0,0 +1,52 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/overpass"
+	"sync"
+)
+
+// Building elements converted at a time. Shards are merged in order so the output doesn't depend on the workers.
+const buildingShardSize = 512
+
+// Converts the buildings in fixed size shards that are spread across the workers
+func convertBuildingsConcurrently(metadata *world.Metadata, buildingElements []*overpass.Way, workers int) (o *buildingOutput, err error) {
+	if buildingElements == nil {
+		return nil, errors.New("building elements be nil")
+	}
+
+	shardCount := (len(buildingElements) + buildingShardSize - 1) / buildingShardSize
+	outputs := make([]*buildingOutput, shardCount)
+	errs := make([]error, shardCount)
+
+	shards := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < maxInt(1, minInt(workers, shardCount)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				end := minInt((shard+1)*buildingShardSize, len(buildingElements))
+				outputs[shard], errs[shard] = convertBuildings(metadata, buildingElements[shard*buildingShardSize:end])
+			}
+		}()
+	}
+
+	for shard := 0; shard < shardCount; shard++ {
+		shards <- shard
+	}
+	close(shards)
+	wg.Wait()
+
+	output := newBuildingOutput(len(buildingElements))
+	for shard, shardOutput := range outputs {
+		if errs[shard] != nil {
+			return nil, errs[shard]
+		}
+
+		output.merge(shardOutput)
+	}
+
+	return output, nil
+}
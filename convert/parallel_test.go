@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertBuildingsConcurrently(t *testing.T) {
+	metadata := world.NewMetadata(10000, 10000, 0.0, 0.0, 1.0, 1.0)
+
+	_, err := convertBuildingsConcurrently(metadata, nil, 4)
+	require.Error(t, err, "nil building elements should error")
+
+	result := testutil.GridCity(25, 1.0, 2)
+	elements := separate(result.Elements, nil, nil, []Classifier{DefaultClassifier}, nil).buildings
+	require.True(t, len(elements) > 3*buildingShardSize, "there should be several shards")
+
+	// Repeat a building in a later shard so that duplicates have to be found across shards
+	elements = append(elements, elements[0])
+
+	expected, err := convertBuildings(metadata, elements)
+	require.NoError(t, err)
+	require.Equal(t, DuplicateIdCode, expected.diagnostics[len(expected.diagnostics)-1].Code)
+
+	for _, workers := range []int{0, 1, 3, 16} {
+		output, err := convertBuildingsConcurrently(metadata, elements, workers)
+		require.NoError(t, err)
+		require.Equal(t, expected, output, "%d workers should give the same output as converting in one go", workers)
+	}
+}
+
+func TestConvertWorkers(t *testing.T) {
+	metadata := world.NewMetadata(10000, 10000, 0.0, 0.0, 1.0, 1.0)
+	result := testutil.GridCity(25, 1.0, 2)
+
+	expected, expectedReport, err := Convert(metadata, result, WithWorkers(1))
+	require.NoError(t, err)
+
+	w, report, err := Convert(metadata, result, WithWorkers(8))
+	require.NoError(t, err)
+	require.Equal(t, expected, w)
+	require.Equal(t, expectedReport, report)
+}
+
+// Records a fixture from the live Overpass API when set to <city>:<lat1>,<lon1>,<lat2>,<lon2>, for example
+// go test ./convert -run TestRecordFixture -record "lisbon:38.705,-9.15,38.72,-9.13"
+var record = flag.String("record", "", "record an Overpass response into testdata as <city>:<lat1>,<lon1>,<lat2>,<lon2>")
+
+func TestRecordFixture(t *testing.T) {
+	if *record == "" {
+		t.Skip("no fixture to record")
+	}
+
+	parts := strings.SplitN(*record, ":", 2)
+	require.Len(t, parts, 2, "fixtures are recorded as <city>:<lat1>,<lon1>,<lat2>,<lon2>")
+
+	var lat1, lon1, lat2, lon2 float64
+	_, err := fmt.Sscanf(parts[1], "%g,%g,%g,%g", &lat1, &lon1, &lat2, &lon2)
+	require.NoError(t, err)
+
+	result, err := overpass.ExecuteQuery(lat1, lon1, lat2, lon2)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll("testdata", 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join("testdata", parts[0]+".json"), data, 0644))
+}
+
+// Loads Overpass responses recorded with TestRecordFixture. Recorded fixtures are OpenStreetMap data under the ODbL, so
+// they are kept out of the repository and only used for local benchmarking.
+func loadFixtures(t require.TestingT) map[string]*overpass.Result {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	require.NoError(t, err)
+
+	fixtures := make(map[string]*overpass.Result, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+
+		result := new(overpass.Result)
+		require.NoError(t, json.Unmarshal(data, result))
+		fixtures[strings.TrimSuffix(filepath.Base(path), ".json")] = result
+	}
+
+	return fixtures
+}
+
+// The smallest bounds that contain every way in the result
+func fixtureBounds(result *overpass.Result) *overpass.Bounds {
+	bounds := &overpass.Bounds{MinLat: math.Inf(1), MinLon: math.Inf(1), MaxLat: math.Inf(-1), MaxLon: math.Inf(-1)}
+	for _, w := range result.Elements {
+		for _, p := range w.Geometry {
+			bounds.MinLat, bounds.MaxLat = math.Min(bounds.MinLat, p.Lat), math.Max(bounds.MaxLat, p.Lat)
+			bounds.MinLon, bounds.MaxLon = math.Min(bounds.MinLon, p.Lon), math.Max(bounds.MaxLon, p.Lon)
+		}
+	}
+
+	return bounds
+}
+
+func TestConvertFixtures(t *testing.T) {
+	fixtures := loadFixtures(t)
+	if len(fixtures) == 0 {
+		t.Skip("no recorded fixtures in testdata, see TestRecordFixture")
+	}
+
+	for name, result := range fixtures {
+		metadata, err := MetadataFromMaxDimension(fixtureBounds(result), 10000)
+		require.NoError(t, err)
+
+		expected, expectedReport, err := Convert(metadata, result, WithWorkers(1))
+		require.NoError(t, err, name)
+		require.NotEmpty(t, expected.Buildings(), name)
+		require.NotEmpty(t, expected.Roads(), name)
+
+		w, report, err := Convert(metadata, result, WithWorkers(4))
+		require.NoError(t, err, name)
+		require.Equal(t, expected, w, "%s should convert the same with several workers", name)
+		require.Equal(t, expectedReport, report, name)
+	}
+}
+
+func benchmarkConvert(b *testing.B, metadata *world.Metadata, result *overpass.Result) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _, err := Convert(metadata, result, WithWorkers(workers))
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkConvertSyntheticCity(b *testing.B) {
+	// 40000 buildings and 20000 streets
+	benchmarkConvert(b, world.NewMetadata(20000, 20000, 0.0, 0.0, 1.0, 1.0), testutil.GridCity(100, 1.0, 2))
+}
+
+func BenchmarkConvertFixtures(b *testing.B) {
+	fixtures := loadFixtures(b)
+	if len(fixtures) == 0 {
+		b.Skip("no recorded fixtures in testdata, see TestRecordFixture")
+	}
+
+	for name, result := range fixtures {
+		metadata, err := MetadataFromMaxDimension(fixtureBounds(result), 10000)
+		require.NoError(b, err)
+
+		b.Run(name, func(b *testing.B) {
+			benchmarkConvert(b, metadata, result)
+		})
+	}
+}
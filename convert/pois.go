@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+)
+
+var poiAmenities = map[string]layers.PoiCategory{
+	"restaurant":       layers.FoodPoi,
+	"cafe":             layers.FoodPoi,
+	"fast_food":        layers.FoodPoi,
+	"pub":              layers.FoodPoi,
+	"bar":              layers.FoodPoi,
+	"ice_cream":        layers.FoodPoi,
+	"bus_station":      layers.TransportPoi,
+	"taxi":             layers.TransportPoi,
+	"bicycle_rental":   layers.TransportPoi,
+	"ferry_terminal":   layers.TransportPoi,
+	"fuel":             layers.TransportPoi,
+	"hospital":         layers.HealthPoi,
+	"clinic":           layers.HealthPoi,
+	"doctors":          layers.HealthPoi,
+	"dentist":          layers.HealthPoi,
+	"pharmacy":         layers.HealthPoi,
+	"school":           layers.EducationPoi,
+	"kindergarten":     layers.EducationPoi,
+	"college":          layers.EducationPoi,
+	"university":       layers.EducationPoi,
+	"library":          layers.EducationPoi,
+	"police":           layers.EmergencyPoi,
+	"fire_station":     layers.EmergencyPoi,
+	"townhall":         layers.CivicPoi,
+	"post_office":      layers.CivicPoi,
+	"courthouse":       layers.CivicPoi,
+	"community_centre": layers.CivicPoi,
+	"place_of_worship": layers.ReligiousPoi,
+	"bank":             layers.FinancePoi,
+	"atm":              layers.FinancePoi,
+	"bureau_de_change": layers.FinancePoi,
+	"cinema":           layers.LeisurePoi,
+	"theatre":          layers.LeisurePoi,
+	"arts_centre":      layers.LeisurePoi,
+	"nightclub":        layers.LeisurePoi,
+	"fountain":         layers.WaterPoi,
+	"drinking_water":   layers.WaterPoi,
+}
+
+// Sorts the tags of a node into a point of interest category, checking the most specific tags first
+func poiCategory(tags *overpass.Tags) (c layers.PoiCategory, value string, ok bool) {
+	if tags == nil {
+		return 0, "", false
+	}
+
+	switch {
+	case tags.Amenity != "":
+		return poiAmenities[tags.Amenity], tags.Amenity, true
+	case tags.Shop != "":
+		return layers.ShopPoi, tags.Shop, true
+	case tags.Highway == "bus_stop":
+		return layers.TransportPoi, tags.Highway, true
+	case tags.Tourism != "":
+		return layers.LeisurePoi, tags.Tourism, true
+	case tags.Historic != "":
+		return layers.HistoricPoi, tags.Historic, true
+	case tags.Natural == "tree":
+		return layers.TreePoi, tags.Natural, true
+	}
+
+	return 0, "", false
+}
+
+func convertPois(metadata *world.Metadata, nodeElements []*overpass.Node) (p []*layers.Poi, d []*Diagnostic, err error) {
+	if nodeElements == nil {
+		return nil, nil, errors.New("node elements cannot be nil")
+	}
+
+	toGameCoords, _, err := world.CreateConverters(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pois := make([]*layers.Poi, 0, len(nodeElements))
+	diagnostics := make([]*Diagnostic, 0)
+	for _, e := range nodeElements {
+		if e == nil {
+			continue
+		}
+
+		category, value, ok := poiCategory(e.Tags)
+		if !ok {
+			// Nodes that aren't landmarks are only useful as part of a way
+			continue
+		}
+
+		if problems := e.Validate(); len(problems) > 0 {
+			diagnostics = append(diagnostics, rejectedDiagnostic(NodeElement, e.Id, MalformedCode, "point of interest",
+				validationMessage(problems)))
+			continue
+		}
+
+		id, err := ids.New(ids.PoiNamespace, e.Id, world.NodeType)
+		if err != nil {
+			diagnostics = append(diagnostics, rejectedDiagnostic(NodeElement, e.Id, InvalidIdCode, "point of interest", err.Error()))
+			continue
+		}
+
+		x, y := toGameCoords(e.Lat, e.Lon)
+		pois = append(pois, &layers.Poi{
+			OsmId:    e.Id,
+			Category: category,
+			Type:     value,
+			Name:     e.Tags.Name,
+			Node:     world.NewNode(id, x, y),
+		})
+	}
+
+	return pois, diagnostics, nil
+}
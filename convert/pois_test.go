@@ -0,0 +1,83 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/overpass"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestPoiCategory(t *testing.T) {
+	test := func(tags *overpass.Tags, expected layers.PoiCategory, expectedValue string, expectedOk bool) {
+		c, value, ok := poiCategory(tags)
+		require.Equal(t, expectedOk, ok)
+		require.Equal(t, expected, c)
+		require.Equal(t, expectedValue, value)
+	}
+
+	test(nil, 0, "", false)
+	test(&overpass.Tags{}, 0, "", false)
+	test(&overpass.Tags{Amenity: "cafe"}, layers.FoodPoi, "cafe", true)
+	test(&overpass.Tags{Amenity: "bench"}, layers.OtherPoi, "bench", true)
+	test(&overpass.Tags{Amenity: "pharmacy", Shop: "chemist"}, layers.HealthPoi, "pharmacy", true)
+	test(&overpass.Tags{Shop: "bakery"}, layers.ShopPoi, "bakery", true)
+	test(&overpass.Tags{Highway: "bus_stop"}, layers.TransportPoi, "bus_stop", true)
+	test(&overpass.Tags{Highway: "crossing"}, 0, "", false)
+	test(&overpass.Tags{Tourism: "museum"}, layers.LeisurePoi, "museum", true)
+	test(&overpass.Tags{Historic: "memorial"}, layers.HistoricPoi, "memorial", true)
+	test(&overpass.Tags{Natural: "tree"}, layers.TreePoi, "tree", true)
+	test(&overpass.Tags{Natural: "peak"}, 0, "", false)
+}
+
+func TestConvertPois(t *testing.T) {
+	_, _, err := convertPois(nil, []*overpass.Node{})
+	require.Error(t, err, "nil metadata should error")
+
+	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
+
+	_, _, err = convertPois(metadata, nil)
+	require.Error(t, err, "nil node elements should error")
+
+	nodeElements := []*overpass.Node{
+		{Id: 0, Lat: 0.1, Lon: 0.2, Tags: &overpass.Tags{Amenity: "bank", Name: "First Bank"}},
+		{Id: 1, Lat: 0.3, Lon: 0.4, Tags: &overpass.Tags{Natural: "tree"}},
+		{Id: 2, Lat: 0.5, Lon: 0.6, Tags: &overpass.Tags{}},
+		{Id: 3, Lat: 0.5, Lon: 0.6},
+	}
+
+	pois, diagnostics, err := convertPois(metadata, []*overpass.Node{{Id: 1 << 48, Tags: &overpass.Tags{Shop: "bakery"}}})
+	require.NoError(t, err)
+	require.Empty(t, pois)
+	require.Len(t, diagnostics, 1, "ids that are too large should be rejected")
+	require.Equal(t, InvalidIdCode, diagnostics[0].Code)
+	require.Equal(t, NodeElement, diagnostics[0].ElementType)
+
+	pois, diagnostics, err = convertPois(metadata, []*overpass.Node{
+		{Id: 4, Lat: math.NaN(), Lon: 0.2, Tags: &overpass.Tags{Shop: "bakery"}},
+		{Id: 5, Lat: 0.1, Lon: 200, Tags: &overpass.Tags{Shop: "bakery"}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, pois)
+	require.Len(t, diagnostics, 2, "nodes that aren't at a real coordinate should be rejected")
+	require.Equal(t, MalformedCode, diagnostics[0].Code)
+	require.Equal(t, uint64(5), diagnostics[1].OsmId)
+
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
+		require.NoError(t, err)
+		return id
+	}
+
+	expectedPois := []*layers.Poi{
+		{OsmId: 0, Category: layers.FinancePoi, Type: "bank", Name: "First Bank", Node: world.NewNode(makeId(ids.PoiNamespace, 0, world.NodeType), 20, 10)},
+		{OsmId: 1, Category: layers.TreePoi, Type: "tree", Node: world.NewNode(makeId(ids.PoiNamespace, 1, world.NodeType), 40, 30)},
+	}
+
+	pois, diagnostics, err = convertPois(metadata, nodeElements)
+	require.NoError(t, err)
+	require.Equal(t, expectedPois, pois)
+	require.Empty(t, diagnostics)
+}
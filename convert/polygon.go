@@ -0,0 +1,156 @@
+package convert
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+)
+
+var (
+	errTooFewPoints     = errors.New("polygon has fewer than 3 distinct non-collinear points")
+	errZeroArea         = errors.New("polygon has zero area")
+	errSelfIntersecting = errors.New("polygon intersects itself")
+)
+
+// Drops the closing point along with duplicate and collinear points and winds the ring counter-clockwise. Removed
+// doesn't count the closing point.
+func normalizeRing(points []*world.Node) (ring []*world.Node, removed int, err error) {
+	ring = make([]*world.Node, len(points))
+	copy(ring, points)
+
+	// OSM closes a way by repeating the first node at the end
+	if len(ring) > 1 && samePoint(ring[0], ring[len(ring)-1]) {
+		ring = ring[:len(ring)-1]
+	}
+	unclosedLength := len(ring)
+
+	// Removing a point can make its neighbours collinear so keep going until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(ring) && len(ring) >= 3; i++ {
+			prev := ring[(i+len(ring)-1)%len(ring)]
+			next := ring[(i+1)%len(ring)]
+
+			// A zero cross product covers duplicate points, points along a straight edge and spikes that double back
+			if cross(prev, ring[i], next) == 0 {
+				ring = append(ring[:i], ring[i+1:]...)
+				changed = true
+				i--
+			}
+		}
+	}
+
+	if len(ring) < 3 {
+		return nil, 0, errTooFewPoints
+	}
+
+	if selfIntersecting(ring) {
+		return nil, 0, errSelfIntersecting
+	}
+
+	area := signedArea(ring)
+	if area == 0 {
+		return nil, 0, errZeroArea
+	}
+
+	if area < 0 {
+		reverseRing(ring)
+	}
+
+	return ring, unclosedLength - len(ring), nil
+}
+
+func samePoint(a, b *world.Node) bool {
+	return a.X() == b.X() && a.Y() == b.Y()
+}
+
+// The z component of (b - a) x (c - b), which is positive for a left turn
+func cross(a, b, c *world.Node) int {
+	return (b.X()-a.X())*(c.Y()-b.Y()) - (b.Y()-a.Y())*(c.X()-b.X())
+}
+
+// Twice the signed area of the ring, which is positive when it is counter-clockwise
+func signedArea(ring []*world.Node) int {
+	area := 0
+	for i, p := range ring {
+		next := ring[(i+1)%len(ring)]
+		area += p.X()*next.Y() - next.X()*p.Y()
+	}
+
+	return area
+}
+
+// Reverses the winding of the ring while keeping the first point in place
+func reverseRing(ring []*world.Node) {
+	for i, j := 1, len(ring)-1; i < j; i, j = i+1, j-1 {
+		ring[i], ring[j] = ring[j], ring[i]
+	}
+}
+
+func selfIntersecting(ring []*world.Node) bool {
+	n := len(ring)
+	for i := 0; i < n; i++ {
+		a1, a2 := ring[i], ring[(i+1)%n]
+		for j := i + 1; j < n; j++ {
+			// Adjacent edges always share a point
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue
+			}
+
+			if segmentsIntersect(a1, a2, ring[j], ring[(j+1)%n]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func segmentsIntersect(p1, p2, q1, q2 *world.Node) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if d1 != d2 && d3 != d4 && d1 != 0 && d2 != 0 && d3 != 0 && d4 != 0 {
+		return true
+	}
+
+	// Touching or overlapping segments also count as an intersection
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
+
+func orientation(a, b, c *world.Node) int {
+	v := (b.X()-a.X())*(c.Y()-a.Y()) - (b.Y()-a.Y())*(c.X()-a.X())
+	if v > 0 {
+		return 1
+	} else if v < 0 {
+		return -1
+	}
+
+	return 0
+}
+
+// Whether p is within the bounds of the segment from a to b, assuming all three are collinear
+func onSegment(a, b, p *world.Node) bool {
+	return minInt(a.X(), b.X()) <= p.X() && p.X() <= maxInt(a.X(), b.X()) &&
+		minInt(a.Y(), b.Y()) <= p.Y() && p.Y() <= maxInt(a.Y(), b.Y())
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
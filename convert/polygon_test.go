@@ -0,0 +1,61 @@
+package convert
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeRing(t *testing.T) {
+	nodes := func(coords ...int) []*world.Node {
+		points := make([]*world.Node, 0, len(coords)/2)
+		for i := 0; i < len(coords); i += 2 {
+			points = append(points, world.NewNode(world.Id(i/2), coords[i], coords[i+1]))
+		}
+
+		return points
+	}
+
+	test := func(points []*world.Node, expected []*world.Node, expectedRemoved int, expectedErr error, msg string) {
+		ring, removed, err := normalizeRing(points)
+		if expectedErr != nil {
+			require.Equal(t, expectedErr, err, msg)
+			require.Nil(t, ring, msg)
+		} else {
+			require.NoError(t, err, msg)
+			require.Equal(t, expected, ring, msg)
+			require.Equal(t, expectedRemoved, removed, msg)
+		}
+	}
+
+	square := nodes(0, 0, 10, 0, 10, 10, 0, 10)
+	test(square, square, 0, nil, "already normalized")
+
+	closed := nodes(0, 0, 10, 0, 10, 10, 0, 10, 0, 0)
+	test(closed, closed[:4], 0, nil, "closing point should be dropped")
+
+	clockwise := nodes(0, 0, 0, 10, 10, 10, 10, 0)
+	test(clockwise, []*world.Node{clockwise[0], clockwise[3], clockwise[2], clockwise[1]}, 0, nil, "should be wound counter-clockwise")
+
+	redundant := nodes(0, 0, 5, 0, 10, 0, 10, 0, 10, 10, 0, 10, 0, 5)
+	test(redundant, []*world.Node{redundant[0], redundant[3], redundant[4], redundant[5]}, 3, nil,
+		"collinear and duplicate points should be removed")
+
+	spike := nodes(0, 0, 10, 0, 20, 0, 10, 0, 10, 10)
+	test(spike, []*world.Node{spike[0], spike[3], spike[4]}, 2, nil, "spikes should be removed")
+
+	test(nodes(), nil, 0, errTooFewPoints, "empty")
+	test(nodes(0, 0, 10, 10, 0, 0), nil, 0, errTooFewPoints, "two points")
+	test(nodes(0, 0, 5, 5, 10, 10), nil, 0, errTooFewPoints, "all points collinear")
+	test(nodes(0, 0, 10, 10, 10, 0, 0, 10), nil, 0, errSelfIntersecting, "bow tie")
+	test(nodes(0, 0, 10, 0, 10, 10, 5, 0, 0, 10), nil, 0, errSelfIntersecting, "touching edges")
+}
+
+func TestSignedArea(t *testing.T) {
+	ccw := []*world.Node{world.NewNode(0, 0, 0), world.NewNode(1, 4, 0), world.NewNode(2, 4, 3)}
+	require.Equal(t, 12, signedArea(ccw))
+
+	reverseRing(ccw)
+	require.Equal(t, -12, signedArea(ccw))
+	require.Equal(t, world.Id(0), ccw[0].Id(), "first point should stay in place")
+}
@@ -3,48 +3,60 @@ package convert
 import (
 	"errors"
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
 )
 
-func convertRoads(metadata *world.Metadata, roadElements []*overpass.Way) (roads []*world.Road, err error) {
+// Converts the highway ways into road nodes, each with the class of the most important way it is on
+func convertRoads(metadata *world.Metadata, roadElements []*overpass.Way) (roads []*world.Road, classes map[world.Id]layers.RoadClass, d []*Diagnostic, err error) {
 	if roadElements == nil {
-		return nil, errors.New("roadElements cannot be nil")
+		return nil, nil, nil, errors.New("roadElements cannot be nil")
 	}
 
 	toGameCoords, _, err := world.CreateConverters(metadata)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	roads = make([]*world.Road, 0, len(roadElements))
-	placedRoads := make(map[uint64]*world.Road)
+	classes = make(map[world.Id]layers.RoadClass, len(roadElements))
+	diagnostics := make([]*Diagnostic, 0)
+	placedRoads := make(map[world.Id]*world.Road)
+	nodes := newNodePool(ids.RoadNodeNamespace)
 
 	for _, e := range roadElements {
+		// Check the whole way up front so that a bad way doesn't leave half of its roads behind
+		wayNodes, err := convertNodes(toGameCoords, nodes, e.Nodes, e.Geometry)
+		if err != nil {
+			diagnostics = append(diagnostics, rejectedDiagnostic(WayElement, e.Id, nodesCode(err), "road", err.Error()))
+			continue
+		}
+
+		class := layers.UnknownRoad
+		if e.Tags != nil {
+			class = roadClass(e.Tags.Highway)
+		}
+
 		// Road segments will go from this node to the next in the array
 		var prevRoad *world.Road
-		for i, nodeId := range e.Nodes {
+		for _, node := range wayNodes {
 			// check if a road has already been placed for the node
-			r := placedRoads[nodeId]
+			r := placedRoads[node.Id()]
 
 			if r == nil {
-				roadNodeId, err := world.NewId(nodeId, world.NodeType)
-				if err != nil {
-					return nil, err
-				}
-
-				roadId, err := world.NewId(nodeId, world.RoadType)
-				if err != nil {
-					return nil, err
-				}
-
-				lat, lon := e.Geometry[i].Lat, e.Geometry[i].Lon
-				x, y := toGameCoords(lat, lon)
+				// The node id is valid so the road id with the same base is too
+				roadId, _ := world.NewId(node.Id().BaseId(), world.RoadType)
 
-				r = world.NewRoad(roadId, world.NewNode(roadNodeId, x, y))
-				placedRoads[nodeId] = r
+				r = world.NewRoad(roadId, node)
+				placedRoads[node.Id()] = r
 				roads = append(roads, r)
 			}
 
+			if current, ok := classes[r.Id()]; !ok || class.Importance() > current.Importance() {
+				classes[r.Id()] = class
+			}
+
 			// The will be no previous road to connect to on the first loop
 			if prevRoad != nil {
 				prevRoad.InitOperation(&world.RoadInitOperation{
@@ -60,5 +72,23 @@ func convertRoads(metadata *world.Metadata, roadElements []*overpass.Way) (roads
 		}
 	}
 
-	return roads, nil
+	return roads, classes, diagnostics, nil
+}
+
+// Groups the value of a highway tag
+func roadClass(highway string) layers.RoadClass {
+	switch highway {
+	case "motorway", "motorway_link", "trunk", "trunk_link":
+		return layers.MajorRoad
+	case "primary", "primary_link", "secondary", "secondary_link", "tertiary", "tertiary_link":
+		return layers.ArterialRoad
+	case "residential", "unclassified", "living_street", "road":
+		return layers.LocalRoad
+	case "service":
+		return layers.ServiceRoad
+	case "footway", "path", "cycleway", "pedestrian", "steps", "bridleway", "track":
+		return layers.PathRoad
+	default:
+		return layers.UnknownRoad
+	}
 }
@@ -2,18 +2,20 @@ package convert
 
 import (
 	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/overpass"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
 
 func TestConvertRoads(t *testing.T) {
-	_, err := convertRoads(nil, []*overpass.Way{})
+	_, _, _, err := convertRoads(nil, []*overpass.Way{})
 	require.Error(t, err, "nil metadata should error")
 
 	metadata := world.NewMetadata(100, 100, 0.0, 0.0, 1.0, 1.0)
 
-	_, err = convertRoads(metadata, nil)
+	_, _, _, err = convertRoads(metadata, nil)
 	require.Error(t, err, "nil road elements should error")
 
 	roadElements := []*overpass.Way{
@@ -47,23 +49,29 @@ func TestConvertRoads(t *testing.T) {
 				{0, 1.0},
 				{0.5, 1.0},
 			},
-			Tags: &overpass.Tags{Highway: "primary"},
+			Tags: &overpass.Tags{Highway: "footway"},
+		},
+		{
+			Id:       3,
+			Nodes:    []uint64{3, 6},
+			Geometry: []*overpass.LatLon{{Lat: 0.5, Lon: 1.0}},
+			Tags:     &overpass.Tags{Highway: "primary"},
 		},
 	}
 
-	makeId := func(baseId uint64, idType world.Type) world.Id {
-		id, err := world.NewId(baseId, idType)
+	makeId := func(namespace ids.Namespace, osmId uint64, idType world.Type) world.Id {
+		id, err := ids.New(namespace, osmId, idType)
 		require.NoError(t, err)
 		return id
 	}
 
 	expectedRoads := []*world.Road{
-		world.NewRoad(makeId(0, world.RoadType), world.NewNode(makeId(0, world.NodeType), 0, 0)),
-		world.NewRoad(makeId(1, world.RoadType), world.NewNode(makeId(1, world.NodeType), 0, 50)),
-		world.NewRoad(makeId(2, world.RoadType), world.NewNode(makeId(2, world.NodeType), 50, 50)),
-		world.NewRoad(makeId(3, world.RoadType), world.NewNode(makeId(3, world.NodeType), 100, 50)),
-		world.NewRoad(makeId(4, world.RoadType), world.NewNode(makeId(4, world.NodeType), 100, 100)),
-		world.NewRoad(makeId(5, world.RoadType), world.NewNode(makeId(5, world.NodeType), 100, 0)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 0, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 0, world.NodeType), 0, 0)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 1, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 1, world.NodeType), 0, 50)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 2, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 2, world.NodeType), 50, 50)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 3, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 3, world.NodeType), 100, 50)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 4, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 4, world.NodeType), 100, 100)),
+		world.NewRoad(makeId(ids.RoadNodeNamespace, 5, world.RoadType), world.NewNode(makeId(ids.RoadNodeNamespace, 5, world.NodeType), 100, 0)),
 	}
 
 	expectedRoads[0].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{expectedRoads[1]}})
@@ -73,10 +81,46 @@ func TestConvertRoads(t *testing.T) {
 	expectedRoads[4].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{expectedRoads[3]}})
 	expectedRoads[5].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{expectedRoads[3]}})
 
-	roads, err := convertRoads(metadata, roadElements)
+	expectedDiagnostics := []*Diagnostic{
+		{OsmId: 3, ElementType: WayElement, Code: MalformedCode, Severity: Error,
+			Message: "road rejected: number of nodes does not match the number of points in the geometry"},
+	}
+
+	roads, classes, diagnostics, err := convertRoads(metadata, roadElements)
 	require.NotNil(t, roads)
 	require.NoError(t, err)
+	require.Equal(t, expectedDiagnostics, diagnostics, "the malformed way should not add any roads")
 
 	// The order of the road connections could be different without breaking anything. This doesn't check that currently
 	require.ElementsMatch(t, expectedRoads, roads)
+
+	// The node shared with the primary road takes its class over the footway's
+	require.Equal(t, map[world.Id]layers.RoadClass{
+		makeId(ids.RoadNodeNamespace, 0, world.RoadType): layers.ArterialRoad,
+		makeId(ids.RoadNodeNamespace, 1, world.RoadType): layers.ArterialRoad,
+		makeId(ids.RoadNodeNamespace, 2, world.RoadType): layers.ArterialRoad,
+		makeId(ids.RoadNodeNamespace, 3, world.RoadType): layers.ArterialRoad,
+		makeId(ids.RoadNodeNamespace, 4, world.RoadType): layers.ArterialRoad,
+		makeId(ids.RoadNodeNamespace, 5, world.RoadType): layers.PathRoad,
+	}, classes)
+}
+
+func TestRoadClass(t *testing.T) {
+	tests := []struct {
+		highway  string
+		expected layers.RoadClass
+	}{
+		{"motorway", layers.MajorRoad},
+		{"trunk_link", layers.MajorRoad},
+		{"tertiary", layers.ArterialRoad},
+		{"residential", layers.LocalRoad},
+		{"service", layers.ServiceRoad},
+		{"footway", layers.PathRoad},
+		{"bus_guideway", layers.UnknownRoad},
+		{"", layers.UnknownRoad},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, roadClass(test.highway), test.highway)
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/ajstarks/svgo"
 	"github.com/real-life-td/game-core/world"
 	"github.com/real-life-td/world-generator/convert"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/real-life-td/world-generator/mutate"
 	"github.com/real-life-td/world-generator/overpass"
 	"log"
@@ -92,16 +93,74 @@ func example(w http.ResponseWriter, req *http.Request) {
 	println(time.Now().UnixNano())
 
 	// Convert the result into a world object
-	metadata := world.NewMetadata(1000, 1000, params.lat1, params.lon1, params.lat2, params.lon2)
-	world, err := convert.Convert(metadata, result)
+	bounds := &overpass.Bounds{MinLat: params.lat1, MinLon: params.lon1, MaxLat: params.lat2, MaxLon: params.lon2}
+	metadata, err := convert.MetadataFromMaxDimension(bounds, 1000)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintln(w, "Invalid query parameters: "+err.Error())
+		return
+	}
+
+	world, report, err := convert.Convert(metadata, result, convert.WithMode(convert.Lenient))
 	if err != nil {
 		w.Header().Set("Content-Type", "text/plain")
 		_, _ = fmt.Fprintln(w, "Internal error when executing converting: "+err.Error())
 		return
 	}
+
+	summary := report.Summary()
+	log.Printf("Conversion finished with %d diagnostics (%d errors, %d warnings)", summary.Total,
+		summary.BySeverity[convert.Error], summary.BySeverity[convert.Warning])
+	for _, d := range report.AtLeast(convert.Warning) {
+		log.Println(d)
+	}
 	println(time.Now().UnixNano())
 
-	mutate.InitBuildingConnections(world, 2.0)
+	repair, err := mutate.RepairConnectivity(world, mutate.WithBridgeDistance(10), mutate.WithKeepLargestOnly())
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintln(w, "Internal error when repairing roads: "+err.Error())
+		return
+	}
+
+	log.Printf("Found %d road components, added %d bridges and dropped %d roads", repair.ComponentsBefore,
+		len(repair.Bridges), len(repair.Dropped))
+
+	connections, err := mutate.InitBuildingConnections(world, mutate.WithMinConnections(1), mutate.WithMaxConnections(3),
+		mutate.WithTargetAverage(2.0))
+	if errors.Is(err, mutate.ErrTargetNotReached) {
+		log.Printf("%v, %d buildings are not connected", err, len(connections.Unconnected))
+	} else if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintln(w, "Internal error when connecting buildings: "+err.Error())
+		return
+	}
+
+	placement, err := mutate.PlaceSpawnsAndBase(world, mutate.WithMinPathLength(200), mutate.WithBuildingBase())
+	if errors.Is(err, mutate.ErrNoPlacement) {
+		log.Println(err)
+	} else if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintln(w, "Internal error when placing spawns: "+err.Error())
+		return
+	} else {
+		log.Printf("Placed %d spawns with paths of %v to the base", len(world.Spawns), placement.PathLengths)
+
+		if err := mutate.GenerateRoutes(world); err != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprintln(w, "Internal error when generating routes: "+err.Error())
+			return
+		}
+
+		towers, err := mutate.PlaceTowerSlots(world)
+		if err != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprintln(w, "Internal error when placing tower slots: "+err.Error())
+			return
+		}
+
+		log.Printf("Kept %d of %d tower slots", len(world.TowerSlots), towers.Candidates)
+	}
 
 	println(time.Now().UnixNano())
 
@@ -114,11 +173,53 @@ func example(w http.ResponseWriter, req *http.Request) {
 	s.End()
 }
 
-func renderContainer(s *svg.SVG, container *world.Container) {
+func renderContainer(s *svg.SVG, container *layers.World) {
+	for _, a := range container.Areas {
+		renderArea(s, a)
+	}
+
+	for _, l := range container.Lines {
+		renderLine(s, l)
+	}
+
 	renderRoads(s, container.Roads())
 
 	for _, b := range container.Buildings() {
 		renderBuilding(s, b)
+
+		for _, hole := range container.Holes[b.Id()] {
+			renderHole(s, hole)
+		}
+	}
+
+	for _, p := range container.Pois {
+		s.Circle(p.X(), p.Y(), 2, "fill:rgb(255,140,0)")
+	}
+
+	for _, route := range container.Routes {
+		x := make([]int, 0, len(route.Roads))
+		y := make([]int, 0, len(route.Roads))
+		for _, p := range route.Points() {
+			x = append(x, p.X())
+			y = append(y, p.Y())
+		}
+
+		s.Polyline(x, y, "fill:none;stroke-width:2;stroke:rgb(220,0,0);stroke-opacity:0.5")
+		for _, c := range route.ChokePoints {
+			s.Circle(c.X(), c.Y(), 5, "fill:none;stroke-width:2;stroke:rgb(220,0,0)")
+		}
+	}
+
+	for _, t := range container.TowerSlots {
+		s.Square(t.X()-3, t.Y()-3, 6, "fill:rgb(30,90,200);fill-opacity:0.7")
+	}
+
+	for _, r := range container.Spawns {
+		s.Circle(r.X(), r.Y(), 8, "fill:rgb(220,0,0)")
+	}
+
+	if container.Base != nil {
+		s.Circle(container.Base.Road.X(), container.Base.Road.Y(), 10, "fill:rgb(255,215,0);stroke:black")
 	}
 }
 
@@ -173,3 +274,56 @@ func renderBuilding(s *svg.SVG, building *world.Building) {
 		s.Line(c.Road().X(), c.Road().Y(), c.PointOnBuilding().X(), c.PointOnBuilding().Y(), "stroke-width:1;stroke:rgb(0,255,0);")
 	}
 }
+
+func renderHole(s *svg.SVG, hole []*world.Node) {
+	x := make([]int, 0, len(hole))
+	y := make([]int, 0, len(hole))
+
+	for _, p := range hole {
+		x = append(x, p.X())
+		y = append(y, p.Y())
+	}
+
+	s.Polygon(x, y, "fill:white")
+}
+
+func renderArea(s *svg.SVG, area *layers.Area) {
+	fill := "rgb(230,230,230)"
+	switch {
+	case area.Class.Impassable():
+		fill = "rgb(150,190,255)"
+	case area.Class == layers.ParkArea || area.Class == layers.GrassArea || area.Class == layers.ForestArea:
+		fill = "rgb(180,230,170)"
+	}
+
+	x := make([]int, 0, len(area.Outer))
+	y := make([]int, 0, len(area.Outer))
+
+	for _, p := range area.Outer {
+		x = append(x, p.X())
+		y = append(y, p.Y())
+	}
+
+	s.Polygon(x, y, "fill:"+fill)
+
+	for _, hole := range area.Holes {
+		renderHole(s, hole)
+	}
+}
+
+func renderLine(s *svg.SVG, line *layers.Line) {
+	style := "fill:none;stroke-width:2;stroke:rgb(90,90,90);stroke-dasharray:6,3"
+	if line.Class == layers.RiverLine || line.Class == layers.StreamLine {
+		style = "fill:none;stroke-width:4;stroke:rgb(150,190,255)"
+	}
+
+	x := make([]int, 0, len(line.Points))
+	y := make([]int, 0, len(line.Points))
+
+	for _, p := range line.Points {
+		x = append(x, p.X())
+		y = append(y, p.Y())
+	}
+
+	s.Polyline(x, y, style)
+}
@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/layers"
+	"math"
+)
+
+// A road node that is an intersection or a dead end, or one picked from a loop of roads that has neither
+type Node struct {
+	Road *world.Road
+	// Edges that loop back to the node are only included once
+	Edges []*Edge
+}
+
+// A chain of road nodes between two graph nodes
+type Edge struct {
+	From, To *Node
+	// Including both ends
+	Roads  []*world.Road
+	Length float64
+	// The least important class of the roads along the edge
+	Class layers.RoadClass
+}
+
+// The road network with the chains between intersections and dead ends contracted into single edges
+type Graph struct {
+	Nodes []*Node
+	Edges []*Edge
+
+	nodes   map[*world.Road]*Node
+	through map[*world.Road]*Edge
+}
+
+// Builds the contracted graph of the world's roads
+func Contract(w *layers.World) *Graph {
+	return contract(w.Roads(), w.RoadClasses)
+}
+
+func contract(roads []*world.Road, classes map[world.Id]layers.RoadClass) *Graph {
+	neighbours := undirectedNeighbours(roads)
+
+	g := &Graph{
+		Nodes:   make([]*Node, 0),
+		Edges:   make([]*Edge, 0),
+		nodes:   make(map[*world.Road]*Node),
+		through: make(map[*world.Road]*Edge),
+	}
+
+	for _, r := range roads {
+		if len(neighbours[r]) != 2 {
+			g.addNode(r)
+		}
+	}
+
+	// Each edge is walked once from one end. The first step from the other end is remembered so that it isn't walked
+	// again in the opposite direction.
+	type step struct{ from, to *world.Road }
+	walked := make(map[step]bool)
+
+	walkFrom := func(n *Node) {
+		for _, first := range neighbours[n.Road] {
+			if walked[step{n.Road, first}] {
+				continue
+			}
+
+			edge := &Edge{From: n, Roads: []*world.Road{n.Road}}
+			previous, current := n.Road, first
+			for g.nodes[current] == nil {
+				edge.Roads = append(edge.Roads, current)
+				g.through[current] = edge
+
+				next := neighbours[current][0]
+				if next == previous {
+					next = neighbours[current][1]
+				}
+
+				previous, current = current, next
+			}
+
+			edge.Roads = append(edge.Roads, current)
+			edge.To = g.nodes[current]
+			edge.Length = polylineLength(edge.Roads)
+			edge.Class = leastImportantClass(edge.Roads, classes)
+
+			walked[step{n.Road, first}] = true
+			walked[step{current, previous}] = true
+
+			g.Edges = append(g.Edges, edge)
+			n.Edges = append(n.Edges, edge)
+			if edge.To != n {
+				edge.To.Edges = append(edge.To.Edges, edge)
+			}
+		}
+	}
+
+	for _, n := range g.Nodes {
+		walkFrom(n)
+	}
+
+	// Whatever is left are loops of roads that only have two neighbours each
+	for _, r := range roads {
+		if g.nodes[r] == nil && g.through[r] == nil {
+			walkFrom(g.addNode(r))
+		}
+	}
+
+	return g
+}
+
+func (g *Graph) addNode(r *world.Road) *Node {
+	n := &Node{Road: r, Edges: make([]*Edge, 0)}
+	g.Nodes = append(g.Nodes, n)
+	g.nodes[r] = n
+	return n
+}
+
+// The graph node at the road node, or nil if the road node is part of an edge
+func (g *Graph) NodeAt(r *world.Road) *Node {
+	return g.nodes[r]
+}
+
+// The edge that passes through the road node, or nil if the road node is a graph node or isn't in the graph
+func (g *Graph) EdgeThrough(r *world.Road) *Edge {
+	return g.through[r]
+}
+
+func (e *Edge) Other(n *Node) *Node {
+	if e.From == n {
+		return e.To
+	}
+
+	return e.From
+}
+
+func (e *Edge) RoadIds() []world.Id {
+	ids := make([]world.Id, len(e.Roads))
+	for i, r := range e.Roads {
+		ids[i] = r.Id()
+	}
+
+	return ids
+}
+
+func (e *Edge) Points() []*primitives.Point {
+	points := make([]*primitives.Point, len(e.Roads))
+	for i, r := range e.Roads {
+		points[i] = r.Point
+	}
+
+	return points
+}
+
+// The neighbours of each road with connections followed both ways, leaving out roads that aren't in the list
+func undirectedNeighbours(roads []*world.Road) map[*world.Road][]*world.Road {
+	included := make(map[*world.Road]bool, len(roads))
+	for _, r := range roads {
+		included[r] = true
+	}
+
+	type pair struct{ a, b *world.Road }
+	seen := make(map[pair]bool)
+	neighbours := make(map[*world.Road][]*world.Road, len(roads))
+	add := func(a, b *world.Road) {
+		if a != b && !seen[pair{a, b}] {
+			seen[pair{a, b}] = true
+			neighbours[a] = append(neighbours[a], b)
+		}
+	}
+
+	for _, r := range roads {
+		for _, c := range r.Connections() {
+			if included[c] {
+				add(r, c)
+				add(c, r)
+			}
+		}
+	}
+
+	return neighbours
+}
+
+func polylineLength(roads []*world.Road) float64 {
+	length := 0.0
+	for i := 1; i < len(roads); i++ {
+		length += math.Hypot(float64(roads[i].X()-roads[i-1].X()), float64(roads[i].Y()-roads[i-1].Y()))
+	}
+
+	return length
+}
+
+func leastImportantClass(roads []*world.Road, classes map[world.Id]layers.RoadClass) layers.RoadClass {
+	least := classes[roads[0].Id()]
+	for _, r := range roads[1:] {
+		if c := classes[r.Id()]; c.Importance() < least.Importance() {
+			least = c
+		}
+	}
+
+	return least
+}
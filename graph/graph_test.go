@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	// A street from a to d with a dead end branching off at c, a loop on its own and a lone road node
+	a := world.NewRoad(0, world.NewNode(1, 0, 0))
+	b := world.NewRoad(2, world.NewNode(3, 10, 0))
+	c := world.NewRoad(4, world.NewNode(5, 20, 0))
+	d := world.NewRoad(6, world.NewNode(7, 30, 0))
+	e := world.NewRoad(8, world.NewNode(9, 20, 10))
+	f := world.NewRoad(10, world.NewNode(11, 20, 20))
+	g := world.NewRoad(12, world.NewNode(13, 100, 0))
+	h := world.NewRoad(14, world.NewNode(15, 110, 0))
+	i := world.NewRoad(16, world.NewNode(17, 110, 10))
+	j := world.NewRoad(18, world.NewNode(19, 200, 200))
+	testutil.Chain(a, b, c, d)
+	testutil.Chain(c, e, f)
+	testutil.Chain(g, h, i, g)
+
+	roads := []*world.Road{b, a, c, d, e, f, h, g, i, j}
+	w := layers.NewWorld(world.NewContainer(nil, roads, []*world.Building{}))
+	for _, r := range roads {
+		w.RoadClasses[r.Id()] = layers.LocalRoad
+	}
+	w.RoadClasses[b.Id()] = layers.ServiceRoad
+
+	graph := Contract(w)
+
+	require.Len(t, graph.Nodes, 6)
+	nodeA, nodeC, nodeD, nodeF, nodeJ, nodeH := graph.Nodes[0], graph.Nodes[1], graph.Nodes[2], graph.Nodes[3],
+		graph.Nodes[4], graph.Nodes[5]
+	require.Equal(t, []*world.Road{a, c, d, f, j, h}, []*world.Road{nodeA.Road, nodeC.Road, nodeD.Road, nodeF.Road,
+		nodeJ.Road, nodeH.Road}, "the loop should be given a node after the other nodes")
+
+	require.Len(t, graph.Edges, 4)
+	ab, cd, cf, loop := graph.Edges[0], graph.Edges[1], graph.Edges[2], graph.Edges[3]
+
+	require.Equal(t, &Edge{From: nodeA, To: nodeC, Roads: []*world.Road{a, b, c}, Length: 20, Class: layers.ServiceRoad}, ab)
+	require.Equal(t, &Edge{From: nodeC, To: nodeD, Roads: []*world.Road{c, d}, Length: 10, Class: layers.LocalRoad}, cd)
+	require.Equal(t, &Edge{From: nodeC, To: nodeF, Roads: []*world.Road{c, e, f}, Length: 20, Class: layers.LocalRoad}, cf)
+	require.Equal(t, []*world.Road{h, g, i, h}, loop.Roads)
+	require.Equal(t, nodeH, loop.From)
+	require.Equal(t, nodeH, loop.To)
+	require.InDelta(t, 20+math.Sqrt(200), loop.Length, 1e-9)
+
+	require.Equal(t, []*Edge{ab}, nodeA.Edges)
+	require.Equal(t, []*Edge{ab, cd, cf}, nodeC.Edges)
+	require.Equal(t, []*Edge{loop}, nodeH.Edges, "a loop should only be included once")
+	require.Empty(t, nodeJ.Edges)
+
+	// Mapping back to the roads
+	require.Equal(t, nodeC, graph.NodeAt(c))
+	require.Nil(t, graph.NodeAt(b))
+	require.Equal(t, ab, graph.EdgeThrough(b))
+	require.Equal(t, loop, graph.EdgeThrough(i))
+	require.Nil(t, graph.EdgeThrough(c))
+	require.Equal(t, []world.Id{0, 2, 4}, ab.RoadIds())
+	require.Equal(t, []*primitives.Point{a.Point, b.Point, c.Point}, ab.Points())
+	require.Equal(t, nodeA, ab.Other(nodeC))
+	require.Equal(t, nodeC, ab.Other(nodeA))
+}
+
+func TestContract_CoversEveryRoad(t *testing.T) {
+	random := rand.New(rand.NewSource(46))
+	for iteration := 0; iteration < 100; iteration++ {
+		roads := make([]*world.Road, random.Intn(60))
+		for i := range roads {
+			roads[i] = world.NewRoad(world.Id(2*i), world.NewNode(world.Id(2*i+1), random.Intn(100), random.Intn(100)))
+		}
+
+		// Some connections are only made in one direction
+		segments := make(map[[2]*world.Road]bool)
+		for k := 0; k < len(roads); k++ {
+			x, y := roads[random.Intn(len(roads))], roads[random.Intn(len(roads))]
+			if x == y || segments[[2]*world.Road{x, y}] {
+				continue
+			}
+
+			segments[[2]*world.Road{x, y}], segments[[2]*world.Road{y, x}] = true, true
+			x.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{y}})
+			if random.Intn(4) != 0 {
+				y.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{x}})
+			}
+		}
+
+		graph := contract(roads, nil)
+
+		// Every road node is either a graph node or inside exactly one edge
+		for _, r := range roads {
+			require.True(t, (graph.NodeAt(r) == nil) != (graph.EdgeThrough(r) == nil))
+			if e := graph.EdgeThrough(r); e != nil {
+				require.Contains(t, e.Roads[1:len(e.Roads)-1], r)
+			}
+		}
+
+		// Every segment is in exactly one edge so the lengths add up
+		total, contracted := 0.0, 0.0
+		for s := range segments {
+			total += polylineLength(s[:]) / 2
+		}
+
+		for _, e := range graph.Edges {
+			contracted += e.Length
+			require.Equal(t, e.From.Road, e.Roads[0])
+			require.Equal(t, e.To.Road, e.Roads[len(e.Roads)-1])
+			require.Contains(t, e.From.Edges, e)
+			require.Contains(t, e.To.Edges, e)
+		}
+
+		require.InDelta(t, total, contracted, 1e-6)
+	}
+}
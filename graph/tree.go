@@ -0,0 +1,95 @@
+package graph
+
+import "container/heap"
+
+// Every shortest path from a source node across the graph
+type Tree struct {
+	// In the order they were reached, starting with the source
+	Order []*Node
+	// Length of the shortest path to each node
+	Lengths map[*Node]float64
+	// Number of different shortest paths to each node
+	Counts map[*Node]float64
+	// Edges that arrive at each node along all of its shortest paths
+	Previous map[*Node][]*Edge
+}
+
+// Finds every shortest path from the source using Dijkstra's algorithm, following edges in both directions
+func ShortestTree(source *Node) *Tree {
+	tree := &Tree{
+		Order:    make([]*Node, 0),
+		Lengths:  map[*Node]float64{source: 0},
+		Counts:   map[*Node]float64{source: 1},
+		Previous: make(map[*Node][]*Edge),
+	}
+
+	done := make(map[*Node]bool)
+	queue := new(nodeQueue)
+	queue.push(source, 0)
+
+	for queue.Len() > 0 {
+		step := heap.Pop(queue).(*nodeStep)
+		cur := step.node
+		if done[cur] || step.length > tree.Lengths[cur] {
+			continue
+		}
+
+		done[cur] = true
+		tree.Order = append(tree.Order, cur)
+
+		for _, e := range cur.Edges {
+			next := e.Other(cur)
+			length := step.length + e.Length
+			known, ok := tree.Lengths[next]
+			switch {
+			case !ok || length < known:
+				tree.Lengths[next] = length
+				tree.Counts[next] = tree.Counts[cur]
+				tree.Previous[next] = []*Edge{e}
+				queue.push(next, length)
+			case length == known && !done[next]:
+				tree.Counts[next] += tree.Counts[cur]
+				tree.Previous[next] = append(tree.Previous[next], e)
+			}
+		}
+	}
+
+	return tree
+}
+
+type nodeStep struct {
+	node   *Node
+	length float64
+	// Breaks ties between steps with the same length so that searches are repeatable
+	order int
+}
+
+type nodeQueue struct {
+	steps []*nodeStep
+	count int
+}
+
+func (q *nodeQueue) push(node *Node, length float64) {
+	heap.Push(q, &nodeStep{node: node, length: length, order: q.count})
+	q.count++
+}
+
+func (q *nodeQueue) Len() int { return len(q.steps) }
+
+func (q *nodeQueue) Less(i, j int) bool {
+	if q.steps[i].length != q.steps[j].length {
+		return q.steps[i].length < q.steps[j].length
+	}
+
+	return q.steps[i].order < q.steps[j].order
+}
+
+func (q *nodeQueue) Swap(i, j int)      { q.steps[i], q.steps[j] = q.steps[j], q.steps[i] }
+func (q *nodeQueue) Push(x interface{}) { q.steps = append(q.steps, x.(*nodeStep)) }
+
+func (q *nodeQueue) Pop() interface{} {
+	n := len(q.steps)
+	x := q.steps[n-1]
+	q.steps = q.steps[:n-1]
+	return x
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestShortestTree(t *testing.T) {
+	// A dead end leading to a block with the same length around either side and another dead end past it
+	start := world.NewRoad(0, world.NewNode(1, 0, 0))
+	a := world.NewRoad(2, world.NewNode(3, 10, 0))
+	north := world.NewRoad(4, world.NewNode(5, 20, -10))
+	south := world.NewRoad(6, world.NewNode(7, 20, 10))
+	b := world.NewRoad(8, world.NewNode(9, 30, 0))
+	end := world.NewRoad(10, world.NewNode(11, 40, 0))
+	lonely := world.NewRoad(12, world.NewNode(13, 500, 500))
+	testutil.Chain(start, a, north, b, end)
+	testutil.Chain(a, south, b)
+
+	g := Contract(layers.NewWorld(world.NewContainer(nil,
+		[]*world.Road{start, a, north, south, b, end, lonely}, []*world.Building{})))
+	require.Len(t, g.Nodes, 5)
+
+	tree := ShortestTree(g.NodeAt(start))
+	require.Equal(t, []*Node{g.NodeAt(start), g.NodeAt(a), g.NodeAt(b), g.NodeAt(end)}, tree.Order,
+		"roads that can't be reached should be left out")
+
+	around := polylineLength([]*world.Road{a, north, b})
+	require.Equal(t, 10+around+10, tree.Lengths[g.NodeAt(end)])
+	require.Equal(t, 2.0, tree.Counts[g.NodeAt(b)], "there are two ways around the block")
+	require.Equal(t, 2.0, tree.Counts[g.NodeAt(end)])
+	require.Len(t, tree.Previous[g.NodeAt(b)], 2)
+	require.NotEqual(t, tree.Previous[g.NodeAt(b)][0], tree.Previous[g.NodeAt(b)][1])
+	for _, e := range tree.Previous[g.NodeAt(b)] {
+		require.Equal(t, g.NodeAt(a), e.Other(g.NodeAt(b)))
+	}
+
+	require.Empty(t, tree.Previous[g.NodeAt(start)])
+
+	tree = ShortestTree(g.NodeAt(lonely))
+	require.Equal(t, []*Node{g.NodeAt(lonely)}, tree.Order)
+}
@@ -0,0 +1,106 @@
+package ids
+
+import (
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+)
+
+// The kind of OSM element an id or a diagnostic refers to
+type ElementType string
+
+const (
+	NodeElement     ElementType = "node"
+	WayElement      ElementType = "way"
+	RelationElement ElementType = "relation"
+)
+
+// Which layer an id was created for. It is stored in the top bits of the base id so layers can't collide.
+type Namespace uint64
+
+const (
+	// Road ids and the ids of their nodes. This is the first namespace so road ids are the same as the OSM node id.
+	RoadNodeNamespace Namespace = iota
+	BuildingNodeNamespace
+	AreaNodeNamespace
+	LineNodeNamespace
+	PoiNamespace
+	// Buildings made from a single closed way
+	BuildingWayNamespace
+	// Buildings made from one of the outer rings of a multipolygon relation, identified by the first way in the ring
+	MultipolygonWayNamespace
+	// Road nodes added where a road was split after conversion. These are numbered from 0 instead of using an OSM id.
+	JunctionNamespace
+)
+
+// Number of bits of the base id used for the OSM id. The rest of the 48 bits allowed by world.NewId hold the namespace.
+const namespaceShift = 44
+
+// The largest OSM id that fits in a namespace
+const MaxOsmId = 1<<namespaceShift - 1
+
+func (n Namespace) String() string {
+	switch n {
+	case RoadNodeNamespace:
+		return "road node"
+	case BuildingNodeNamespace:
+		return "building node"
+	case AreaNodeNamespace:
+		return "area node"
+	case LineNodeNamespace:
+		return "line node"
+	case PoiNamespace:
+		return "point of interest"
+	case BuildingWayNamespace:
+		return "building way"
+	case MultipolygonWayNamespace:
+		return "multipolygon way"
+	case JunctionNamespace:
+		return "junction"
+	default:
+		return "unknown"
+	}
+}
+
+// The kind of OSM element that ids in the namespace are created from
+func (n Namespace) ElementType() ElementType {
+	switch n {
+	case BuildingWayNamespace, MultipolygonWayNamespace:
+		return WayElement
+	default:
+		return NodeElement
+	}
+}
+
+// The OSM element that an id was created from
+type OsmRef struct {
+	Namespace   Namespace
+	ElementType ElementType
+	OsmId       uint64
+}
+
+// Creates the id for an OSM element in the namespace, failing if the OSM id would spill into the namespace bits
+func New(namespace Namespace, osmId uint64, t world.Type) (world.Id, error) {
+	if osmId > MaxOsmId {
+		return 0, fmt.Errorf("osm id %d is larger than the maximum of %d", osmId, uint64(MaxOsmId))
+	}
+
+	return world.NewId(uint64(namespace)<<namespaceShift|osmId, t)
+}
+
+// Creates the road and node ids for the nth junction added to the roads
+func JunctionIds(n uint64) (roadId, nodeId world.Id, err error) {
+	roadId, err = New(JunctionNamespace, n, world.RoadType)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	nodeId, err = New(JunctionNamespace, n, world.NodeType)
+	return roadId, nodeId, err
+}
+
+// Finds the OSM element that an id created by the generator came from
+func Lookup(id world.Id) *OsmRef {
+	base := id.BaseId()
+	namespace := Namespace(base >> namespaceShift)
+	return &OsmRef{Namespace: namespace, ElementType: namespace.ElementType(), OsmId: base & MaxOsmId}
+}
@@ -0,0 +1,58 @@
+package ids
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewId(t *testing.T) {
+	roadNode, err := New(RoadNodeNamespace, 5, world.NodeType)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), roadNode.BaseId(), "road ids should match the OSM id")
+
+	buildingNode, err := New(BuildingNodeNamespace, 5, world.NodeType)
+	require.NoError(t, err)
+	require.NotEqual(t, roadNode, buildingNode)
+	require.Equal(t, world.NodeType, buildingNode.Type())
+
+	largest, err := New(MultipolygonWayNamespace, MaxOsmId, world.BuildingType)
+	require.NoError(t, err)
+	require.Equal(t, &OsmRef{Namespace: MultipolygonWayNamespace, ElementType: WayElement, OsmId: MaxOsmId}, Lookup(largest))
+
+	_, err = New(PoiNamespace, MaxOsmId+1, world.NodeType)
+	require.EqualError(t, err, "osm id 17592186044416 is larger than the maximum of 17592186044415")
+}
+
+func TestLookup(t *testing.T) {
+	namespaces := []Namespace{RoadNodeNamespace, BuildingNodeNamespace, AreaNodeNamespace, LineNodeNamespace, PoiNamespace,
+		BuildingWayNamespace, MultipolygonWayNamespace, JunctionNamespace}
+
+	seen := make(map[world.Id]bool)
+	for _, namespace := range namespaces {
+		id, err := New(namespace, 123, world.NodeType)
+		require.NoError(t, err)
+		require.False(t, seen[id], "%s ids should not collide with other namespaces", namespace)
+		seen[id] = true
+
+		ref := Lookup(id)
+		require.Equal(t, namespace, ref.Namespace)
+		require.Equal(t, namespace.ElementType(), ref.ElementType)
+		require.Equal(t, uint64(123), ref.OsmId)
+	}
+}
+
+func TestJunctionIds(t *testing.T) {
+	roadId, nodeId, err := JunctionIds(7)
+	require.NoError(t, err)
+	require.Equal(t, world.RoadType, roadId.Type())
+	require.Equal(t, world.NodeType, nodeId.Type())
+	require.Equal(t, &OsmRef{Namespace: JunctionNamespace, ElementType: NodeElement, OsmId: 7}, Lookup(roadId))
+
+	road, err := New(RoadNodeNamespace, 7, world.RoadType)
+	require.NoError(t, err)
+	require.NotEqual(t, road, roadId, "junctions should not collide with roads from OSM")
+
+	_, _, err = JunctionIds(MaxOsmId + 1)
+	require.Error(t, err)
+}
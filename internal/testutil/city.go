@@ -0,0 +1,64 @@
+package testutil
+
+import "github.com/real-life-td/world-generator/overpass"
+
+// Builds an Overpass result for a grid of blocks, size degrees across, with streets around every block and perSide by
+// perSide houses in each block
+func GridCity(blocks int, size float64, perSide int) *overpass.Result {
+	result := &overpass.Result{Elements: make([]*overpass.Way, 0, 2*blocks*(blocks+1)+blocks*blocks*perSide*perSide)}
+	step := size / float64(blocks)
+	plot := step / float64(perSide)
+
+	// Street intersections are shared between the streets that cross there
+	intersection := func(row, column int) uint64 {
+		return uint64(row*(blocks+1) + column + 1)
+	}
+
+	// Everything else gets an id after the last intersection
+	nextId := intersection(blocks, blocks)
+	id := func() uint64 {
+		nextId++
+		return nextId
+	}
+
+	street := func(fromRow, fromColumn, toRow, toColumn int) *overpass.Way {
+		return &overpass.Way{
+			Id:    id(),
+			Nodes: []uint64{intersection(fromRow, fromColumn), intersection(toRow, toColumn)},
+			Geometry: []*overpass.LatLon{
+				{Lat: float64(fromRow) * step, Lon: float64(fromColumn) * step},
+				{Lat: float64(toRow) * step, Lon: float64(toColumn) * step},
+			},
+			Tags: &overpass.Tags{Highway: "residential"},
+		}
+	}
+
+	for row := 0; row <= blocks; row++ {
+		for column := 0; column < blocks; column++ {
+			result.Elements = append(result.Elements, street(row, column, row, column+1), street(column, row, column+1, row))
+		}
+	}
+
+	for row := 0; row < blocks; row++ {
+		for column := 0; column < blocks; column++ {
+			for i := 0; i < perSide*perSide; i++ {
+				lat := float64(row)*step + (float64(i/perSide)+0.25)*plot
+				lon := float64(column)*step + (float64(i%perSide)+0.25)*plot
+				side := plot / 2
+
+				wayId, first := id(), id()
+				result.Elements = append(result.Elements, &overpass.Way{
+					Id:    wayId,
+					Nodes: []uint64{first, id(), id(), id(), first},
+					Geometry: []*overpass.LatLon{
+						{Lat: lat, Lon: lon}, {Lat: lat, Lon: lon + side}, {Lat: lat + side, Lon: lon + side},
+						{Lat: lat + side, Lon: lon}, {Lat: lat, Lon: lon},
+					},
+					Tags: &overpass.Tags{Building: "house", Levels: "2"},
+				})
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,11 @@
+package testutil
+
+import "github.com/real-life-td/game-core/world"
+
+// Joins each road to the one after it in both directions
+func Chain(roads ...*world.Road) {
+	for i := 1; i < len(roads); i++ {
+		roads[i].InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{roads[i-1]}})
+		roads[i-1].InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{roads[i]}})
+	}
+}
@@ -0,0 +1,70 @@
+package layers
+
+// Broad grouping of the OSM building values that gameplay cares about
+type BuildingClass int
+
+const (
+	UnknownBuilding BuildingClass = iota
+	ResidentialBuilding
+	CommercialBuilding
+	IndustrialBuilding
+	ReligiousBuilding
+	CivicBuilding
+	AgriculturalBuilding
+	// Sheds, garages, huts and other small structures
+	MinorBuilding
+)
+
+func (c BuildingClass) String() string {
+	switch c {
+	case ResidentialBuilding:
+		return "residential"
+	case CommercialBuilding:
+		return "commercial"
+	case IndustrialBuilding:
+		return "industrial"
+	case ReligiousBuilding:
+		return "religious"
+	case CivicBuilding:
+		return "civic"
+	case AgriculturalBuilding:
+		return "agricultural"
+	case MinorBuilding:
+		return "minor"
+	default:
+		return "unknown"
+	}
+}
+
+// The roof:shape values from OSM
+type RoofShape string
+
+const (
+	FlatRoof       RoofShape = "flat"
+	GabledRoof     RoofShape = "gabled"
+	HippedRoof     RoofShape = "hipped"
+	HalfHippedRoof RoofShape = "half-hipped"
+	PyramidalRoof  RoofShape = "pyramidal"
+	SkillionRoof   RoofShape = "skillion"
+	GambrelRoof    RoofShape = "gambrel"
+	MansardRoof    RoofShape = "mansard"
+	DomeRoof       RoofShape = "dome"
+	OnionRoof      RoofShape = "onion"
+	RoundRoof      RoofShape = "round"
+	OtherRoof      RoofShape = "other"
+)
+
+type BuildingAttributes struct {
+	// The raw value of the building tag, for example "yes", "house" or "church"
+	Type  string
+	Class BuildingClass
+
+	// Metres above the ground to the top of the roof and to the bottom of the building
+	Height    float64
+	MinHeight float64
+	Levels    int
+	RoofShape RoofShape
+
+	// Whether the height was made up because the building had no usable height or levels tags
+	HeightEstimated bool
+}
@@ -0,0 +1,259 @@
+package layers
+
+import (
+	"encoding/json"
+	"github.com/real-life-td/game-core/world"
+	"io"
+)
+
+// The JSON form of the world, kept in stored order so that a world is always written as the same bytes
+type exportedWorld struct {
+	Meta       *exportedMeta        `json:"meta"`
+	Roads      []*exportedRoad      `json:"roads"`
+	Buildings  []*exportedBuilding  `json:"buildings"`
+	Areas      []*exportedArea      `json:"areas"`
+	Lines      []*exportedLine      `json:"lines"`
+	Pois       []*exportedPoi       `json:"pois"`
+	Spawns     []world.Id           `json:"spawns"`
+	Base       *exportedBase        `json:"base,omitempty"`
+	Bridges    []*exportedBridge    `json:"bridges"`
+	Routes     []*exportedRoute     `json:"routes"`
+	TowerSlots []*exportedTowerSlot `json:"towerSlots"`
+}
+
+type exportedMeta struct {
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Lat1   float64 `json:"lat1"`
+	Lon1   float64 `json:"lon1"`
+	Lat2   float64 `json:"lat2"`
+	Lon2   float64 `json:"lon2"`
+}
+
+type exportedNode struct {
+	Id world.Id `json:"id"`
+	X  int      `json:"x"`
+	Y  int      `json:"y"`
+}
+
+type exportedRoad struct {
+	Id          world.Id      `json:"id"`
+	Class       string        `json:"class"`
+	Node        *exportedNode `json:"node"`
+	Connections []world.Id    `json:"connections"`
+}
+
+type exportedPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type exportedConnection struct {
+	Road     world.Id       `json:"road"`
+	Distance float64        `json:"distance"`
+	Point    *exportedPoint `json:"point,omitempty"`
+}
+
+type exportedAttributes struct {
+	Type            string  `json:"type"`
+	Class           string  `json:"class"`
+	Height          float64 `json:"height"`
+	MinHeight       float64 `json:"minHeight"`
+	Levels          int     `json:"levels"`
+	RoofShape       string  `json:"roofShape"`
+	HeightEstimated bool    `json:"heightEstimated"`
+}
+
+type exportedBuilding struct {
+	Id          world.Id              `json:"id"`
+	Points      []*exportedNode       `json:"points"`
+	Holes       [][]*exportedNode     `json:"holes,omitempty"`
+	Attributes  *exportedAttributes   `json:"attributes,omitempty"`
+	Connections []*exportedConnection `json:"connections"`
+}
+
+type exportedArea struct {
+	OsmId uint64            `json:"osmId"`
+	Class string            `json:"class"`
+	Outer []*exportedNode   `json:"outer"`
+	Holes [][]*exportedNode `json:"holes,omitempty"`
+}
+
+type exportedLine struct {
+	OsmId  uint64          `json:"osmId"`
+	Class  string          `json:"class"`
+	Points []*exportedNode `json:"points"`
+}
+
+type exportedPoi struct {
+	OsmId    uint64        `json:"osmId"`
+	Category string        `json:"category"`
+	Type     string        `json:"type"`
+	Name     string        `json:"name,omitempty"`
+	Node     *exportedNode `json:"node"`
+	Building *world.Id     `json:"building,omitempty"`
+}
+
+type exportedBase struct {
+	Road     world.Id  `json:"road"`
+	Building *world.Id `json:"building,omitempty"`
+}
+
+type exportedBridge struct {
+	From world.Id `json:"from"`
+	To   world.Id `json:"to"`
+}
+
+type exportedRoute struct {
+	Spawn       world.Id         `json:"spawn"`
+	Roads       []world.Id       `json:"roads"`
+	Points      []*exportedPoint `json:"points"`
+	Length      float64          `json:"length"`
+	ChokePoints []world.Id       `json:"chokePoints"`
+}
+
+type exportedTowerSlot struct {
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Kind     string    `json:"kind"`
+	Building *world.Id `json:"building,omitempty"`
+	Score    float64   `json:"score"`
+}
+
+// Writes the whole world as a single JSON object
+func (w *World) WriteJSON(writer io.Writer) error {
+	meta := w.Meta()
+	exported := &exportedWorld{
+		Roads:      make([]*exportedRoad, 0, len(w.Roads())),
+		Buildings:  make([]*exportedBuilding, 0, len(w.Buildings())),
+		Areas:      make([]*exportedArea, 0, len(w.Areas)),
+		Lines:      make([]*exportedLine, 0, len(w.Lines)),
+		Pois:       make([]*exportedPoi, 0, len(w.Pois)),
+		Spawns:     make([]world.Id, 0, len(w.Spawns)),
+		Bridges:    make([]*exportedBridge, 0, len(w.Bridges)),
+		Routes:     make([]*exportedRoute, 0, len(w.Routes)),
+		TowerSlots: make([]*exportedTowerSlot, 0, len(w.TowerSlots)),
+	}
+
+	if meta != nil {
+		exported.Meta = &exportedMeta{Width: meta.Width(), Height: meta.Height(), Lat1: meta.Lat1(), Lon1: meta.Lon1(),
+			Lat2: meta.Lat2(), Lon2: meta.Lon2()}
+	}
+
+	for _, r := range w.Roads() {
+		connections := make([]world.Id, 0, len(r.Connections()))
+		for _, c := range r.Connections() {
+			connections = append(connections, c.Id())
+		}
+
+		exported.Roads = append(exported.Roads, &exportedRoad{Id: r.Id(), Class: w.RoadClasses[r.Id()].String(),
+			Node: exportNode(r.Node), Connections: connections})
+	}
+
+	for _, b := range w.Buildings() {
+		building := &exportedBuilding{Id: b.Id(), Points: exportRing(b.Points()), Connections: make([]*exportedConnection, 0)}
+		for _, hole := range w.Holes[b.Id()] {
+			building.Holes = append(building.Holes, exportRing(hole))
+		}
+
+		if a := w.Attributes[b.Id()]; a != nil {
+			building.Attributes = &exportedAttributes{Type: a.Type, Class: a.Class.String(), Height: a.Height,
+				MinHeight: a.MinHeight, Levels: a.Levels, RoofShape: string(a.RoofShape), HeightEstimated: a.HeightEstimated}
+		}
+
+		for _, c := range b.Connections() {
+			building.Connections = append(building.Connections, exportConnection(c))
+		}
+
+		exported.Buildings = append(exported.Buildings, building)
+	}
+
+	for _, a := range w.Areas {
+		area := &exportedArea{OsmId: a.OsmId, Class: a.Class.String(), Outer: exportRing(a.Outer)}
+		for _, hole := range a.Holes {
+			area.Holes = append(area.Holes, exportRing(hole))
+		}
+
+		exported.Areas = append(exported.Areas, area)
+	}
+
+	for _, l := range w.Lines {
+		exported.Lines = append(exported.Lines, &exportedLine{OsmId: l.OsmId, Class: l.Class.String(), Points: exportRing(l.Points)})
+	}
+
+	for _, p := range w.Pois {
+		poi := &exportedPoi{OsmId: p.OsmId, Category: p.Category.String(), Type: p.Type, Name: p.Name, Node: exportNode(p.Node)}
+		if p.Building != nil {
+			id := p.Building.Id()
+			poi.Building = &id
+		}
+
+		exported.Pois = append(exported.Pois, poi)
+	}
+
+	for _, r := range w.Spawns {
+		exported.Spawns = append(exported.Spawns, r.Id())
+	}
+
+	if w.Base != nil {
+		exported.Base = &exportedBase{Road: w.Base.Road.Id()}
+		if w.Base.Building != nil {
+			id := w.Base.Building.Id()
+			exported.Base.Building = &id
+		}
+	}
+
+	for _, b := range w.Bridges {
+		exported.Bridges = append(exported.Bridges, &exportedBridge{From: b.From.Id(), To: b.To.Id()})
+	}
+
+	for _, r := range w.Routes {
+		route := &exportedRoute{Spawn: r.Spawn.Id(), Roads: make([]world.Id, 0, len(r.Roads)),
+			Points: make([]*exportedPoint, 0, len(r.Roads)), Length: r.Length,
+			ChokePoints: make([]world.Id, 0, len(r.ChokePoints))}
+		for _, road := range r.Roads {
+			route.Roads = append(route.Roads, road.Id())
+			route.Points = append(route.Points, &exportedPoint{X: road.X(), Y: road.Y()})
+		}
+
+		for _, road := range r.ChokePoints {
+			route.ChokePoints = append(route.ChokePoints, road.Id())
+		}
+
+		exported.Routes = append(exported.Routes, route)
+	}
+
+	for _, s := range w.TowerSlots {
+		slot := &exportedTowerSlot{X: s.X(), Y: s.Y(), Kind: s.Kind.String(), Score: s.Score}
+		if s.Building != nil {
+			id := s.Building.Id()
+			slot.Building = &id
+		}
+
+		exported.TowerSlots = append(exported.TowerSlots, slot)
+	}
+
+	return json.NewEncoder(writer).Encode(exported)
+}
+
+func exportNode(n *world.Node) *exportedNode {
+	return &exportedNode{Id: n.Id(), X: n.X(), Y: n.Y()}
+}
+
+func exportRing(nodes []*world.Node) []*exportedNode {
+	exported := make([]*exportedNode, 0, len(nodes))
+	for _, n := range nodes {
+		exported = append(exported, exportNode(n))
+	}
+
+	return exported
+}
+
+func exportConnection(c *world.Connection) *exportedConnection {
+	connection := &exportedConnection{Road: c.Road().Id(), Distance: c.Distance()}
+	if p := c.PointOnBuilding(); p != nil {
+		connection.Point = &exportedPoint{X: p.X(), Y: p.Y()}
+	}
+
+	return connection
+}
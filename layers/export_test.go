@@ -0,0 +1,61 @@
+package layers
+
+import (
+	"bytes"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWorld_WriteJSON(t *testing.T) {
+	r1 := world.NewRoad(1, world.NewNode(1, 0, 0))
+	r2 := world.NewRoad(2, world.NewNode(2, 10, 0))
+	r1.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r2}})
+	r2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r1}})
+
+	b := world.NewBuilding(3, []*world.Node{world.NewNode(4, 0, 5), world.NewNode(5, 5, 5), world.NewNode(6, 5, 10)})
+	b.InitOperation(&world.BuildingInitOperation{
+		NewConnections: []*world.Connection{world.NewConnection(r1, 5, primitives.NewPoint(0, 5))},
+	})
+
+	w := NewWorld(world.NewContainer(world.NewMetadata(10, 10, 0, 0, 1, 1), []*world.Road{r1, r2}, []*world.Building{b}))
+	w.Attributes[b.Id()] = &BuildingAttributes{Type: "house", Class: ResidentialBuilding, Height: 6, Levels: 2,
+		RoofShape: GabledRoof}
+	w.RoadClasses[r1.Id()] = LocalRoad
+	w.Lines = append(w.Lines, &Line{OsmId: 7, Class: RiverLine, Points: []*world.Node{world.NewNode(8, 0, 9), world.NewNode(9, 9, 9)}})
+	w.Pois = append(w.Pois, &Poi{OsmId: 10, Category: ShopPoi, Type: "bakery", Node: world.NewNode(10, 4, 6), Building: b})
+	w.Spawns = append(w.Spawns, r2)
+	w.Base = &Base{Road: r1, Building: b}
+	w.Bridges = append(w.Bridges, &Bridge{From: r2, To: r1})
+	w.TowerSlots = append(w.TowerSlots, &TowerSlot{Point: primitives.NewPoint(3, 7), Kind: RooftopSlot, Building: b, Score: 4.5},
+		&TowerSlot{Point: primitives.NewPoint(8, 2), Kind: FrontageSlot})
+	w.Routes = append(w.Routes, &Route{Spawn: r2, Roads: []*world.Road{r2, r1}, Length: 10, ChokePoints: []*world.Road{}})
+
+	var buffer bytes.Buffer
+	require.NoError(t, w.WriteJSON(&buffer))
+	require.JSONEq(t, `{
+		"meta": {"width": 10, "height": 10, "lat1": 0, "lon1": 0, "lat2": 1, "lon2": 1},
+		"roads": [
+			{"id": 1, "class": "local", "node": {"id": 1, "x": 0, "y": 0}, "connections": [2]},
+			{"id": 2, "class": "unknown", "node": {"id": 2, "x": 10, "y": 0}, "connections": [1]}
+		],
+		"buildings": [{
+			"id": 3,
+			"points": [{"id": 4, "x": 0, "y": 5}, {"id": 5, "x": 5, "y": 5}, {"id": 6, "x": 5, "y": 10}],
+			"attributes": {"type": "house", "class": "residential", "height": 6, "minHeight": 0, "levels": 2,
+				"roofShape": "gabled", "heightEstimated": false},
+			"connections": [{"road": 1, "distance": 5, "point": {"x": 0, "y": 5}}]
+		}],
+		"areas": [],
+		"lines": [{"osmId": 7, "class": "river", "points": [{"id": 8, "x": 0, "y": 9}, {"id": 9, "x": 9, "y": 9}]}],
+		"pois": [{"osmId": 10, "category": "shop", "type": "bakery", "node": {"id": 10, "x": 4, "y": 6}, "building": 3}],
+		"spawns": [2],
+		"base": {"road": 1, "building": 3},
+		"bridges": [{"from": 2, "to": 1}],
+		"routes": [{"spawn": 2, "roads": [2, 1], "points": [{"x": 10, "y": 0}, {"x": 0, "y": 0}], "length": 10,
+			"chokePoints": []}],
+		"towerSlots": [{"x": 3, "y": 7, "kind": "rooftop", "building": 3, "score": 4.5},
+			{"x": 8, "y": 2, "kind": "frontage", "score": 0}]
+	}`, buffer.String())
+}
@@ -0,0 +1,160 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+)
+
+// What kind of land an area covers
+type AreaClass int
+
+const (
+	// Land use that isn't covered by one of the other classes
+	OtherArea AreaClass = iota
+	WaterArea
+	WetlandArea
+	ParkArea
+	ForestArea
+	GrassArea
+	FarmlandArea
+	ResidentialArea
+	CommercialArea
+	IndustrialArea
+	RailwayArea
+	CemeteryArea
+)
+
+func (c AreaClass) String() string {
+	switch c {
+	case WaterArea:
+		return "water"
+	case WetlandArea:
+		return "wetland"
+	case ParkArea:
+		return "park"
+	case ForestArea:
+		return "forest"
+	case GrassArea:
+		return "grass"
+	case FarmlandArea:
+		return "farmland"
+	case ResidentialArea:
+		return "residential"
+	case CommercialArea:
+		return "commercial"
+	case IndustrialArea:
+		return "industrial"
+	case RailwayArea:
+		return "railway"
+	case CemeteryArea:
+		return "cemetery"
+	default:
+		return "other"
+	}
+}
+
+// Whether nothing can move across the area
+func (c AreaClass) Impassable() bool {
+	return c == WaterArea
+}
+
+// Whether the open ground in the area can be built on
+func (c AreaClass) Buildable() bool {
+	switch c {
+	case WaterArea, WetlandArea, ForestArea, RailwayArea, CemeteryArea:
+		return false
+	default:
+		return true
+	}
+}
+
+// What kind of feature a line follows
+type LineClass int
+
+const (
+	// Rivers and canals
+	RiverLine LineClass = iota
+	// Streams, ditches and drains that are small enough to step over
+	StreamLine
+	RailLine
+	TramLine
+)
+
+func (c LineClass) String() string {
+	switch c {
+	case RiverLine:
+		return "river"
+	case StreamLine:
+		return "stream"
+	case RailLine:
+		return "rail"
+	case TramLine:
+		return "tram"
+	default:
+		return "unknown"
+	}
+}
+
+// Whether nothing can move across the line
+func (c LineClass) Impassable() bool {
+	return c == RiverLine
+}
+
+type Area struct {
+	// The id of the way or relation the area was converted from
+	OsmId uint64
+	Class AreaClass
+
+	// Wound counter-clockwise, with the holes clockwise
+	Outer []*world.Node
+	Holes [][]*world.Node
+}
+
+// Whether the point is inside the area and not inside one of its holes
+func (a *Area) Contains(p *primitives.Point) bool {
+	if !RingContains(a.Outer, p) {
+		return false
+	}
+
+	for _, hole := range a.Holes {
+		if RingContains(hole, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Finds the areas that contain a point, looking only at the areas whose bounds contain it
+type AreaIndex struct {
+	areas []*Area
+	grid  *rectangleGrid
+}
+
+func NewAreaIndex(areas []*Area) *AreaIndex {
+	bounds := make([]*primitives.Rectangle, len(areas))
+	for i, a := range areas {
+		bounds[i] = ringBounds(a.Outer)
+	}
+
+	return &AreaIndex{areas: areas, grid: newRectangleGrid(bounds)}
+}
+
+// Same as World.AreasAt for the indexed areas
+func (i *AreaIndex) At(p *primitives.Point) []*Area {
+	found := make([]*Area, 0)
+	for _, position := range i.grid.at(p) {
+		if i.areas[position].Contains(p) {
+			found = append(found, i.areas[position])
+		}
+	}
+
+	return found
+}
+
+type Line struct {
+	// The id of the way the line was converted from
+	OsmId  uint64
+	Class  LineClass
+	Points []*world.Node
+}
@@ -0,0 +1,71 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func TestAreaClass(t *testing.T) {
+	require.True(t, WaterArea.Impassable())
+	require.False(t, ParkArea.Impassable())
+
+	require.True(t, ParkArea.Buildable())
+	require.True(t, GrassArea.Buildable())
+	require.True(t, OtherArea.Buildable())
+	require.False(t, WaterArea.Buildable())
+	require.False(t, ForestArea.Buildable())
+
+	require.Equal(t, "water", WaterArea.String())
+	require.Equal(t, "other", AreaClass(-1).String())
+}
+
+func TestLineClass(t *testing.T) {
+	require.True(t, RiverLine.Impassable())
+	require.False(t, StreamLine.Impassable())
+	require.False(t, RailLine.Impassable())
+
+	require.Equal(t, "tram", TramLine.String())
+	require.Equal(t, "unknown", LineClass(-1).String())
+}
+
+func TestArea_Contains(t *testing.T) {
+	a := &Area{
+		Class: WaterArea,
+		Outer: []*world.Node{world.NewNode(0, 0, 0), world.NewNode(1, 30, 0), world.NewNode(2, 30, 30), world.NewNode(3, 0, 30)},
+		Holes: [][]*world.Node{
+			{world.NewNode(4, 10, 10), world.NewNode(5, 10, 20), world.NewNode(6, 20, 20), world.NewNode(7, 20, 10)},
+		},
+	}
+
+	require.True(t, a.Contains(primitives.NewPoint(5, 5)))
+	require.False(t, a.Contains(primitives.NewPoint(15, 15)), "islands are not part of the lake")
+	require.False(t, a.Contains(primitives.NewPoint(35, 5)))
+}
+
+func TestAreaIndex_At(t *testing.T) {
+	random := rand.New(rand.NewSource(50))
+	areas := make([]*Area, 100)
+	for i := range areas {
+		x, y, size := random.Intn(500), random.Intn(500), 1+random.Intn(100)
+		id := world.Id(4 * i)
+		areas[i] = &Area{Class: AreaClass(random.Intn(4)), Outer: []*world.Node{
+			world.NewNode(id, x+size, y),
+			world.NewNode(id+1, x+size, y+size),
+			world.NewNode(id+2, x, y+size),
+			world.NewNode(id+3, x, y),
+		}}
+	}
+
+	w := NewWorld(world.NewContainer(nil, nil, nil))
+	w.Areas = areas
+	index := NewAreaIndex(areas)
+	for i := 0; i < 1000; i++ {
+		p := primitives.NewPoint(random.Intn(700)-50, random.Intn(700)-50)
+		require.Equal(t, w.AreasAt(p), index.At(p))
+	}
+
+	require.Empty(t, NewAreaIndex(nil).At(primitives.NewPoint(0, 0)))
+}
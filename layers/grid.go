@@ -0,0 +1,80 @@
+package layers
+
+import (
+	"github.com/real-life-td/math/primitives"
+	"math"
+)
+
+// Uniform grid for finding the rectangles that contain a point
+type rectangleGrid struct {
+	rectangles       []*primitives.Rectangle
+	originX, originY int
+	cellSize         int
+	columns, rows    int
+	cells            [][]int
+}
+
+// Picks a cell size that puts about four of the rectangles in each cell if they were spread evenly
+func newRectangleGrid(rectangles []*primitives.Rectangle) *rectangleGrid {
+	g := &rectangleGrid{rectangles: rectangles, cellSize: 1, columns: 1, rows: 1}
+	if len(rectangles) > 0 {
+		minX, minY, maxX, maxY := math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32
+		for _, r := range rectangles {
+			minX, minY = minInt(minX, r.X1()), minInt(minY, r.Y1())
+			maxX, maxY = maxInt(maxX, r.X2()), maxInt(maxY, r.Y2())
+		}
+
+		area := float64(maxX-minX+1) * float64(maxY-minY+1)
+		g.cellSize = maxInt(1, int(math.Ceil(math.Sqrt(area*4/float64(len(rectangles))))))
+		g.originX, g.originY = minX, minY
+		g.columns, g.rows = (maxX-minX)/g.cellSize+1, (maxY-minY)/g.cellSize+1
+	}
+
+	g.cells = make([][]int, g.columns*g.rows)
+	for i, r := range rectangles {
+		for row := g.row(r.Y1()); row <= g.row(r.Y2()); row++ {
+			for column := g.column(r.X1()); column <= g.column(r.X2()); column++ {
+				cell := row*g.columns + column
+				g.cells[cell] = append(g.cells[cell], i)
+			}
+		}
+	}
+
+	return g
+}
+
+// Positions of the rectangles that contain the point, from first to last
+func (g *rectangleGrid) at(p *primitives.Point) []int {
+	found := make([]int, 0)
+	for _, i := range g.cells[g.row(p.Y())*g.columns+g.column(p.X())] {
+		if g.rectangles[i].ContainsPoint(p) {
+			found = append(found, i)
+		}
+	}
+
+	return found
+}
+
+func (g *rectangleGrid) column(x int) int {
+	return minInt(maxInt((x-g.originX)/g.cellSize, 0), g.columns-1)
+}
+
+func (g *rectangleGrid) row(y int) int {
+	return minInt(maxInt((y-g.originY)/g.cellSize, 0), g.rows-1)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
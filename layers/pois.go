@@ -0,0 +1,79 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+)
+
+// The kind of landmark a point of interest is
+type PoiCategory int
+
+const (
+	OtherPoi PoiCategory = iota
+	// Restaurants, cafes, pubs and other places to eat or drink
+	FoodPoi
+	ShopPoi
+	// Bus stops, stations and other places to catch a ride
+	TransportPoi
+	HealthPoi
+	EducationPoi
+	// Police and fire stations
+	EmergencyPoi
+	// Town halls, post offices and other public services
+	CivicPoi
+	ReligiousPoi
+	FinancePoi
+	// Cinemas, museums, attractions and other places to visit
+	LeisurePoi
+	HistoricPoi
+	// Fountains, drinking water and other places to find water
+	WaterPoi
+	TreePoi
+)
+
+func (c PoiCategory) String() string {
+	switch c {
+	case FoodPoi:
+		return "food"
+	case ShopPoi:
+		return "shop"
+	case TransportPoi:
+		return "transport"
+	case HealthPoi:
+		return "health"
+	case EducationPoi:
+		return "education"
+	case EmergencyPoi:
+		return "emergency"
+	case CivicPoi:
+		return "civic"
+	case ReligiousPoi:
+		return "religious"
+	case FinancePoi:
+		return "finance"
+	case LeisurePoi:
+		return "leisure"
+	case HistoricPoi:
+		return "historic"
+	case WaterPoi:
+		return "water"
+	case TreePoi:
+		return "tree"
+	default:
+		return "other"
+	}
+}
+
+type Poi struct {
+	// The id of the node the point of interest was converted from
+	OsmId    uint64
+	Category PoiCategory
+
+	// The raw value of the tag that gave the category, for example "cafe" or "bakery"
+	Type string
+	Name string
+
+	*world.Node
+
+	// The building that the point of interest sits inside of, or nil if it's out in the open
+	Building *world.Building
+}
@@ -0,0 +1,13 @@
+package layers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPoiCategory_String(t *testing.T) {
+	require.Equal(t, "food", FoodPoi.String())
+	require.Equal(t, "tree", TreePoi.String())
+	require.Equal(t, "other", OtherPoi.String())
+	require.Equal(t, "other", PoiCategory(-1).String())
+}
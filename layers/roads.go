@@ -0,0 +1,54 @@
+package layers
+
+// Broad grouping of the OSM highway values
+type RoadClass int
+
+const (
+	// Highway values that aren't covered by one of the other classes
+	UnknownRoad RoadClass = iota
+	// Motorways and trunk roads along with their links
+	MajorRoad
+	// Primary, secondary and tertiary roads along with their links
+	ArterialRoad
+	// Residential, unclassified and living streets
+	LocalRoad
+	// Service roads, driveways and parking aisles
+	ServiceRoad
+	// Footways, paths, cycleways, pedestrian streets and steps
+	PathRoad
+)
+
+func (c RoadClass) String() string {
+	switch c {
+	case MajorRoad:
+		return "major"
+	case ArterialRoad:
+		return "arterial"
+	case LocalRoad:
+		return "local"
+	case ServiceRoad:
+		return "service"
+	case PathRoad:
+		return "path"
+	default:
+		return "unknown"
+	}
+}
+
+// How much traffic the class carries, which decides the class of road nodes shared by several classes
+func (c RoadClass) Importance() int {
+	switch c {
+	case MajorRoad:
+		return 5
+	case ArterialRoad:
+		return 4
+	case LocalRoad:
+		return 3
+	case ServiceRoad:
+		return 2
+	case PathRoad:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,17 @@
+package layers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRoadClass(t *testing.T) {
+	require.Equal(t, "arterial", ArterialRoad.String())
+	require.Equal(t, "unknown", RoadClass(-1).String())
+
+	classes := []RoadClass{UnknownRoad, PathRoad, ServiceRoad, LocalRoad, ArterialRoad, MajorRoad}
+	for i := 1; i < len(classes); i++ {
+		require.True(t, classes[i].Importance() > classes[i-1].Importance(), "%s should be more important than %s",
+			classes[i], classes[i-1])
+	}
+}
@@ -0,0 +1,41 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+)
+
+// What a tower slot is built on
+type SlotKind int
+
+const (
+	// On the roof of a building
+	RooftopSlot SlotKind = iota
+	// Beside a road
+	FrontageSlot
+	// On open land away from the roads
+	GroundSlot
+)
+
+func (k SlotKind) String() string {
+	switch k {
+	case RooftopSlot:
+		return "rooftop"
+	case FrontageSlot:
+		return "frontage"
+	case GroundSlot:
+		return "ground"
+	default:
+		return "unknown"
+	}
+}
+
+// A place where a tower can be built
+type TowerSlot struct {
+	*primitives.Point
+	Kind SlotKind
+	// The building the slot is on, or nil if the slot isn't on a building
+	Building *world.Building
+	// Length of the enemy routes within range of the slot that it can see
+	Score float64
+}
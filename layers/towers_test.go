@@ -0,0 +1,13 @@
+package layers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSlotKind(t *testing.T) {
+	require.Equal(t, "rooftop", RooftopSlot.String())
+	require.Equal(t, "frontage", FrontageSlot.String())
+	require.Equal(t, "ground", GroundSlot.String())
+	require.Equal(t, "unknown", SlotKind(-1).String())
+}
@@ -0,0 +1,206 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+)
+
+// World wraps the game-core container with the layers produced by the generator that game-core doesn't model
+type World struct {
+	*world.Container
+
+	// Inner rings of buildings keyed by building id, wound clockwise
+	Holes map[world.Id][][]*world.Node
+
+	// Height, type and roof information for each building keyed by building id
+	Attributes map[world.Id]*BuildingAttributes
+
+	// The kind of road each road node is on keyed by road id
+	RoadClasses map[world.Id]RoadClass
+
+	// Land cover such as water, parks and forests along with linear features such as rivers and railways
+	Areas []*Area
+	Lines []*Line
+
+	// Landmarks such as shops, bus stops and trees
+	Pois []*Poi
+
+	// Road nodes that enemies enter the world from and the place they travel to, empty until they are placed
+	Spawns []*world.Road
+	Base   *Base
+
+	// Connector roads added between parts of the road network that weren't joined in the map data
+	Bridges []*Bridge
+
+	// Ways that enemies take from the spawn points to the base
+	Routes []*Route
+
+	// Places where towers can be built, from the best to the worst
+	TowerSlots []*TowerSlot
+}
+
+// The place that enemies travel to
+type Base struct {
+	// The road node enemies path to
+	Road *world.Road
+	// The building the base is in, or nil if the base is on the road node
+	Building *world.Building
+}
+
+// A connection between two road nodes that was made up by the generator rather than taken from the map data
+type Bridge struct {
+	From, To *world.Road
+}
+
+// A way that enemies take from a spawn point to the base
+type Route struct {
+	Spawn *world.Road
+	// Road nodes from the spawn point to the base, including both
+	Roads  []*world.Road
+	Length float64
+	// Road nodes along the route that every path from the spawn point to the base has to pass through
+	ChokePoints []*world.Road
+}
+
+// The route as a line through each of its road nodes
+func (r *Route) Points() []*primitives.Point {
+	points := make([]*primitives.Point, len(r.Roads))
+	for i, road := range r.Roads {
+		points[i] = road.Point
+	}
+
+	return points
+}
+
+func NewWorld(container *world.Container) *World {
+	w := new(World)
+	w.Container = container
+	w.Holes = make(map[world.Id][][]*world.Node)
+	w.Attributes = make(map[world.Id]*BuildingAttributes)
+	w.RoadClasses = make(map[world.Id]RoadClass)
+	w.Areas = make([]*Area, 0)
+	w.Lines = make([]*Line, 0)
+	w.Pois = make([]*Poi, 0)
+	w.Spawns = make([]*world.Road, 0)
+	w.Bridges = make([]*Bridge, 0)
+	w.Routes = make([]*Route, 0)
+	w.TowerSlots = make([]*TowerSlot, 0)
+	return w
+}
+
+// Whether the point is inside the building and not in one of its holes
+func (w *World) InBuilding(b *world.Building, p *primitives.Point) bool {
+	return BuildingBounds(b).ContainsPoint(p) && w.inOutline(b, p)
+}
+
+// InBuilding without looking at the bounds first
+func (w *World) inOutline(b *world.Building, p *primitives.Point) bool {
+	if !RingContains(b.Points(), p) {
+		return false
+	}
+
+	for _, hole := range w.Holes[b.Id()] {
+		if RingContains(hole, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The bounds of the building's outline. game-core's Building.Bounds misses the largest x or y when the first point of
+// the outline has it.
+func BuildingBounds(b *world.Building) *primitives.Rectangle {
+	return ringBounds(b.Points())
+}
+
+func ringBounds(points []*world.Node) *primitives.Rectangle {
+	if len(points) == 0 {
+		return primitives.NewRectangle(0, 0, 0, 0)
+	}
+
+	minX, minY, maxX, maxY := points[0].X(), points[0].Y(), points[0].X(), points[0].Y()
+	for _, p := range points[1:] {
+		if p.X() < minX {
+			minX = p.X()
+		}
+
+		if p.X() > maxX {
+			maxX = p.X()
+		}
+
+		if p.Y() < minY {
+			minY = p.Y()
+		}
+
+		if p.Y() > maxY {
+			maxY = p.Y()
+		}
+	}
+
+	return primitives.NewRectangle(minX, minY, maxX, maxY)
+}
+
+// Links each point of interest to the building it sits inside of, if any
+func (w *World) LinkPois() {
+	buildings := w.Buildings()
+	bounds := make([]*primitives.Rectangle, len(buildings))
+	for i, b := range buildings {
+		bounds[i] = BuildingBounds(b)
+	}
+
+	grid := newRectangleGrid(bounds)
+	for _, poi := range w.Pois {
+		poi.Building = nil
+		for _, i := range grid.at(poi.Point) {
+			if w.inOutline(buildings[i], poi.Point) {
+				poi.Building = buildings[i]
+				break
+			}
+		}
+	}
+}
+
+// All of the areas that contain the point, since areas can overlap. An AreaIndex is quicker for many points.
+func (w *World) AreasAt(p *primitives.Point) []*Area {
+	areas := make([]*Area, 0)
+	for _, a := range w.Areas {
+		if a.Contains(p) {
+			areas = append(areas, a)
+		}
+	}
+
+	return areas
+}
+
+// Whether the point is strictly inside the ring, so points on its edge are not
+func RingContains(ring []*world.Node, p *primitives.Point) bool {
+	inside := false
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+
+		// Check if the point sits on this edge
+		crossProduct := (b.X()-a.X())*(p.Y()-a.Y()) - (b.Y()-a.Y())*(p.X()-a.X())
+		if crossProduct == 0 && between(p.X(), a.X(), b.X()) && between(p.Y(), a.Y(), b.Y()) {
+			return false
+		}
+
+		// Cast a ray in the positive x direction and count the edges that it crosses
+		if (a.Y() > p.Y()) != (b.Y() > p.Y()) {
+			crossX := float64(a.X()) + float64(p.Y()-a.Y())*float64(b.X()-a.X())/float64(b.Y()-a.Y())
+			if float64(p.X()) < crossX {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+func between(v, a, b int) bool {
+	if a > b {
+		a, b = b, a
+	}
+
+	return a <= v && v <= b
+}
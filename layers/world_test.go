@@ -0,0 +1,186 @@
+package layers
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func TestNewWorld(t *testing.T) {
+	container := world.NewContainer(nil, nil, nil)
+	w := NewWorld(container)
+
+	require.Same(t, container, w.Container)
+	require.NotNil(t, w.Holes)
+	require.NotNil(t, w.Attributes)
+	require.NotNil(t, w.Areas)
+	require.NotNil(t, w.Lines)
+	require.NotNil(t, w.Pois)
+	require.NotNil(t, w.Spawns)
+	require.NotNil(t, w.Routes)
+	require.NotNil(t, w.TowerSlots)
+}
+
+func TestRoute_Points(t *testing.T) {
+	r1 := world.NewRoad(0, world.NewNode(1, 0, 0))
+	r2 := world.NewRoad(2, world.NewNode(3, 5, 5))
+	route := &Route{Spawn: r1, Roads: []*world.Road{r1, r2}}
+
+	require.Equal(t, []*primitives.Point{primitives.NewPoint(0, 0), primitives.NewPoint(5, 5)}, route.Points())
+}
+
+func TestWorld_InBuilding(t *testing.T) {
+	b := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 0, 0),
+		world.NewNode(2, 30, 0),
+		world.NewNode(3, 30, 30),
+		world.NewNode(4, 0, 30),
+	})
+
+	w := NewWorld(world.NewContainer(nil, nil, []*world.Building{b}))
+	require.True(t, w.InBuilding(b, primitives.NewPoint(15, 15)))
+	require.False(t, w.InBuilding(b, primitives.NewPoint(40, 15)))
+
+	w.Holes[b.Id()] = [][]*world.Node{{
+		world.NewNode(5, 10, 10),
+		world.NewNode(6, 10, 20),
+		world.NewNode(7, 20, 20),
+		world.NewNode(8, 20, 10),
+	}}
+	require.False(t, w.InBuilding(b, primitives.NewPoint(15, 15)), "courtyards are not part of the building")
+	require.True(t, w.InBuilding(b, primitives.NewPoint(5, 15)))
+}
+
+func TestWorld_InBuilding_Rotated(t *testing.T) {
+	// The first point has the largest x, which game-core leaves out of the building's bounds
+	b := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 20, 10),
+		world.NewNode(2, 10, 0),
+		world.NewNode(3, 0, 10),
+		world.NewNode(4, 10, 20),
+	})
+
+	w := NewWorld(world.NewContainer(nil, nil, []*world.Building{b}))
+	require.True(t, w.InBuilding(b, primitives.NewPoint(15, 10)))
+	require.True(t, w.InBuilding(b, primitives.NewPoint(5, 10)))
+	require.False(t, w.InBuilding(b, primitives.NewPoint(18, 18)))
+}
+
+func TestBuildingBounds(t *testing.T) {
+	b := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 20, 10),
+		world.NewNode(2, 10, 0),
+		world.NewNode(3, 0, 10),
+		world.NewNode(4, 10, 25),
+	})
+
+	require.Equal(t, primitives.NewRectangle(0, 0, 20, 25), BuildingBounds(b))
+}
+
+func TestRingContains(t *testing.T) {
+	triangle := []*world.Node{world.NewNode(0, 0, 0), world.NewNode(1, 10, 0), world.NewNode(2, 0, 10)}
+
+	require.True(t, RingContains(triangle, primitives.NewPoint(2, 2)))
+	require.False(t, RingContains(triangle, primitives.NewPoint(8, 8)))
+	require.False(t, RingContains(triangle, primitives.NewPoint(-1, 0)))
+	require.False(t, RingContains(triangle, primitives.NewPoint(5, 0)), "on edge")
+	require.False(t, RingContains(triangle, primitives.NewPoint(5, 5)), "on edge")
+	require.False(t, RingContains(triangle, primitives.NewPoint(0, 0)), "on corner")
+}
+
+func TestWorld_AreasAt(t *testing.T) {
+	park := &Area{
+		Class: ParkArea,
+		Outer: []*world.Node{world.NewNode(0, 0, 0), world.NewNode(1, 30, 0), world.NewNode(2, 30, 30), world.NewNode(3, 0, 30)},
+	}
+
+	pond := &Area{
+		Class: WaterArea,
+		Outer: []*world.Node{world.NewNode(4, 10, 10), world.NewNode(5, 20, 10), world.NewNode(6, 20, 20), world.NewNode(7, 10, 20)},
+	}
+
+	w := NewWorld(world.NewContainer(nil, nil, nil))
+	w.Areas = []*Area{park, pond}
+
+	require.Equal(t, []*Area{park}, w.AreasAt(primitives.NewPoint(5, 5)))
+	require.Equal(t, []*Area{park, pond}, w.AreasAt(primitives.NewPoint(15, 15)))
+	require.Empty(t, w.AreasAt(primitives.NewPoint(40, 40)))
+}
+
+func TestWorld_LinkPois_Rotated(t *testing.T) {
+	b := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 20, 10),
+		world.NewNode(2, 10, 0),
+		world.NewNode(3, 0, 10),
+		world.NewNode(4, 10, 20),
+	})
+
+	poi := &Poi{Category: ShopPoi, Node: world.NewNode(5, 15, 10)}
+	w := NewWorld(world.NewContainer(nil, nil, []*world.Building{b}))
+	w.Pois = []*Poi{poi}
+
+	w.LinkPois()
+	require.Same(t, b, poi.Building)
+}
+
+func TestWorld_LinkPois(t *testing.T) {
+	b := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 0, 0),
+		world.NewNode(2, 30, 0),
+		world.NewNode(3, 30, 30),
+		world.NewNode(4, 0, 30),
+	})
+
+	inside := &Poi{Category: ShopPoi, Node: world.NewNode(5, 5, 5)}
+	outside := &Poi{Category: TreePoi, Node: world.NewNode(6, 40, 40), Building: b}
+	courtyard := &Poi{Category: TreePoi, Node: world.NewNode(7, 15, 15)}
+
+	w := NewWorld(world.NewContainer(nil, nil, []*world.Building{b}))
+	w.Holes[b.Id()] = [][]*world.Node{{
+		world.NewNode(8, 10, 10),
+		world.NewNode(9, 10, 20),
+		world.NewNode(10, 20, 20),
+		world.NewNode(11, 20, 10),
+	}}
+	w.Pois = []*Poi{inside, outside, courtyard}
+
+	w.LinkPois()
+	require.Same(t, b, inside.Building)
+	require.Nil(t, outside.Building, "stale links should be cleared")
+	require.Nil(t, courtyard.Building, "courtyards are outside of the building")
+}
+
+func TestWorld_LinkPois_MatchesEveryBuilding(t *testing.T) {
+	random := rand.New(rand.NewSource(31))
+	buildings := make([]*world.Building, 300)
+	for i := range buildings {
+		x, y, size := random.Intn(1000), random.Intn(1000), 1+random.Intn(60)
+		id := world.Id(5 * i)
+		buildings[i] = world.NewBuilding(id, []*world.Node{
+			world.NewNode(id+1, x+size, y+size/2),
+			world.NewNode(id+2, x+size/2, y),
+			world.NewNode(id+3, x, y+size/2),
+			world.NewNode(id+4, x+size/2, y+size),
+		})
+	}
+
+	w := NewWorld(world.NewContainer(nil, nil, buildings))
+	for i := 0; i < 1000; i++ {
+		w.Pois = append(w.Pois, &Poi{Node: world.NewNode(world.Id(10000+i), random.Intn(1100)-50, random.Intn(1100)-50)})
+	}
+
+	w.LinkPois()
+	for _, poi := range w.Pois {
+		var expected *world.Building
+		for _, b := range buildings {
+			if w.InBuilding(b, poi.Point) {
+				expected = b
+				break
+			}
+		}
+
+		require.Same(t, expected, poi.Building)
+	}
+}
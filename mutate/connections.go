@@ -2,28 +2,45 @@ package mutate
 
 import (
 	"container/heap"
+	"errors"
+	"fmt"
 	"github.com/real-life-td/game-core/world"
 	"github.com/real-life-td/math/primitives"
 	"github.com/real-life-td/math/raycast"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/layers"
 	"math"
+	"sort"
 )
 
 type toRemove struct {
 	building   *world.Building
 	connection *world.Connection
 	score      float64
+	// Used to break ties between equal scores
+	buildingIndex, connectionIndex int
 }
 
-type toRemoveMaxHeap []toRemove
+// Whether a should be kept over b. Ties in score remove the earlier connections first.
+func (a toRemove) keptOver(b toRemove) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	} else if a.buildingIndex != b.buildingIndex {
+		return a.buildingIndex > b.buildingIndex
+	}
+
+	return a.connectionIndex > b.connectionIndex
+}
 
-func (r toRemoveMaxHeap) Len() int           { return len(r) }
-func (r toRemoveMaxHeap) Less(i, j int) bool { return r[i].score > r[j].score }
-func (r toRemoveMaxHeap) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+// The root of the heap is the connection that most deserves to be kept
+type toRemoveMinHeap []toRemove
 
-func (r *toRemoveMaxHeap) Push(x interface{}) { *r = append(*r, x.(toRemove)) }
-func (r toRemoveMaxHeap) Peek() toRemove      { return r[len(r)-1] }
+func (r toRemoveMinHeap) Len() int            { return len(r) }
+func (r toRemoveMinHeap) Less(i, j int) bool  { return r[i].keptOver(r[j]) }
+func (r toRemoveMinHeap) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *toRemoveMinHeap) Push(x interface{}) { *r = append(*r, x.(toRemove)) }
 
-func (r *toRemoveMaxHeap) Pop() interface{} {
+func (r *toRemoveMinHeap) Pop() interface{} {
 	old := *r
 	n := len(old)
 	x := old[n-1]
@@ -31,50 +48,283 @@ func (r *toRemoveMaxHeap) Pop() interface{} {
 	return x
 }
 
-func InitBuildingConnections(container *world.Container, targetAvgConnections float64) {
-	initBuildingConnections(container, 4, targetAvgConnections)
+// Returned along with the report when buildings are still short of connections once the limits are reached
+var ErrTargetNotReached = errors.New("buildings with fewer than the minimum connections")
+
+// What happened while connecting buildings to roads
+type ConnectionReport struct {
+	// Passes made over the buildings and the search radius of the last one
+	Iterations         int
+	Radius             int
+	AverageConnections float64
+	// Buildings with fewer than the minimum number of connections, in the order they are in the container
+	Short []*world.Building
+	// Buildings left without any connections
+	Unconnected []*world.Building
 }
 
-func initBuildingConnections(container *world.Container, initialConnectDistance int, targetAvgConnections float64) {
-	for _, b := range container.Buildings() {
-		connectionBounds := b.Bounds().Expand(initialConnectDistance, initialConnectDistance, initialConnectDistance, initialConnectDistance)
-		withinBounds := withinBounds(connectionBounds, container.Roads())
-
-		closeEnough := make(map[world.Id]*world.Connection) // Map to make the next step more efficient
-		for _, r := range withinBounds {
-			closest := closestConnection(b, r)
-			if int(math.Round(closest.Distance())) < initialConnectDistance {
-				closeEnough[r.Id()] = closest
+// Connects every building to the roads around it, widening the search for buildings that are short of connections.
+// Roads that pass closer to a building than their nodes are split, which gives the world a new container.
+func InitBuildingConnections(w *layers.World, opts ...Option) (*ConnectionReport, error) {
+	options := new(Options)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := options.check(); err != nil {
+		return nil, err
+	}
+
+	c := newConnector(w.Roads(), w.RoadClasses, options)
+	report := initBuildingConnections(w.Container, c, options.maxRadius(w.Meta()), options.maxIterations())
+
+	if len(c.index.Roads()) != len(w.Roads()) {
+		w.Container = world.NewContainer(w.Meta(), c.index.Roads(), w.Buildings())
+	}
+
+	if len(report.Short) > 0 {
+		return report, fmt.Errorf("%w: %d of %d buildings after %d passes up to a radius of %d", ErrTargetNotReached,
+			len(report.Short), len(w.Buildings()), report.Iterations, report.Radius)
+	}
+
+	return report, nil
+}
+
+// A junction is only added when it is at least this much closer to the building than the ends of the road segment, so
+// that roads aren't split over a rounding error
+const minJunctionGain = 1.0
+
+type connector struct {
+	index *RoadIndex
+	// Number used for the id of the next junction
+	nextJunction uint64
+	// Junctions added for a connection that was kept, in the order they were added
+	junctions []*world.Road
+
+	// Class of each road, which junctions are added to. Nil when the world has no road classes.
+	classes map[world.Id]layers.RoadClass
+
+	strategy       ConnectionStrategy
+	minConnections int
+	maxConnections int
+	maxLength      float64
+	targetAverage  float64
+}
+
+func newConnector(roads []*world.Road, classes map[world.Id]layers.RoadClass, options *Options) *connector {
+	c := &connector{
+		index:          NewRoadIndex(roads),
+		classes:        classes,
+		strategy:       options.strategy(),
+		minConnections: options.minConnections(),
+		maxConnections: options.MaxConnections,
+		maxLength:      options.maxLength(),
+		targetAverage:  options.TargetAverage,
+		nextJunction:   nextJunctionNumber(roads),
+	}
+
+	return c
+}
+
+// Number to give the next junction, carrying on from junctions that were added by an earlier call
+func nextJunctionNumber(roads []*world.Road) uint64 {
+	next := uint64(0)
+	for _, r := range roads {
+		if ref := ids.Lookup(r.Id()); ref.Namespace == ids.JunctionNamespace && ref.OsmId >= next {
+			next = ref.OsmId + 1
+		}
+	}
+
+	return next
+}
+
+// Splits the segment with a new junction at the point, or returns nil when there are no junction ids left
+func addJunction(index *RoadIndex, s *Segment, p *primitives.Point, number uint64, classes map[world.Id]layers.RoadClass) *world.Road {
+	roadId, nodeId, err := ids.JunctionIds(number)
+	if err != nil {
+		return nil
+	}
+
+	junction := world.NewRoad(roadId, world.NewNode(nodeId, p.X(), p.Y()))
+	index.Split(s, junction)
+
+	if classes != nil {
+		from, to := classes[s.From.Id()], classes[s.To.Id()]
+		if to.Importance() < from.Importance() {
+			from = to
+		}
+
+		classes[roadId] = from
+	}
+
+	return junction
+}
+
+func initBuildingConnections(container *world.Container, c *connector, maxRadius, maxIterations int) *ConnectionReport {
+	report := &ConnectionReport{Short: make([]*world.Building, 0), Unconnected: make([]*world.Building, 0)}
+
+	pending := container.Buildings()
+	for connectDistance := minInt(initialConnectDistance, maxRadius); len(pending) > 0; connectDistance = minInt(connectDistance*2, maxRadius) {
+		short := make([]*world.Building, 0)
+		for _, b := range pending {
+			c.connectBuilding(b, connectDistance)
+			if len(b.Connections()) < c.minConnections {
+				short = append(short, b)
 			}
 		}
 
-		b.InitOperation(&world.BuildingInitOperation{
-			NewConnections: cullPaths(closeEnough),
-		})
+		pending = short
+		report.Iterations++
+		report.Radius = connectDistance
+
+		if connectDistance >= maxRadius || report.Iterations >= maxIterations {
+			break
+		}
 	}
 
-	// If the average number of connections for each building is low then recursively call this method with a connection
-	// distance that is twice as large.
-	avgConnections := averageNumberOfConnections(container.Buildings())
-	if avgConnections < targetAvgConnections {
-		initBuildingConnections(container, initialConnectDistance*2, targetAvgConnections)
-		return
-	} else {
+	if c.targetAverage > 0 {
 		// Remove any extra connection points
-		numToRemove := int(math.Round((avgConnections - targetAvgConnections) * float64(len(container.Buildings()))))
-		cullWorstScoring(container.Buildings(), numToRemove)
+		avgConnections := averageNumberOfConnections(container.Buildings())
+		numToRemove := int(math.Round((avgConnections - c.targetAverage) * float64(len(container.Buildings()))))
+		cullWorstScoring(container.Buildings(), numToRemove, c.minConnections, c.strategy)
+	}
+
+	// Buildings that were searched again or lost connections to culling can leave junctions that nothing connects to
+	c.removeUnusedJunctions(c.junctions, connectedRoads(container.Buildings()))
+
+	report.AverageConnections = averageNumberOfConnections(container.Buildings())
+	for _, b := range container.Buildings() {
+		if len(b.Connections()) < c.minConnections {
+			report.Short = append(report.Short, b)
+		}
+
+		if len(b.Connections()) == 0 {
+			report.Unconnected = append(report.Unconnected, b)
+		}
 	}
+
+	return report
 }
 
-func withinBounds(bounds *primitives.Rectangle, roads []*world.Road) []*world.Road {
-	possibleConnections := make([]*world.Road, 0)
-	for _, r := range roads {
-		if bounds.ContainsPoint(r.Node.Point) {
-			possibleConnections = append(possibleConnections, r)
+// Replaces the connections of the building with the closest roads within the distance, splitting road segments that
+// pass closer to the building than their ends
+func (c *connector) connectBuilding(b *world.Building, connectDistance int) {
+	connectionBounds := layers.BuildingBounds(b).Expand(connectDistance, connectDistance, connectDistance, connectDistance)
+	added := c.splitSegmentsNear(b, connectionBounds, connectDistance)
+	withinBounds := c.index.Within(connectionBounds)
+
+	closeEnough := make([]*world.Connection, 0, len(withinBounds))
+	for _, r := range withinBounds {
+		closest := closestConnection(b, r)
+		if int(math.Round(closest.Distance())) < connectDistance && closest.Distance() <= c.maxLength {
+			closeEnough = append(closeEnough, closest)
+		}
+	}
+
+	connections := c.strategy.Cull(b, closeEnough)
+	if c.maxConnections > 0 && len(connections) > c.maxConnections {
+		sortByDistance(connections)
+		connections = connections[:c.maxConnections]
+	}
+
+	kept := c.removeUnusedJunctions(added, connectedRoads(nil, connections))
+	c.junctions = append(c.junctions, kept...)
+	if len(kept) < len(added) {
+		// The ids of the junctions after the last one that is kept can be handed out again
+		c.nextJunction = ids.Lookup(added[0].Id()).OsmId
+		if len(kept) > 0 {
+			c.nextJunction = ids.Lookup(kept[len(kept)-1].Id()).OsmId + 1
+		}
+	}
+
+	b.InitOperation(&world.BuildingInitOperation{
+		NewConnections: connections,
+	})
+}
+
+func (c *connector) splitSegmentsNear(b *world.Building, bounds *primitives.Rectangle, connectDistance int) []*world.Road {
+	added := make([]*world.Road, 0)
+	for _, s := range c.index.SegmentsWithin(bounds) {
+		p, distance := closestPointOnSegment(b, s)
+		if p == nil || int(math.Round(distance)) >= connectDistance || distance > c.maxLength || samePoint(p, s.From.Point) || samePoint(p, s.To.Point) {
+			continue
+		}
+
+		endDistance := math.Min(closestConnection(b, s.From).Distance(), closestConnection(b, s.To).Distance())
+		if distance+minJunctionGain > endDistance {
+			continue
+		}
+
+		junction := addJunction(c.index, s, p, c.nextJunction, c.classes)
+		if junction == nil {
+			// Out of junction ids, the building can still connect to the ends of the segment
+			continue
+		}
+
+		c.nextJunction++
+		added = append(added, junction)
+	}
+
+	return added
+}
+
+// Joins the roads back up at the unused junctions and returns the used ones
+func (c *connector) removeUnusedJunctions(junctions []*world.Road, used map[*world.Road]bool) []*world.Road {
+	kept := make([]*world.Road, 0, len(junctions))
+	unused := make([]*world.Road, 0, len(junctions))
+	for _, j := range junctions {
+		if used[j] {
+			kept = append(kept, j)
+		} else {
+			unused = append(unused, j)
+			delete(c.classes, j.Id())
 		}
 	}
 
-	return possibleConnections
+	if len(unused) == 0 {
+		return kept
+	}
+
+	c.index.Unsplit(unused)
+	return kept
+}
+
+// The roads that the buildings are connected to, along with the roads of any extra connections
+func connectedRoads(buildings []*world.Building, extra ...[]*world.Connection) map[*world.Road]bool {
+	roads := make(map[*world.Road]bool)
+	for _, b := range buildings {
+		for _, connection := range b.Connections() {
+			roads[connection.Road()] = true
+		}
+	}
+
+	for _, connections := range extra {
+		for _, connection := range connections {
+			roads[connection.Road()] = true
+		}
+	}
+
+	return roads
+}
+
+// The point on the segment closest to any corner of the building and its distance from that corner
+func closestPointOnSegment(b *world.Building, s *Segment) (*primitives.Point, float64) {
+	closestDistance := math.MaxFloat64
+	var closestPoint *primitives.Point
+
+	for _, corner := range b.Points() {
+		p, distance := s.ClosestPointTo(corner.Point)
+		if distance < closestDistance {
+			closestDistance = distance
+			closestPoint = p
+		}
+	}
+
+	return closestPoint, closestDistance
+}
+
+func samePoint(a, b *primitives.Point) bool {
+	return a.X() == b.X() && a.Y() == b.Y()
 }
 
 func closestConnection(building *world.Building, r *world.Road) *world.Connection {
@@ -101,17 +351,25 @@ func closestConnection(building *world.Building, r *world.Road) *world.Connectio
 	return world.NewConnection(r, closestDistance, closestPoint)
 }
 
-// Iterate through the map in it's arbitrary order. Returns only the connections that are closer than any other
-// connection within 5 roads. This means that no two connections will be within 5 roads of another. Since map ordering
-// is arbitrary the output of this function is generally not consistent.
-func cullPaths(closeEnough map[world.Id]*world.Connection) []*world.Connection {
+// Returns only the connections that are closer than any other connection within the number of hops along the roads.
+// Connections are visited from closest to furthest with ties broken by road id, so the output is deterministic.
+func cullPaths(closeEnough []*world.Connection, hops int) []*world.Connection {
 	passing := make([]*world.Connection, 0)
 
+	ordered := make([]*world.Connection, len(closeEnough))
+	copy(ordered, closeEnough)
+	sortByDistance(ordered)
+
+	byRoad := make(map[world.Id]*world.Connection, len(closeEnough)) // Map to make the search more efficient
+	for _, c := range closeEnough {
+		byRoad[c.Road().Id()] = c
+	}
+
 	visited := make(map[world.Id]bool)
 
 	var breadthFirst func(cur *world.Road, distanceLimit int) *world.Connection
 	breadthFirst = func(cur *world.Road, distanceLimit int) *world.Connection {
-		closest := closeEnough[cur.Id()] // might be nil if the current road isn't connected to a building
+		closest := byRoad[cur.Id()] // might be nil if the current road isn't connected to a building
 		if distanceLimit == 0 {
 			return closest
 		}
@@ -134,75 +392,80 @@ func cullPaths(closeEnough map[world.Id]*world.Connection) []*world.Connection {
 		return closest
 	}
 
-	for _, c := range closeEnough {
+	for _, c := range ordered {
 		if !visited[c.Road().Id()] {
-			passing = append(passing, breadthFirst(c.Road(), 5))
+			passing = append(passing, breadthFirst(c.Road(), hops))
 		}
 	}
 
 	return passing
 }
 
-func cullWorstScoring(buildings []*world.Building, numToRemove int) {
+// Sorts the connections from closest to furthest with ties broken by road id
+func sortByDistance(connections []*world.Connection) {
+	sort.Slice(connections, func(i, j int) bool {
+		if connections[i].Distance() != connections[j].Distance() {
+			return connections[i].Distance() < connections[j].Distance()
+		}
+
+		return connections[i].Road().Id() < connections[j].Road().Id()
+	})
+}
+
+// Removes the worst scoring connections across all of the buildings, leaving each building at least keep connections
+func cullWorstScoring(buildings []*world.Building, numToRemove, keep int, strategy ConnectionStrategy) {
 	if numToRemove <= 0 {
 		return
 	}
 
-	removeHeap := new(toRemoveMaxHeap)
-	heap.Init(removeHeap)
-
-	for _, b := range buildings {
-		for i, score := range scoreConnections(b) {
-			// First iteration
-			if removeHeap.Len() == 0 {
-				heap.Push(removeHeap, toRemove{building: b, score: score, connection: b.Connections()[i]})
-			}
+	keep = maxInt(keep, 1)
 
-			if removeHeap.Peek().score < score {
-				if removeHeap.Len() > numToRemove {
-					heap.Pop(removeHeap)
-				}
+	// Holds the numToRemove worst connections seen so far. Each push beyond that evicts the one most worth keeping.
+	removeHeap := make(toRemoveMinHeap, 0, numToRemove+1)
+	for buildingIndex, b := range buildings {
+		scores := strategy.Score(b)
+		removable := len(b.Connections()) - keep
+		if removable <= 0 || len(scores) != len(b.Connections()) {
+			continue
+		}
 
-				heap.Push(removeHeap, toRemove{building: b, score: score, connection: b.Connections()[i]})
-			}
+		// Only the worst connections of a building can be removed, so that it keeps the ones that score best
+		candidates := make([]toRemove, len(scores))
+		for i, score := range scores {
+			candidates[i] = toRemove{building: b, connection: b.Connections()[i], score: score,
+				buildingIndex: buildingIndex, connectionIndex: i}
 		}
-	}
 
-	for removeHeap.Len() > 0 {
-		cur := heap.Pop(removeHeap).(toRemove)
-		cur.building.InitOperation(&world.BuildingInitOperation{ToRemoveConnections: []*world.Connection{cur.connection}})
-	}
-}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[j].keptOver(candidates[i])
+		})
 
-func scoreConnections(b *world.Building) []float64 {
-	if len(b.Connections()) == 0 {
-		return []float64{}
+		for _, candidate := range candidates[:removable] {
+			heap.Push(&removeHeap, candidate)
+			if removeHeap.Len() > numToRemove {
+				heap.Pop(&removeHeap)
+			}
+		}
 	}
 
-	// Find the closest connection to the building
-	var closest = b.Connections()[0]
-	for _, c := range b.Connections()[1:] {
-		if c.Distance() < closest.Distance() {
-			closest = c
-		}
+	// Group the connections by building so that they are removed in the order the buildings are in
+	toRemoveByBuilding := make(map[*world.Building][]*world.Connection)
+	for _, r := range removeHeap {
+		toRemoveByBuilding[r.building] = append(toRemoveByBuilding[r.building], r.connection)
 	}
 
-	scores := make([]float64, len(b.Connections()))
-	// Score the nodes
-	for i, c := range b.Connections() {
-		// Closest connection has a perfect score
-		if c == closest {
-			scores[i] = 0
+	for _, b := range buildings {
+		if connections := toRemoveByBuilding[b]; len(connections) > 0 {
+			b.InitOperation(&world.BuildingInitOperation{ToRemoveConnections: connections})
 		}
-
-		// distance from building x number of connections on building
-		scores[i] = c.Distance() * float64(len(b.Connections()))
 	}
-
-	return scores
 }
 
 func averageNumberOfConnections(buildings []*world.Building) float64 {
+	if len(buildings) == 0 {
+		return 0
+	}
+
 	number := 0
 	for _, b := range buildings {
 		number += len(b.Connections())
@@ -1,9 +1,18 @@
 package mutate
 
 import (
+	"bytes"
+	"errors"
 	"github.com/real-life-td/game-core/world"
 	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/convert"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
 	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
 )
 
@@ -43,15 +52,368 @@ func TestInitBuildingConnections(t *testing.T) {
 		world.NewNode(30, 0, 20),
 	})
 
-	container := world.NewContainer(nil, []*world.Road{r1, r2, r3, r4, r5, r6, r7, r8, r9, r10, r11, r12, r13}, []*world.Building{b})
+	w := &layers.World{Container: world.NewContainer(nil, []*world.Road{r1, r2, r3, r4, r5, r6, r7, r8, r9, r10, r11, r12, r13}, []*world.Building{b})}
 
-	InitBuildingConnections(container, 2.0)
+	report, err := InitBuildingConnections(w, WithMinConnections(2))
+	require.NoError(t, err)
+	require.Equal(t, &ConnectionReport{Iterations: 4, Radius: 32, AverageConnections: 2.0, Short: []*world.Building{},
+		Unconnected: []*world.Building{}}, report)
 	expectedConnections := []*world.Connection{
 		world.NewConnection(r1, 10.0, primitives.NewPoint(20, 0)),
 		world.NewConnection(r13, 30.0, primitives.NewPoint(0, 20)),
 	}
 	require.ElementsMatch(t, expectedConnections, b.Connections())
 
-	InitBuildingConnections(container, 3.0)
+	_, err = InitBuildingConnections(w, WithMinConnections(3))
+	require.NoError(t, err)
 	require.Equal(t, len(b.Connections()), 3)
+	require.Len(t, w.Roads(), 13, "the roads end closer to the building than anywhere else so none should be split")
+}
+
+func newSquareBuilding(id world.Id, x, y int) *world.Building {
+	return world.NewBuilding(id, []*world.Node{
+		world.NewNode(id+1, x, y),
+		world.NewNode(id+2, x+10, y),
+		world.NewNode(id+3, x+10, y+10),
+		world.NewNode(id+4, x, y+10),
+	})
+}
+
+func TestInitBuildingConnections_Limits(t *testing.T) {
+	// Nothing to connect to should stop at the iteration limit rather than searching forever
+	b1 := newSquareBuilding(0, 0, 0)
+	w := &layers.World{Container: world.NewContainer(nil, []*world.Road{}, []*world.Building{b1})}
+	report, err := InitBuildingConnections(w)
+	require.True(t, errors.Is(err, ErrTargetNotReached))
+	require.Equal(t, defaultMaxIterations, report.Iterations)
+	require.Equal(t, []*world.Building{b1}, report.Short)
+	require.Equal(t, []*world.Building{b1}, report.Unconnected)
+
+	// Nothing to connect is not an error
+	w = &layers.World{Container: world.NewContainer(nil, []*world.Road{}, []*world.Building{})}
+	report, err = InitBuildingConnections(w)
+	require.NoError(t, err)
+	require.Equal(t, &ConnectionReport{Short: []*world.Building{}, Unconnected: []*world.Building{}}, report)
+
+	_, err = InitBuildingConnections(w, WithMinConnections(3), WithMaxConnections(2))
+	require.EqualError(t, err, "max connections of 2 is less than the min connections of 3")
+	_, err = InitBuildingConnections(w, WithMaxLength(math.NaN()))
+	require.Error(t, err)
+
+	// One building is next to a road and the other is too far away to reach within the radius
+	r := world.NewRoad(100, world.NewNode(101, 0, 15))
+	b2 := newSquareBuilding(10, 500, 500)
+	w = &layers.World{Container: world.NewContainer(nil, []*world.Road{r}, []*world.Building{b1, b2})}
+	report, err = InitBuildingConnections(w, WithMaxRadius(100))
+	require.EqualError(t, err, "buildings with fewer than the minimum connections: 1 of 2 buildings after 6 passes up to a radius of 100")
+	require.Equal(t, &ConnectionReport{Iterations: 6, Radius: 100, AverageConnections: 0.5, Short: []*world.Building{b2},
+		Unconnected: []*world.Building{b2}}, report)
+	require.Len(t, b1.Connections(), 1)
+
+	report, err = InitBuildingConnections(w, WithMaxIterations(2))
+	require.True(t, errors.Is(err, ErrTargetNotReached))
+	require.Equal(t, 8, report.Radius)
+
+	// The metadata limits the radius when no radius is given
+	w = &layers.World{Container: world.NewContainer(world.NewMetadata(1000, 300, 0.0, 0.0, 1.0, 1.0), []*world.Road{r},
+		[]*world.Building{b1, b2})}
+	report, err = InitBuildingConnections(w)
+	require.NoError(t, err)
+	require.Equal(t, 1000, report.Radius, "the last pass should search as far as the world is wide")
+
+	// The max length limits the radius as well as the connections
+	report, err = InitBuildingConnections(w, WithMaxLength(400))
+	require.True(t, errors.Is(err, ErrTargetNotReached))
+	require.Equal(t, 401, report.Radius)
+	require.Equal(t, []*world.Building{b2}, report.Unconnected)
+}
+
+func TestInitBuildingConnections_PerBuilding(t *testing.T) {
+	// Roads that aren't connected to each other so that every road within reach becomes a connection
+	near := world.NewRoad(100, world.NewNode(101, 0, 15))
+	far := world.NewRoad(102, world.NewNode(103, 0, 60))
+	left := world.NewRoad(104, world.NewNode(105, 200, -3))
+	right := world.NewRoad(106, world.NewNode(107, 212, 5))
+	lonely := world.NewRoad(108, world.NewNode(109, 1000, 1030))
+
+	b1 := newSquareBuilding(0, 0, 0)
+	b2 := newSquareBuilding(10, 200, 0)
+	b3 := newSquareBuilding(20, 1000, 1000)
+	w := &layers.World{Container: world.NewContainer(nil, []*world.Road{near, far, left, right, lonely},
+		[]*world.Building{b1, b2, b3})}
+
+	report, err := InitBuildingConnections(w)
+	require.NoError(t, err)
+	require.Equal(t, 32, report.Radius, "the search should widen until the last building has a connection")
+	require.Equal(t, []*world.Connection{closestConnection(b1, near)}, b1.Connections(),
+		"buildings that already have enough connections should not be searched again")
+	require.ElementsMatch(t, []*world.Connection{closestConnection(b2, left), closestConnection(b2, right)}, b2.Connections())
+	require.Equal(t, []*world.Connection{closestConnection(b3, lonely)}, b3.Connections())
+
+	// Only the closest connections are kept
+	_, err = InitBuildingConnections(w, WithMaxConnections(1))
+	require.NoError(t, err)
+	require.Equal(t, []*world.Connection{closestConnection(b2, right)}, b2.Connections())
+
+	// Buildings that need more connections keep looking
+	_, err = InitBuildingConnections(w, WithMinConnections(2), WithMaxIterations(5))
+	require.True(t, errors.Is(err, ErrTargetNotReached))
+	require.ElementsMatch(t, []*world.Connection{closestConnection(b1, near), closestConnection(b1, far)}, b1.Connections())
+	require.Len(t, b2.Connections(), 2)
+	require.Len(t, b3.Connections(), 1)
+
+	// Connections longer than the max length are never made
+	report, err = InitBuildingConnections(w, WithMaxLength(10))
+	require.True(t, errors.Is(err, ErrTargetNotReached))
+	require.Equal(t, []*world.Building{b3}, report.Unconnected)
+
+	// The target average removes the worst connections from the buildings that have more than they need
+	report, err = InitBuildingConnections(w, WithTargetAverage(1.0))
+	require.NoError(t, err)
+	require.Equal(t, 1.0, report.AverageConnections)
+	require.Equal(t, []*world.Connection{closestConnection(b2, right)}, b2.Connections())
+}
+
+// Scores connections from a table and keeps every candidate
+type fixedScores map[*world.Connection]float64
+
+func (f fixedScores) Cull(_ *world.Building, candidates []*world.Connection) []*world.Connection {
+	return candidates
+}
+
+func (f fixedScores) Score(b *world.Building) []float64 {
+	scores := make([]float64, len(b.Connections()))
+	for i, c := range b.Connections() {
+		scores[i] = f[c]
+	}
+
+	return scores
+}
+
+// Removes connections one at a time from the worst scoring to the best, skipping any that would leave a building with
+// fewer than keep connections
+func bruteForceCull(buildings []*world.Building, numToRemove, keep int, scores fixedScores) map[*world.Connection]bool {
+	type candidate struct {
+		building        *world.Building
+		connection      *world.Connection
+		buildingIndex   int
+		connectionIndex int
+	}
+
+	candidates := make([]candidate, 0)
+	for bi, b := range buildings {
+		for ci, c := range b.Connections() {
+			candidates = append(candidates, candidate{building: b, connection: c, buildingIndex: bi, connectionIndex: ci})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if scores[a.connection] != scores[b.connection] {
+			return scores[a.connection] > scores[b.connection]
+		} else if a.buildingIndex != b.buildingIndex {
+			return a.buildingIndex < b.buildingIndex
+		}
+
+		return a.connectionIndex < b.connectionIndex
+	})
+
+	removed := make(map[*world.Connection]bool)
+	removedFrom := make(map[*world.Building]int)
+	for _, c := range candidates {
+		if len(removed) == numToRemove {
+			break
+		}
+
+		if len(c.building.Connections())-removedFrom[c.building] > maxInt(keep, 1) {
+			removed[c.connection] = true
+			removedFrom[c.building]++
+		}
+	}
+
+	return removed
+}
+
+func TestCullWorstScoring(t *testing.T) {
+	r := world.NewRoad(0, world.NewNode(1, 0, 0))
+	newConnection := func(distance float64) *world.Connection {
+		return world.NewConnection(r, distance, nil)
+	}
+
+	// The worst connection is on a building that only has one connection so the next worst ones are removed instead
+	only := newConnection(100)
+	worst, middle, best := newConnection(50), newConnection(20), newConnection(1)
+	b1 := newSquareBuilding(10, 0, 0)
+	b1.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{only}})
+	b2 := newSquareBuilding(20, 0, 0)
+	b2.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{middle, best, worst}})
+	scores := fixedScores{only: 100, worst: 50, middle: 20, best: 1}
+
+	cullWorstScoring([]*world.Building{b1, b2}, 5, 1, scores)
+	require.Equal(t, []*world.Connection{only}, b1.Connections())
+	require.Equal(t, []*world.Connection{best}, b2.Connections())
+
+	// Compare against removing connections one at a time
+	random := rand.New(rand.NewSource(44))
+	for i := 0; i < 500; i++ {
+		buildings := make([]*world.Building, random.Intn(8))
+		scores := make(fixedScores)
+		original := make(map[*world.Building][]*world.Connection)
+		total := 0
+		for j := range buildings {
+			buildings[j] = newSquareBuilding(world.Id(10*j), 0, 0)
+			connections := make([]*world.Connection, random.Intn(6))
+			for k := range connections {
+				connections[k] = newConnection(float64(k))
+				// Few distinct scores so that there are plenty of ties
+				scores[connections[k]] = float64(random.Intn(5))
+			}
+
+			buildings[j].InitOperation(&world.BuildingInitOperation{NewConnections: connections})
+			original[buildings[j]] = append([]*world.Connection{}, connections...)
+			total += len(connections)
+		}
+
+		numToRemove := random.Intn(total + 2)
+		keep := random.Intn(4)
+		removed := bruteForceCull(buildings, numToRemove, keep, scores)
+
+		cullWorstScoring(buildings, numToRemove, keep, scores)
+
+		for _, b := range buildings {
+			expected := make([]*world.Connection, 0)
+			for _, c := range original[b] {
+				if !removed[c] {
+					expected = append(expected, c)
+				}
+			}
+
+			require.ElementsMatch(t, expected, b.Connections(), "iteration %d", i)
+			require.True(t, len(b.Connections()) >= minInt(len(original[b]), maxInt(keep, 1)),
+				"iteration %d: buildings should keep their minimum number of connections", i)
+		}
+	}
+}
+
+func TestInitBuildingConnections_SplitsRoads(t *testing.T) {
+	// A long road with a building halfway along it
+	r1 := world.NewRoad(0, world.NewNode(1, 0, 0))
+	r2 := world.NewRoad(2, world.NewNode(3, 100, 0))
+	r1.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r2}})
+	r2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r1}})
+
+	b1 := world.NewBuilding(4, []*world.Node{
+		world.NewNode(5, 40, 10),
+		world.NewNode(6, 60, 10),
+		world.NewNode(7, 60, 30),
+		world.NewNode(8, 40, 30),
+	})
+
+	container := world.NewContainer(nil, []*world.Road{r1, r2}, []*world.Building{b1})
+	w := layers.NewWorld(container)
+	w.RoadClasses[r1.Id()] = layers.ArterialRoad
+	w.RoadClasses[r2.Id()] = layers.LocalRoad
+
+	_, err := InitBuildingConnections(w)
+	require.NoError(t, err)
+	require.NotEqual(t, container, w.Container, "the world should get a new container with the junction in it")
+	require.Len(t, w.Roads(), 3)
+
+	junction := w.Roads()[2]
+	require.Equal(t, &ids.OsmRef{Namespace: ids.JunctionNamespace, ElementType: ids.NodeElement, OsmId: 0},
+		ids.Lookup(junction.Id()))
+	require.Equal(t, primitives.NewPoint(40, 0), junction.Point)
+	require.Equal(t, layers.LocalRoad, w.RoadClasses[junction.Id()], "junctions should take the class of the less important end")
+	require.Equal(t, []*world.Road{junction}, r1.Connections())
+	require.Equal(t, []*world.Road{junction}, r2.Connections())
+	require.Equal(t, []*world.Road{r1, r2}, junction.Connections())
+	require.Equal(t, []*world.Connection{world.NewConnection(junction, 10.0, primitives.NewPoint(40, 10))}, b1.Connections())
+
+	// A second building further along the same road should split it again with the next junction id
+	b2 := world.NewBuilding(9, []*world.Node{
+		world.NewNode(10, 80, 10),
+		world.NewNode(11, 90, 10),
+		world.NewNode(12, 90, 20),
+		world.NewNode(13, 80, 20),
+	})
+
+	w.Container = world.NewContainer(nil, w.Roads(), []*world.Building{b1, b2})
+	_, err = InitBuildingConnections(w)
+	require.NoError(t, err)
+	require.Len(t, w.Roads(), 4)
+	require.Equal(t, uint64(1), ids.Lookup(w.Roads()[3].Id()).OsmId)
+	require.Equal(t, primitives.NewPoint(80, 0), w.Roads()[3].Point)
+	require.Equal(t, []*world.Road{r1, w.Roads()[3]}, junction.Connections())
+	require.Equal(t, []*world.Road{w.Roads()[3]}, r2.Connections())
+}
+
+func TestInitBuildingConnections_RemovesUnusedJunctions(t *testing.T) {
+	// Roads running past a building on either side, one closer than the other
+	near1 := world.NewRoad(0, world.NewNode(1, 0, 0))
+	near2 := world.NewRoad(2, world.NewNode(3, 100, 0))
+	far1 := world.NewRoad(4, world.NewNode(5, 0, 45))
+	far2 := world.NewRoad(6, world.NewNode(7, 100, 45))
+	near1.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{near2}})
+	near2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{near1}})
+	far1.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{far2}})
+	far2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{far1}})
+
+	b := newRectangleBuilding(8, 40, 10, 60, 30)
+	w := layers.NewWorld(world.NewContainer(nil, []*world.Road{near1, near2, far1, far2}, []*world.Building{b}))
+	for _, r := range w.Roads() {
+		w.RoadClasses[r.Id()] = layers.LocalRoad
+	}
+
+	_, err := InitBuildingConnections(w, WithMaxConnections(1))
+	require.NoError(t, err)
+	require.Len(t, w.Roads(), 5, "only the junction that the building is connected to should be added")
+
+	junction := w.Roads()[4]
+	require.Equal(t, uint64(0), ids.Lookup(junction.Id()).OsmId)
+	require.Equal(t, primitives.NewPoint(40, 0), junction.Point)
+	require.Equal(t, []*world.Connection{world.NewConnection(junction, 10.0, primitives.NewPoint(40, 10))}, b.Connections())
+	require.Equal(t, []*world.Road{far2}, far1.Connections(), "the road the building wasn't connected to should be joined back up")
+	require.Equal(t, []*world.Road{far1}, far2.Connections())
+	require.Len(t, w.RoadClasses, 5)
+
+	// When culling to the target average takes away a connection its junction goes as well
+	b2 := newRectangleBuilding(13, 40, 10, 60, 30)
+	w = layers.NewWorld(world.NewContainer(nil, []*world.Road{near1, near2, far1, far2, junction}, []*world.Building{b2}))
+	_, err = InitBuildingConnections(w, WithTargetAverage(1))
+	require.NoError(t, err)
+	require.Len(t, b2.Connections(), 1)
+	require.Len(t, w.Roads(), 5)
+	require.Equal(t, junction, b2.Connections()[0].Road())
+}
+
+func TestPipelineIsDeterministic(t *testing.T) {
+	strategies := map[string]func(w *layers.World) ConnectionStrategy{
+		"distance":       func(*layers.World) ConnectionStrategy { return DefaultStrategy },
+		"road class":     func(w *layers.World) ConnectionStrategy { return NewRoadClassStrategy(w.RoadClasses) },
+		"angular":        func(*layers.World) ConnectionStrategy { return NewAngularStrategy() },
+		"path diversity": func(*layers.World) ConnectionStrategy { return NewPathDiversityStrategy() },
+	}
+
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			generate := func() []byte {
+				metadata := world.NewMetadata(400, 400, 0.0, 0.0, 0.01, 0.01)
+				w, _, err := convert.Convert(metadata, testutil.GridCity(8, 0.01, 1))
+				require.NoError(t, err)
+
+				// A few buildings in the corners of the grid can't get two connections
+				_, err = InitBuildingConnections(w, WithMinConnections(2), WithTargetAverage(2.0), WithStrategy(strategy(w)))
+				require.True(t, err == nil || errors.Is(err, ErrTargetNotReached))
+
+				var buffer bytes.Buffer
+				require.NoError(t, w.WriteJSON(&buffer))
+				return buffer.Bytes()
+			}
+
+			expected := generate()
+			for i := 0; i < 5; i++ {
+				require.Equal(t, expected, generate(), "run %d should be byte-identical to the first", i)
+			}
+		})
+	}
 }
@@ -0,0 +1,309 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/layers"
+	"math"
+	"sort"
+)
+
+// A group of roads that can all be reached from each other
+type Component struct {
+	Roads []*world.Road
+}
+
+// Splits the roads into the groups that can be reached from each other, largest first
+func Components(roads []*world.Road) []*Component {
+	components, _ := componentsOf(roads)
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i].Roads) > len(components[j].Roads)
+	})
+
+	return components
+}
+
+// Connections are followed both ways so that roads only joined one way are still in the same component
+func componentsOf(roads []*world.Road) ([]*Component, map[*world.Road]*Component) {
+	position := make(map[*world.Road]int, len(roads))
+	for i, r := range roads {
+		position[r] = i
+	}
+
+	joined := make(map[*world.Road][]*world.Road, len(roads))
+	for _, r := range roads {
+		for _, c := range r.Connections() {
+			joined[r] = append(joined[r], c)
+			joined[c] = append(joined[c], r)
+		}
+	}
+
+	byRoad := make(map[*world.Road]*Component, len(roads))
+	ordered := make([]*Component, 0)
+	for _, start := range roads {
+		if byRoad[start] != nil {
+			continue
+		}
+
+		component := &Component{Roads: []*world.Road{start}}
+		byRoad[start] = component
+		ordered = append(ordered, component)
+
+		for i := 0; i < len(component.Roads); i++ {
+			for _, next := range joined[component.Roads[i]] {
+				if _, inRoads := position[next]; inRoads && byRoad[next] == nil {
+					byRoad[next] = component
+					component.Roads = append(component.Roads, next)
+				}
+			}
+		}
+
+		sort.Slice(component.Roads, func(i, j int) bool {
+			return position[component.Roads[i]] < position[component.Roads[j]]
+		})
+	}
+
+	return ordered, byRoad
+}
+
+type RepairOptions struct {
+	// Components closer than this are joined by a connector road. Values that are not positive disable bridging.
+	BridgeDistance float64
+	// Used to drop components after bridging
+	MinComponentSize int
+	KeepLargestOnly  bool
+}
+
+type RepairOption func(o *RepairOptions)
+
+func WithBridgeDistance(distance float64) RepairOption {
+	return func(o *RepairOptions) {
+		o.BridgeDistance = distance
+	}
+}
+
+func WithMinComponentSize(size int) RepairOption {
+	return func(o *RepairOptions) {
+		o.MinComponentSize = size
+	}
+}
+
+func WithKeepLargestOnly() RepairOption {
+	return func(o *RepairOptions) {
+		o.KeepLargestOnly = true
+	}
+}
+
+type RepairReport struct {
+	ComponentsBefore   int
+	ComponentsAfter    int
+	Bridges            []*Segment
+	Dropped            []*world.Road
+	RemovedConnections int
+}
+
+// Bridges components that are close together and then drops the ones that are too small, giving the world a new
+// container when any roads are added or dropped
+func RepairConnectivity(w *layers.World, opts ...RepairOption) (*RepairReport, error) {
+	options := new(RepairOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if math.IsNaN(options.BridgeDistance) {
+		return nil, errors.New("bridge distance must be a number")
+	} else if options.MinComponentSize < 0 {
+		return nil, fmt.Errorf("min component size of %d is negative", options.MinComponentSize)
+	}
+
+	report := &RepairReport{Bridges: make([]*Segment, 0), Dropped: make([]*world.Road, 0)}
+	report.ComponentsBefore = len(Components(w.Roads()))
+
+	roads := w.Roads()
+	if options.BridgeDistance > 0 {
+		report.Bridges, roads = bridgeComponents(w.Roads(), w.RoadClasses, options.BridgeDistance)
+		for _, b := range report.Bridges {
+			w.Bridges = append(w.Bridges, &layers.Bridge{From: b.From, To: b.To})
+		}
+	}
+
+	components := Components(roads)
+	dropped := make(map[*world.Road]bool)
+	for i, c := range components {
+		if len(c.Roads) < options.MinComponentSize || (options.KeepLargestOnly && i > 0) {
+			for _, r := range c.Roads {
+				dropped[r] = true
+			}
+		} else {
+			report.ComponentsAfter++
+		}
+	}
+
+	if len(dropped) == 0 {
+		if len(roads) != len(w.Roads()) {
+			w.Container = world.NewContainer(w.Meta(), roads, w.Buildings())
+		}
+
+		return report, nil
+	}
+
+	kept := make([]*world.Road, 0, len(roads)-len(dropped))
+	for _, r := range roads {
+		if dropped[r] {
+			report.Dropped = append(report.Dropped, r)
+			delete(w.RoadClasses, r.Id())
+		} else {
+			kept = append(kept, r)
+		}
+	}
+
+	for _, b := range w.Buildings() {
+		toRemove := make([]*world.Connection, 0)
+		for _, c := range b.Connections() {
+			if dropped[c.Road()] {
+				toRemove = append(toRemove, c)
+			}
+		}
+
+		if len(toRemove) > 0 {
+			report.RemovedConnections += len(toRemove)
+			b.InitOperation(&world.BuildingInitOperation{ToRemoveConnections: toRemove})
+		}
+	}
+
+	bridges := make([]*layers.Bridge, 0, len(w.Bridges))
+	for _, b := range w.Bridges {
+		if !dropped[b.From] && !dropped[b.To] {
+			bridges = append(bridges, b)
+		}
+	}
+
+	w.Bridges = bridges
+	w.Container = world.NewContainer(w.Meta(), kept, w.Buildings())
+	return report, nil
+}
+
+// Joins components that are within the distance of each other, shortest bridges first. Returns the connector roads and
+// the roads along with any junctions added for bridges to the middle of a segment.
+func bridgeComponents(roads []*world.Road, classes map[world.Id]layers.RoadClass, distance float64) ([]*Segment, []*world.Road) {
+	ordered, byRoad := componentsOf(roads)
+	order := make(map[*Component]int, len(ordered))
+	for i, c := range ordered {
+		order[c] = i
+	}
+
+	index := NewRoadIndex(roads)
+	reach := int(math.Ceil(distance))
+
+	// Goes to either a road node or a point in the middle of a segment
+	type bridge struct {
+		from, to *world.Road
+		segment  *Segment
+		point    *primitives.Point
+		length   float64
+	}
+
+	// Points at the end of a segment are left to the road node there
+	bridgesFrom := func(from *world.Road, within func(c *Component) bool) []*bridge {
+		bounds := primitives.NewRectangle(from.X()-reach, from.Y()-reach, from.X()+reach, from.Y()+reach)
+		found := make([]*bridge, 0)
+		for _, to := range index.Within(bounds) {
+			if within(byRoad[to]) {
+				found = append(found, &bridge{from: from, to: to, length: pointDistance(from.Point, to.Point)})
+			}
+		}
+
+		for _, s := range index.SegmentsWithin(bounds) {
+			if !within(byRoad[s.From]) || !within(byRoad[s.To]) {
+				continue
+			}
+
+			p, _ := s.ClosestPointTo(from.Point)
+			if !samePoint(p, s.From.Point) && !samePoint(p, s.To.Point) {
+				found = append(found, &bridge{from: from, segment: s, point: p, length: pointDistance(from.Point, p)})
+			}
+		}
+
+		return found
+	}
+
+	target := func(b *bridge) *Component {
+		if b.segment != nil {
+			return byRoad[b.segment.From]
+		}
+
+		return byRoad[b.to]
+	}
+
+	// Trying the bridges from shortest to longest joins each pair of components with the shortest bridge between them
+	bridges := make([]*bridge, 0)
+	for _, from := range roads {
+		for _, b := range bridgesFrom(from, func(c *Component) bool { return c != nil && c != byRoad[from] }) {
+			if b.length <= distance {
+				bridges = append(bridges, b)
+			}
+		}
+	}
+
+	sort.SliceStable(bridges, func(i, j int) bool {
+		return bridges[i].length < bridges[j].length
+	})
+
+	// Union find over the components
+	parent := make(map[*Component]*Component)
+	var find func(c *Component) *Component
+	find = func(c *Component) *Component {
+		if p, ok := parent[c]; ok && p != c {
+			root := find(p)
+			parent[c] = root
+			return root
+		}
+
+		return c
+	}
+
+	nextJunction := nextJunctionNumber(roads)
+	added := make([]*Segment, 0)
+	for _, b := range bridges {
+		a, c := find(byRoad[b.from]), find(target(b))
+		if a == c {
+			continue
+		}
+
+		if _, ok := index.segmentOrder[b.segment]; b.segment != nil && !ok {
+			// An earlier bridge split the segment so this one goes to the closest of the pieces instead
+			other, closest := target(b), (*bridge)(nil)
+			for _, piece := range bridgesFrom(b.from, func(c *Component) bool { return c == other }) {
+				if piece.length <= distance && (closest == nil || piece.length < closest.length) {
+					closest = piece
+				}
+			}
+
+			if closest == nil {
+				continue
+			}
+
+			b = closest
+		}
+
+		if b.segment != nil {
+			b.to = addJunction(index, b.segment, b.point, nextJunction, classes)
+			if b.to == nil {
+				// Out of junction ids, the components can still be joined by another bridge
+				continue
+			}
+
+			nextJunction++
+			byRoad[b.to] = target(b)
+		}
+
+		parent[c] = a
+		b.from.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{b.to}})
+		b.to.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{b.from}})
+		added = append(added, &Segment{From: b.from, To: b.to})
+	}
+
+	return added, index.Roads()
+}
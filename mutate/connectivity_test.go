@@ -0,0 +1,147 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/ids"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestComponents(t *testing.T) {
+	// A main road, a driveway and a lone road node
+	main := []*world.Road{
+		world.NewRoad(0, world.NewNode(1, 0, 0)),
+		world.NewRoad(2, world.NewNode(3, 10, 0)),
+		world.NewRoad(4, world.NewNode(5, 20, 0)),
+		world.NewRoad(6, world.NewNode(7, 30, 0)),
+	}
+	driveway := []*world.Road{world.NewRoad(8, world.NewNode(9, 33, 0)), world.NewRoad(10, world.NewNode(11, 40, 0))}
+	lonely := world.NewRoad(12, world.NewNode(13, 100, 100))
+	testutil.Chain(main...)
+	testutil.Chain(driveway...)
+
+	// The driveway comes first so that the components are not already in size order
+	roads := append(append([]*world.Road{driveway[0], lonely}, main...), driveway[1])
+	require.Equal(t, []*Component{{Roads: main}, {Roads: driveway}, {Roads: []*world.Road{lonely}}}, Components(roads))
+	require.Empty(t, Components([]*world.Road{}))
+
+	// Connections to roads that aren't in the list are not followed
+	require.Equal(t, []*Component{{Roads: main[:2]}, {Roads: main[3:]}},
+		Components([]*world.Road{main[0], main[1], main[3]}))
+}
+
+func TestRepairConnectivity(t *testing.T) {
+	// A street and a lone road node far away with a building connected to it
+	a := world.NewRoad(0, world.NewNode(1, 0, 0))
+	b := world.NewRoad(2, world.NewNode(3, 10, 0))
+	lonely := world.NewRoad(4, world.NewNode(5, 100, 100))
+	building := newSquareBuilding(6, 100, 90)
+	testutil.Chain(a, b)
+	building.InitOperation(&world.BuildingInitOperation{
+		NewConnections: []*world.Connection{closestConnection(building, lonely)},
+	})
+
+	w := layers.NewWorld(world.NewContainer(nil, []*world.Road{lonely, a, b}, []*world.Building{building}))
+	for _, r := range w.Roads() {
+		w.RoadClasses[r.Id()] = layers.LocalRoad
+	}
+
+	container := w.Container
+	report, err := RepairConnectivity(w)
+	require.NoError(t, err)
+	require.Equal(t, &RepairReport{ComponentsBefore: 2, ComponentsAfter: 2, Bridges: []*Segment{},
+		Dropped: []*world.Road{}}, report)
+	require.Equal(t, container, w.Container, "the container should only be replaced when roads change")
+
+	_, err = RepairConnectivity(w, WithBridgeDistance(math.NaN()))
+	require.Error(t, err)
+	_, err = RepairConnectivity(w, WithMinComponentSize(-1))
+	require.Error(t, err)
+
+	report, err = RepairConnectivity(w, WithMinComponentSize(2))
+	require.NoError(t, err)
+	require.Equal(t, &RepairReport{ComponentsBefore: 2, ComponentsAfter: 1, Bridges: []*Segment{},
+		Dropped: []*world.Road{lonely}, RemovedConnections: 1}, report)
+	require.Equal(t, []*world.Road{a, b}, w.Roads())
+	require.NotContains(t, w.RoadClasses, lonely.Id())
+	require.Empty(t, building.Connections(), "connections to dropped roads should be removed")
+}
+
+func TestRepairConnectivity_Bridges(t *testing.T) {
+	// A main road with a driveway just past the end of it, a side road that stops just short of the middle of it and
+	// a lone road node far away
+	main := []*world.Road{
+		world.NewRoad(0, world.NewNode(1, 0, 0)),
+		world.NewRoad(2, world.NewNode(3, 10, 0)),
+		world.NewRoad(4, world.NewNode(5, 20, 0)),
+		world.NewRoad(6, world.NewNode(7, 30, 0)),
+	}
+	driveway := []*world.Road{world.NewRoad(8, world.NewNode(9, 33, 0)), world.NewRoad(10, world.NewNode(11, 40, 0))}
+	side := []*world.Road{world.NewRoad(12, world.NewNode(13, 15, 4)), world.NewRoad(14, world.NewNode(15, 15, 20))}
+	lonely := world.NewRoad(16, world.NewNode(17, 100, 100))
+	testutil.Chain(main...)
+	testutil.Chain(driveway...)
+	testutil.Chain(side...)
+
+	roads := append(append([]*world.Road{driveway[0], lonely}, main...), driveway[1], side[0], side[1])
+	w := layers.NewWorld(world.NewContainer(nil, roads, []*world.Building{}))
+	for _, r := range roads {
+		w.RoadClasses[r.Id()] = layers.LocalRoad
+	}
+
+	report, err := RepairConnectivity(w, WithBridgeDistance(5), WithKeepLargestOnly())
+	require.NoError(t, err)
+	require.Equal(t, 4, report.ComponentsBefore)
+	require.Equal(t, 1, report.ComponentsAfter)
+	require.Equal(t, []*world.Road{lonely}, report.Dropped)
+	require.Len(t, w.Roads(), 9, "the junction should be added and the lone road node dropped")
+
+	// The side road is bridged to a junction in the middle of the main road rather than to one of its road nodes
+	junction := w.Roads()[8]
+	require.Equal(t, &ids.OsmRef{Namespace: ids.JunctionNamespace, ElementType: ids.NodeElement, OsmId: 0},
+		ids.Lookup(junction.Id()))
+	require.Equal(t, primitives.NewPoint(15, 0), junction.Point)
+	require.Equal(t, layers.LocalRoad, w.RoadClasses[junction.Id()])
+	require.Equal(t, []*world.Road{main[1], main[2], side[0]}, junction.Connections())
+	require.Equal(t, []*world.Road{main[0], junction}, main[1].Connections())
+	require.Equal(t, []*world.Road{driveway[1], main[3]}, driveway[0].Connections())
+
+	require.Equal(t, []*Segment{{From: driveway[0], To: main[3]}, {From: side[0], To: junction}}, report.Bridges)
+	require.Equal(t, []*layers.Bridge{{From: driveway[0], To: main[3]}, {From: side[0], To: junction}}, w.Bridges,
+		"bridges should be recorded on the world so they can be told apart from real roads")
+}
+
+func TestRepairConnectivity_BridgesEveryCloseComponent(t *testing.T) {
+	random := rand.New(rand.NewSource(45))
+	for i := 0; i < 50; i++ {
+		roads := randomRoads(random, 200, 500)
+		distance := float64(random.Intn(40) + 1)
+		w := layers.NewWorld(world.NewContainer(nil, roads, []*world.Building{}))
+
+		before := len(Components(roads))
+		report, err := RepairConnectivity(w, WithBridgeDistance(distance))
+		require.NoError(t, err)
+		require.Equal(t, before-len(report.Bridges), report.ComponentsAfter, "each bridge should join two components")
+		require.Len(t, w.Bridges, len(report.Bridges))
+
+		// No two roads that are within the distance should be left in different components
+		_, byRoad := componentsOf(w.Roads())
+		for _, a := range roads {
+			for _, b := range roads {
+				if pointDistance(a.Point, b.Point) <= distance {
+					require.Equal(t, byRoad[a], byRoad[b])
+				}
+			}
+		}
+
+		for _, s := range report.Bridges {
+			require.LessOrEqual(t, pointDistance(s.From.Point, s.To.Point), distance)
+			require.Contains(t, w.Roads(), s.To)
+		}
+	}
+}
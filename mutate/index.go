@@ -0,0 +1,583 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/math/raycast"
+	"github.com/real-life-td/world-generator/layers"
+	"math"
+	"sort"
+)
+
+// The part of a road between two connected road nodes
+type Segment struct {
+	From, To *world.Road
+}
+
+func (s *Segment) Bounds() *primitives.Rectangle {
+	return primitives.RectangleFromPoints(s.From.Point, s.To.Point)
+}
+
+// Smallest distance from the point to anywhere on the segment and the point on the segment where it happens
+func (s *Segment) ClosestPointTo(p *primitives.Point) (*primitives.Point, float64) {
+	if s.From.X() == s.To.X() && s.From.Y() == s.To.Y() {
+		return s.From.Point, pointDistance(s.From.Point, p)
+	}
+
+	return raycast.ClosestPointTo(s.From.Point, s.To.Point, p)
+}
+
+// Uniform grid over the road nodes and the segments between them. Queries return results in the order they were indexed
+// so that they don't depend on the layout of the grid.
+type RoadIndex struct {
+	roads    []*world.Road
+	segments []*Segment
+
+	// Positions in the slices above for putting query results back in order
+	roadOrder    map[*world.Road]int
+	segmentOrder map[*Segment]int
+
+	grid
+	roadCells    [][]*world.Road
+	segmentCells [][]*Segment
+}
+
+// Cells of the same size covering a rectangle, with anything outside of it clamped to the cells at the edge
+type grid struct {
+	cellSize      int
+	originX       int
+	originY       int
+	columns, rows int
+}
+
+// A grid covering the bounds, or a single cell when there are no bounds
+func newGrid(bounds *primitives.Rectangle, cellSize int) grid {
+	if cellSize < 1 {
+		cellSize = 1
+	}
+
+	if bounds == nil {
+		return grid{cellSize: cellSize, columns: 1, rows: 1}
+	}
+
+	return grid{
+		cellSize: cellSize,
+		originX:  bounds.X1(),
+		originY:  bounds.Y1(),
+		columns:  bounds.Width()/cellSize + 1,
+		rows:     bounds.Height()/cellSize + 1,
+	}
+}
+
+// Cell size that puts about four of the items in each cell if they were spread evenly over the bounds
+func cellSizeFor(bounds *primitives.Rectangle, items int) int {
+	area := float64(bounds.Width()+1) * float64(bounds.Height()+1)
+	return int(math.Ceil(math.Sqrt(area * 4 / float64(items))))
+}
+
+// Indexes the roads with a cell size picked so that there are a few road nodes in each cell
+func NewRoadIndex(roads []*world.Road) *RoadIndex {
+	if len(roads) == 0 {
+		return newRoadIndex(roads, 1)
+	}
+
+	return newRoadIndex(roads, cellSizeFor(roadBounds(roads), len(roads)))
+}
+
+func newRoadIndex(roads []*world.Road, cellSize int) *RoadIndex {
+	var bounds *primitives.Rectangle
+	if len(roads) > 0 {
+		bounds = roadBounds(roads)
+	}
+
+	index := &RoadIndex{
+		// Copied so that adding and removing junctions never writes to the caller's slice
+		roads:     append(make([]*world.Road, 0, len(roads)), roads...),
+		segments:  roadSegments(roads),
+		roadOrder: make(map[*world.Road]int, len(roads)),
+		grid:      newGrid(bounds, cellSize),
+	}
+
+	index.segmentOrder = make(map[*Segment]int, len(index.segments))
+	index.roadCells = make([][]*world.Road, index.columns*index.rows)
+	index.segmentCells = make([][]*Segment, index.columns*index.rows)
+
+	for i, r := range roads {
+		index.roadOrder[r] = i
+		cell := index.cell(index.column(r.X()), index.row(r.Y()))
+		index.roadCells[cell] = append(index.roadCells[cell], r)
+	}
+
+	for i, s := range index.segments {
+		index.segmentOrder[s] = i
+		index.addSegmentToCells(s)
+	}
+
+	return index
+}
+
+// Every segment in the roads, once for each pair of connected roads
+func roadSegments(roads []*world.Road) []*Segment {
+	type pair struct{ a, b world.Id }
+
+	segments := make([]*Segment, 0, len(roads))
+	seen := make(map[pair]bool, len(roads))
+	for _, r := range roads {
+		for _, connected := range r.Connections() {
+			key := pair{r.Id(), connected.Id()}
+			if key.b < key.a {
+				key.a, key.b = key.b, key.a
+			}
+
+			if !seen[key] {
+				seen[key] = true
+				segments = append(segments, &Segment{From: r, To: connected})
+			}
+		}
+	}
+
+	return segments
+}
+
+func roadBounds(roads []*world.Road) *primitives.Rectangle {
+	minX, minY, maxX, maxY := math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32
+	for _, r := range roads {
+		minX, maxX = minInt(minX, r.X()), maxInt(maxX, r.X())
+		minY, maxY = minInt(minY, r.Y()), maxInt(maxY, r.Y())
+	}
+
+	return primitives.NewRectangle(minX, minY, maxX, maxY)
+}
+
+func (i *RoadIndex) Roads() []*world.Road {
+	return i.roads
+}
+
+func (i *RoadIndex) Segments() []*Segment {
+	return i.segments
+}
+
+// Splits the segment in two at the junction, which must lie inside the grid
+func (i *RoadIndex) Split(s *Segment, junction *world.Road) {
+	replaceConnection(s.From, s.To, junction)
+	replaceConnection(s.To, s.From, junction)
+	junction.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{s.From, s.To}})
+
+	i.roadOrder[junction] = len(i.roads)
+	i.roads = append(i.roads, junction)
+	cell := i.cell(i.column(junction.X()), i.row(junction.Y()))
+	i.roadCells[cell] = append(i.roadCells[cell], junction)
+
+	// The first half takes the place of the segment so that segments keep their order
+	first, second := &Segment{From: s.From, To: junction}, &Segment{From: junction, To: s.To}
+	position := i.segmentOrder[s]
+	delete(i.segmentOrder, s)
+	i.segments[position] = first
+	i.segmentOrder[first] = position
+	i.segmentOrder[second] = len(i.segments)
+	i.segments = append(i.segments, second)
+
+	i.removeSegmentFromCells(s)
+	i.addSegmentToCells(first)
+	i.addSegmentToCells(second)
+}
+
+// Undoes Split for each junction. Only the roads and segments after the first removed one are moved, so taking out
+// recent junctions is cheap.
+func (i *RoadIndex) Unsplit(junctions []*world.Road) {
+	removedRoads := make(map[*world.Road]bool, len(junctions))
+	removedSegments := make(map[*Segment]bool, len(junctions))
+	for _, j := range junctions {
+		if _, ok := i.roadOrder[j]; !ok || removedRoads[j] || len(j.Connections()) != 2 {
+			continue
+		}
+
+		// Both halves of the split segment go through the junction so they are in its cell
+		cell := i.cell(i.column(j.X()), i.row(j.Y()))
+		halves := make([]*Segment, 0, 2)
+		for _, s := range i.segmentCells[cell] {
+			if s.From == j || s.To == j {
+				halves = append(halves, s)
+			}
+		}
+
+		if len(halves) != 2 {
+			continue
+		}
+
+		a, b := j.Connections()[0], j.Connections()[1]
+		replaceConnection(a, j, b)
+		replaceConnection(b, j, a)
+
+		// The joined segment takes the place of the earlier half, which is where Split put the first half
+		lower, upper := halves[0], halves[1]
+		if i.segmentOrder[upper] < i.segmentOrder[lower] {
+			lower, upper = upper, lower
+		}
+
+		joined := &Segment{From: lower.otherEnd(j), To: upper.otherEnd(j)}
+		position := i.segmentOrder[lower]
+		delete(i.segmentOrder, lower)
+		i.segments[position] = joined
+		i.segmentOrder[joined] = position
+		removedSegments[upper] = true
+
+		i.removeSegmentFromCells(lower)
+		i.removeSegmentFromCells(upper)
+		i.addSegmentToCells(joined)
+
+		remaining := i.roadCells[cell][:0]
+		for _, r := range i.roadCells[cell] {
+			if r != j {
+				remaining = append(remaining, r)
+			}
+		}
+
+		i.roadCells[cell] = remaining
+		removedRoads[j] = true
+	}
+
+	i.compactRoads(removedRoads)
+	i.compactSegments(removedSegments)
+}
+
+// Takes the roads out of the order, moving the roads after the first removed one up to fill the gaps
+func (i *RoadIndex) compactRoads(removed map[*world.Road]bool) {
+	start := len(i.roads)
+	for r := range removed {
+		start = minInt(start, i.roadOrder[r])
+		delete(i.roadOrder, r)
+	}
+
+	kept := i.roads[:start]
+	for _, r := range i.roads[start:] {
+		if !removed[r] {
+			i.roadOrder[r] = len(kept)
+			kept = append(kept, r)
+		}
+	}
+
+	i.roads = kept
+}
+
+// Takes the segments out of the order, moving the segments after the first removed one up to fill the gaps
+func (i *RoadIndex) compactSegments(removed map[*Segment]bool) {
+	start := len(i.segments)
+	for s := range removed {
+		start = minInt(start, i.segmentOrder[s])
+		delete(i.segmentOrder, s)
+	}
+
+	kept := i.segments[:start]
+	for _, s := range i.segments[start:] {
+		if !removed[s] {
+			i.segmentOrder[s] = len(kept)
+			kept = append(kept, s)
+		}
+	}
+
+	i.segments = kept
+}
+
+// The end of the segment that isn't the road
+func (s *Segment) otherEnd(r *world.Road) *world.Road {
+	if s.From == r {
+		return s.To
+	}
+
+	return s.From
+}
+
+// Replaces the connection from road to old with a connection to replacement
+func replaceConnection(road, old, replacement *world.Road) {
+	connections := make([]*world.Road, len(road.Connections()))
+	for i, c := range road.Connections() {
+		if c == old {
+			connections[i] = replacement
+		} else {
+			connections[i] = c
+		}
+	}
+
+	road.InitOperation(&world.RoadInitOperation{NewConnections: connections})
+}
+
+func (i *RoadIndex) addSegmentToCells(s *Segment) {
+	bounds := s.Bounds()
+	for row := i.row(bounds.Y1()); row <= i.row(bounds.Y2()); row++ {
+		for column := i.column(bounds.X1()); column <= i.column(bounds.X2()); column++ {
+			cell := i.cell(column, row)
+			i.segmentCells[cell] = append(i.segmentCells[cell], s)
+		}
+	}
+}
+
+func (i *RoadIndex) removeSegmentFromCells(s *Segment) {
+	bounds := s.Bounds()
+	for row := i.row(bounds.Y1()); row <= i.row(bounds.Y2()); row++ {
+		for column := i.column(bounds.X1()); column <= i.column(bounds.X2()); column++ {
+			cell := i.cell(column, row)
+			remaining := i.segmentCells[cell][:0]
+			for _, other := range i.segmentCells[cell] {
+				if other != s {
+					remaining = append(remaining, other)
+				}
+			}
+
+			i.segmentCells[cell] = remaining
+		}
+	}
+}
+
+// Column of the cell that contains x, clamped to the grid
+func (g *grid) column(x int) int {
+	return clamp((x-g.originX)/g.cellSize, 0, g.columns-1)
+}
+
+// Row of the cell that contains y, clamped to the grid
+func (g *grid) row(y int) int {
+	return clamp((y-g.originY)/g.cellSize, 0, g.rows-1)
+}
+
+func (g *grid) cell(column, row int) int {
+	return row*g.columns + column
+}
+
+// Whether the rectangle misses the grid entirely, in which case clamping would wrongly give the edge cells
+func (g *grid) outside(bounds *primitives.Rectangle) bool {
+	return bounds.X2() < g.originX || bounds.Y2() < g.originY ||
+		bounds.X1() >= g.originX+g.columns*g.cellSize || bounds.Y1() >= g.originY+g.rows*g.cellSize
+}
+
+// Roads with a node inside the bounds
+func (i *RoadIndex) Within(bounds *primitives.Rectangle) []*world.Road {
+	found := make([]*world.Road, 0)
+	if len(i.roads) == 0 || i.outside(bounds) {
+		return found
+	}
+
+	for row := i.row(bounds.Y1()); row <= i.row(bounds.Y2()); row++ {
+		for column := i.column(bounds.X1()); column <= i.column(bounds.X2()); column++ {
+			for _, r := range i.roadCells[i.cell(column, row)] {
+				if bounds.ContainsPoint(r.Point) {
+					found = append(found, r)
+				}
+			}
+		}
+	}
+
+	sort.Slice(found, func(a, b int) bool {
+		return i.roadOrder[found[a]] < i.roadOrder[found[b]]
+	})
+
+	return found
+}
+
+// Segments whose bounding box overlaps the bounds
+func (i *RoadIndex) SegmentsWithin(bounds *primitives.Rectangle) []*Segment {
+	found := make([]*Segment, 0)
+	if len(i.segments) == 0 || i.outside(bounds) {
+		return found
+	}
+
+	seen := make(map[*Segment]bool)
+	for row := i.row(bounds.Y1()); row <= i.row(bounds.Y2()); row++ {
+		for column := i.column(bounds.X1()); column <= i.column(bounds.X2()); column++ {
+			for _, s := range i.segmentCells[i.cell(column, row)] {
+				if !seen[s] && overlaps(s.Bounds(), bounds) {
+					seen[s] = true
+					found = append(found, s)
+				}
+			}
+		}
+	}
+
+	sort.Slice(found, func(a, b int) bool {
+		return i.segmentOrder[found[a]] < i.segmentOrder[found[b]]
+	})
+
+	return found
+}
+
+// The k roads with nodes closest to the point, closest first. Roads the same distance away are ordered by id.
+func (i *RoadIndex) Nearest(p *primitives.Point, k int) []*world.Road {
+	if k <= 0 || len(i.roads) == 0 {
+		return []*world.Road{}
+	}
+
+	type candidate struct {
+		road     *world.Road
+		distance float64
+	}
+
+	candidates := make([]candidate, 0, k)
+	sortCandidates := func() {
+		sort.Slice(candidates, func(a, b int) bool {
+			if candidates[a].distance != candidates[b].distance {
+				return candidates[a].distance < candidates[b].distance
+			}
+
+			return candidates[a].road.Id() < candidates[b].road.Id()
+		})
+	}
+
+	centerColumn, centerRow := i.column(p.X()), i.row(p.Y())
+	maxRing := maxInt(maxInt(centerColumn, i.columns-1-centerColumn), maxInt(centerRow, i.rows-1-centerRow))
+	// A point outside the grid is already this far from every cell
+	outsideDistance := math.Max(pointDistanceToRange(p.X(), i.originX, i.originX+i.columns*i.cellSize),
+		pointDistanceToRange(p.Y(), i.originY, i.originY+i.rows*i.cellSize))
+
+	for ring := 0; ring <= maxRing; ring++ {
+		for row := centerRow - ring; row <= centerRow+ring; row++ {
+			for column := centerColumn - ring; column <= centerColumn+ring; column++ {
+				onRing := row == centerRow-ring || row == centerRow+ring || column == centerColumn-ring || column == centerColumn+ring
+				if !onRing || row < 0 || row >= i.rows || column < 0 || column >= i.columns {
+					continue
+				}
+
+				for _, r := range i.roadCells[i.cell(column, row)] {
+					candidates = append(candidates, candidate{road: r, distance: pointDistance(r.Point, p)})
+				}
+			}
+		}
+
+		// Anything in a cell further out is at least as far away as the width of the rings that have been searched
+		if len(candidates) >= k {
+			sortCandidates()
+			if candidates[k-1].distance < math.Max(float64(ring*i.cellSize), outsideDistance) {
+				break
+			}
+		}
+	}
+
+	sortCandidates()
+	nearest := make([]*world.Road, 0, k)
+	for _, c := range candidates {
+		if len(nearest) == k {
+			break
+		}
+
+		nearest = append(nearest, c.road)
+	}
+
+	return nearest
+}
+
+// Uniform grid over the bounding boxes of buildings. Queries return buildings in the order they were indexed.
+type BuildingIndex struct {
+	buildings []*world.Building
+	order     map[*world.Building]int
+	bounds    []*primitives.Rectangle
+
+	grid
+	cells [][]*world.Building
+}
+
+// Indexes the buildings with a cell size picked so that there are a few buildings in each cell
+func NewBuildingIndex(buildings []*world.Building) *BuildingIndex {
+	index := &BuildingIndex{
+		buildings: buildings,
+		order:     make(map[*world.Building]int, len(buildings)),
+		bounds:    make([]*primitives.Rectangle, len(buildings)),
+	}
+
+	for i, b := range buildings {
+		index.bounds[i] = layers.BuildingBounds(b)
+	}
+
+	if len(buildings) == 0 {
+		index.grid = newGrid(nil, 1)
+	} else {
+		bounds := index.bounds[0]
+		for _, b := range index.bounds[1:] {
+			bounds = primitives.NewRectangle(minInt(bounds.X1(), b.X1()), minInt(bounds.Y1(), b.Y1()),
+				maxInt(bounds.X2(), b.X2()), maxInt(bounds.Y2(), b.Y2()))
+		}
+
+		index.grid = newGrid(bounds, cellSizeFor(bounds, len(buildings)))
+	}
+
+	index.cells = make([][]*world.Building, index.columns*index.rows)
+	for i, b := range buildings {
+		index.order[b] = i
+		bounds := index.bounds[i]
+		for row := index.row(bounds.Y1()); row <= index.row(bounds.Y2()); row++ {
+			for column := index.column(bounds.X1()); column <= index.column(bounds.X2()); column++ {
+				cell := index.cell(column, row)
+				index.cells[cell] = append(index.cells[cell], b)
+			}
+		}
+	}
+
+	return index
+}
+
+func (i *BuildingIndex) Buildings() []*world.Building {
+	return i.buildings
+}
+
+// Buildings whose bounding box overlaps the bounds
+func (i *BuildingIndex) Within(bounds *primitives.Rectangle) []*world.Building {
+	found := make([]*world.Building, 0)
+	if len(i.buildings) == 0 || i.outside(bounds) {
+		return found
+	}
+
+	seen := make(map[*world.Building]bool)
+	for row := i.row(bounds.Y1()); row <= i.row(bounds.Y2()); row++ {
+		for column := i.column(bounds.X1()); column <= i.column(bounds.X2()); column++ {
+			for _, b := range i.cells[i.cell(column, row)] {
+				if !seen[b] && overlaps(i.bounds[i.order[b]], bounds) {
+					seen[b] = true
+					found = append(found, b)
+				}
+			}
+		}
+	}
+
+	sort.Slice(found, func(a, b int) bool {
+		return i.order[found[a]] < i.order[found[b]]
+	})
+
+	return found
+}
+
+func overlaps(a, b *primitives.Rectangle) bool {
+	return a.X1() <= b.X2() && b.X1() <= a.X2() && a.Y1() <= b.Y2() && b.Y1() <= a.Y2()
+}
+
+func pointDistance(a, b *primitives.Point) float64 {
+	delta := a.Subtract(b)
+	return math.Sqrt(float64(delta.Dot(delta)))
+}
+
+// Distance from v to the range [low, high], zero if v is inside it
+func pointDistanceToRange(v, low, high int) float64 {
+	if v < low {
+		return float64(low - v)
+	} else if v > high {
+		return float64(v - high)
+	}
+
+	return 0
+}
+
+func clamp(v, low, high int) int {
+	return maxInt(low, minInt(v, high))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,293 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/convert"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// Roads scattered over a square with each one connected to the road before it
+func randomRoads(random *rand.Rand, count, size int) []*world.Road {
+	roads := make([]*world.Road, count)
+	for i := range roads {
+		roads[i] = world.NewRoad(world.Id(2*i), world.NewNode(world.Id(2*i+1), random.Intn(size), random.Intn(size)))
+		if i > 0 && random.Intn(4) != 0 {
+			roads[i].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{roads[i-1]}})
+			roads[i-1].InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{roads[i]}})
+		}
+	}
+
+	return roads
+}
+
+func randomRectangle(random *rand.Rand, size int) *primitives.Rectangle {
+	// Some rectangles go past the edges of the roads
+	return primitives.NewRectangle(random.Intn(2*size)-size/2, random.Intn(2*size)-size/2,
+		random.Intn(2*size)-size/2, random.Intn(2*size)-size/2)
+}
+
+func linearWithin(bounds *primitives.Rectangle, roads []*world.Road) []*world.Road {
+	found := make([]*world.Road, 0)
+	for _, r := range roads {
+		if bounds.ContainsPoint(r.Point) {
+			found = append(found, r)
+		}
+	}
+
+	return found
+}
+
+func TestNewRoadIndex(t *testing.T) {
+	r1 := world.NewRoad(0, world.NewNode(1, 0, 0))
+	r2 := world.NewRoad(2, world.NewNode(3, 10, 0))
+	r3 := world.NewRoad(4, world.NewNode(5, 10, 10))
+	r1.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r2}})
+	r2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r1, r3}})
+	r3.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{r2}})
+
+	index := NewRoadIndex([]*world.Road{r1, r2, r3})
+	require.Equal(t, []*world.Road{r1, r2, r3}, index.Roads())
+	require.Equal(t, []*Segment{{From: r1, To: r2}, {From: r2, To: r3}}, index.Segments(),
+		"each pair of connected roads should be one segment")
+
+	empty := NewRoadIndex(nil)
+	require.Empty(t, empty.Within(primitives.NewRectangle(0, 0, 10, 10)))
+	require.Empty(t, empty.SegmentsWithin(primitives.NewRectangle(0, 0, 10, 10)))
+	require.Empty(t, empty.Nearest(primitives.NewPoint(0, 0), 3))
+}
+
+func TestRoadIndex_Within(t *testing.T) {
+	random := rand.New(rand.NewSource(39))
+	for _, cellSize := range []int{5, 20, 100, 1000} {
+		roads := randomRoads(random, 300, 500)
+		index := newRoadIndex(roads, cellSize)
+		for i := 0; i < 200; i++ {
+			bounds := randomRectangle(random, 500)
+			require.Equal(t, linearWithin(bounds, roads), index.Within(bounds), "cell size %d", cellSize)
+		}
+	}
+}
+
+func TestRoadIndex_SegmentsWithin(t *testing.T) {
+	random := rand.New(rand.NewSource(39))
+	for _, cellSize := range []int{5, 20, 100, 1000} {
+		roads := randomRoads(random, 300, 500)
+		index := newRoadIndex(roads, cellSize)
+		for i := 0; i < 200; i++ {
+			bounds := randomRectangle(random, 500)
+
+			expected := make([]*Segment, 0)
+			for _, s := range index.Segments() {
+				if overlaps(s.Bounds(), bounds) {
+					expected = append(expected, s)
+				}
+			}
+
+			require.Equal(t, expected, index.SegmentsWithin(bounds), "cell size %d", cellSize)
+		}
+	}
+}
+
+func TestBuildingIndex_Within(t *testing.T) {
+	random := rand.New(rand.NewSource(50))
+	buildings := make([]*world.Building, 200)
+	for i := range buildings {
+		x, y := random.Intn(500), random.Intn(500)
+		width, height := 1+random.Intn(40), 1+random.Intn(40)
+		if i%2 == 0 {
+			buildings[i] = newRectangleBuilding(world.Id(5*i), x, y, x+width, y+height)
+		} else {
+			// Turned on its side, starting from the point furthest to the right
+			buildings[i] = world.NewBuilding(world.Id(5*i), []*world.Node{
+				world.NewNode(world.Id(5*i+1), x+width, y+height/2),
+				world.NewNode(world.Id(5*i+2), x+width/2, y),
+				world.NewNode(world.Id(5*i+3), x, y+height/2),
+				world.NewNode(world.Id(5*i+4), x+width/2, y+height),
+			})
+		}
+	}
+
+	index := NewBuildingIndex(buildings)
+	for i := 0; i < 200; i++ {
+		bounds := randomRectangle(random, 500)
+
+		expected := make([]*world.Building, 0)
+		for _, b := range buildings {
+			if overlaps(layers.BuildingBounds(b), bounds) {
+				expected = append(expected, b)
+			}
+		}
+
+		require.Equal(t, expected, index.Within(bounds))
+	}
+
+	require.Empty(t, NewBuildingIndex(nil).Within(primitives.NewRectangle(0, 0, 10, 10)))
+
+	diamond := world.NewBuilding(0, []*world.Node{
+		world.NewNode(1, 20, 10), world.NewNode(2, 10, 0), world.NewNode(3, 0, 10), world.NewNode(4, 10, 20),
+	})
+	require.Equal(t, []*world.Building{diamond},
+		NewBuildingIndex([]*world.Building{diamond}).Within(primitives.NewRectangle(18, 12, 30, 30)),
+		"the right of the diamond should be found even though it is where the outline starts")
+}
+
+func TestRoadIndex_Nearest(t *testing.T) {
+	random := rand.New(rand.NewSource(39))
+	for _, cellSize := range []int{5, 20, 100, 1000} {
+		roads := randomRoads(random, 300, 500)
+		index := newRoadIndex(roads, cellSize)
+		for i := 0; i < 200; i++ {
+			p := primitives.NewPoint(random.Intn(1000)-250, random.Intn(1000)-250)
+			k := random.Intn(10)
+
+			expected := make([]*world.Road, len(roads))
+			copy(expected, roads)
+			sort.SliceStable(expected, func(a, b int) bool {
+				return pointDistance(expected[a].Point, p) < pointDistance(expected[b].Point, p)
+			})
+
+			require.Equal(t, expected[:k], index.Nearest(p, k), "cell size %d", cellSize)
+		}
+	}
+
+	roads := randomRoads(random, 5, 10)
+	require.Len(t, NewRoadIndex(roads).Nearest(primitives.NewPoint(0, 0), 10), 5,
+		"asking for more roads than there are should give all of them")
+}
+
+func TestRoadIndex_Split(t *testing.T) {
+	random := rand.New(rand.NewSource(40))
+	roads := randomRoads(random, 200, 500)
+	index := newRoadIndex(roads, 20)
+
+	for i := 0; i < 100; i++ {
+		s := index.Segments()[random.Intn(len(index.Segments()))]
+		// Halfway along the segment is always inside the grid
+		x, y := (s.From.X()+s.To.X())/2, (s.From.Y()+s.To.Y())/2
+		junction := world.NewRoad(world.Id(1000+2*i), world.NewNode(world.Id(1001+2*i), x, y))
+
+		index.Split(s, junction)
+		require.Contains(t, junction.Connections(), s.From)
+		require.Contains(t, junction.Connections(), s.To)
+		require.NotContains(t, s.From.Connections(), s.To)
+		require.NotContains(t, s.To.Connections(), s.From)
+		require.NotContains(t, index.Segments(), s)
+	}
+
+	// The index should answer queries the same way as a new index over the split roads
+	rebuilt := newRoadIndex(index.Roads(), 20)
+	require.Len(t, rebuilt.Segments(), len(index.Segments()))
+	for i := 0; i < 200; i++ {
+		bounds := randomRectangle(random, 500)
+		require.Equal(t, linearWithin(bounds, index.Roads()), index.Within(bounds))
+		require.Len(t, index.SegmentsWithin(bounds), len(rebuilt.SegmentsWithin(bounds)))
+	}
+}
+
+func TestRoadIndex_Unsplit(t *testing.T) {
+	random := rand.New(rand.NewSource(40))
+	roads := randomRoads(random, 200, 500)
+	index := newRoadIndex(roads, 20)
+
+	connections := make(map[*world.Road][]*world.Road, len(roads))
+	for _, r := range roads {
+		connections[r] = append(r.Connections()[:0:0], r.Connections()...)
+	}
+
+	segments := make([]Segment, 0, len(index.Segments()))
+	for _, s := range index.Segments() {
+		segments = append(segments, *s)
+	}
+
+	junctions := make([]*world.Road, 0)
+	for i := 0; i < 100; i++ {
+		s := index.Segments()[random.Intn(len(index.Segments()))]
+		x, y := (s.From.X()+s.To.X())/2, (s.From.Y()+s.To.Y())/2
+		junction := world.NewRoad(world.Id(1000+2*i), world.NewNode(world.Id(1001+2*i), x, y))
+		index.Split(s, junction)
+		junctions = append(junctions, junction)
+	}
+
+	// Taking the junctions out in any order should leave the roads as they were before they were split
+	random.Shuffle(len(junctions), func(a, b int) { junctions[a], junctions[b] = junctions[b], junctions[a] })
+	index.Unsplit(junctions[:50])
+	index.Unsplit(junctions[50:])
+
+	require.Equal(t, roads, index.Roads())
+	for _, r := range roads {
+		require.Equal(t, connections[r], r.Connections())
+	}
+
+	require.Len(t, index.Segments(), len(segments))
+	for i, s := range index.Segments() {
+		require.ElementsMatch(t, []*world.Road{segments[i].From, segments[i].To}, []*world.Road{s.From, s.To})
+	}
+
+	rebuilt := newRoadIndex(roads, 20)
+	for i := 0; i < 200; i++ {
+		bounds := randomRectangle(random, 500)
+		require.Equal(t, linearWithin(bounds, roads), index.Within(bounds))
+		require.Len(t, index.SegmentsWithin(bounds), len(rebuilt.SegmentsWithin(bounds)))
+	}
+}
+
+func TestSegment_ClosestPointTo(t *testing.T) {
+	s := &Segment{From: world.NewRoad(0, world.NewNode(1, 0, 0)), To: world.NewRoad(2, world.NewNode(3, 10, 0))}
+	p, distance := s.ClosestPointTo(primitives.NewPoint(4, 3))
+	require.Equal(t, primitives.NewPoint(4, 0), p)
+	require.Equal(t, 3.0, distance)
+
+	s = &Segment{From: world.NewRoad(0, world.NewNode(1, 5, 5)), To: world.NewRoad(2, world.NewNode(3, 5, 5))}
+	p, distance = s.ClosestPointTo(primitives.NewPoint(8, 9))
+	require.Equal(t, primitives.NewPoint(5, 5), p)
+	require.Equal(t, 5.0, distance)
+}
+
+func BenchmarkWithinBounds(b *testing.B) {
+	random := rand.New(rand.NewSource(39))
+	for _, count := range []int{1000, 10000, 100000} {
+		roads := randomRoads(random, count, 20000)
+		bounds := make([]*primitives.Rectangle, 1000)
+		for i := range bounds {
+			x, y := random.Intn(20000), random.Intn(20000)
+			bounds[i] = primitives.NewRectangle(x, y, x+40, y+40)
+		}
+
+		b.Run(fmt.Sprintf("linear/roads=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearWithin(bounds[i%len(bounds)], roads)
+			}
+		})
+
+		index := NewRoadIndex(roads)
+		b.Run(fmt.Sprintf("index/roads=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.Within(bounds[i%len(bounds)])
+			}
+		})
+	}
+}
+
+func BenchmarkInitBuildingConnections(b *testing.B) {
+	metadata := world.NewMetadata(4000, 4000, 0.0, 0.0, 0.01, 0.01)
+	result := testutil.GridCity(40, 0.01, 1)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		w, _, err := convert.Convert(metadata, result)
+		require.NoError(b, err)
+		b.StartTimer()
+
+		_, err = InitBuildingConnections(w, WithMinConnections(2), WithTargetAverage(2.0))
+		require.True(b, err == nil || errors.Is(err, ErrTargetNotReached))
+	}
+}
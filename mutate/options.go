@@ -0,0 +1,133 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"math"
+)
+
+// Search radius used on the first pass over the buildings
+const initialConnectDistance = 4
+
+// The radius doubles on each pass so this is more than any world needs
+const defaultMaxIterations = 16
+
+// Limits how many connections each building gets and how far InitBuildingConnections looks for roads
+type Options struct {
+	// Buildings with fewer connections than this are searched again with a larger radius. Values less than 1 use 1.
+	MinConnections int
+	// Closest connections kept for each building. Values less than 1 keep every connection.
+	MaxConnections int
+	// Longest connection allowed between a building and a road. Values that are not positive allow any length.
+	MaxLength float64
+	// Picks the connections each building keeps. Nil uses DefaultStrategy.
+	Strategy ConnectionStrategy
+	// Worst scoring connections are removed down to this average. Values that are not positive keep every connection.
+	TargetAverage float64
+
+	// Largest search radius. Values less than 1 use the larger dimension of the world, if there is one.
+	MaxRadius int
+	// Most passes over the buildings, where values less than 1 use the default
+	MaxIterations int
+}
+
+type Option func(o *Options)
+
+func WithMinConnections(connections int) Option {
+	return func(o *Options) {
+		o.MinConnections = connections
+	}
+}
+
+func WithMaxConnections(connections int) Option {
+	return func(o *Options) {
+		o.MaxConnections = connections
+	}
+}
+
+func WithMaxLength(length float64) Option {
+	return func(o *Options) {
+		o.MaxLength = length
+	}
+}
+
+func WithStrategy(strategy ConnectionStrategy) Option {
+	return func(o *Options) {
+		o.Strategy = strategy
+	}
+}
+
+func WithTargetAverage(average float64) Option {
+	return func(o *Options) {
+		o.TargetAverage = average
+	}
+}
+
+func WithMaxRadius(radius int) Option {
+	return func(o *Options) {
+		o.MaxRadius = radius
+	}
+}
+
+func WithMaxIterations(iterations int) Option {
+	return func(o *Options) {
+		o.MaxIterations = iterations
+	}
+}
+
+func (o *Options) check() error {
+	if o.MaxConnections > 0 && o.MaxConnections < o.minConnections() {
+		return fmt.Errorf("max connections of %d is less than the min connections of %d", o.MaxConnections,
+			o.minConnections())
+	} else if math.IsNaN(o.MaxLength) || math.IsNaN(o.TargetAverage) {
+		return errors.New("max length and target average must be numbers")
+	}
+
+	return nil
+}
+
+func (o *Options) minConnections() int {
+	return maxInt(o.MinConnections, 1)
+}
+
+// Largest search radius, which is never more than is needed to find a connection of the max length
+func (o *Options) maxRadius(meta *world.Metadata) int {
+	radius := math.MaxInt32
+	if o.MaxRadius > 0 {
+		radius = o.MaxRadius
+	} else if meta != nil {
+		radius = maxInt(meta.Width(), meta.Height())
+	}
+
+	if o.MaxLength > 0 && o.MaxLength < float64(radius) {
+		// Connections are found when their rounded length is less than the radius
+		radius = int(math.Round(o.MaxLength)) + 1
+	}
+
+	return radius
+}
+
+func (o *Options) maxLength() float64 {
+	if o.MaxLength > 0 {
+		return o.MaxLength
+	}
+
+	return math.Inf(1)
+}
+
+func (o *Options) strategy() ConnectionStrategy {
+	if o.Strategy != nil {
+		return o.Strategy
+	}
+
+	return DefaultStrategy
+}
+
+func (o *Options) maxIterations() int {
+	if o.MaxIterations > 0 {
+		return o.MaxIterations
+	}
+
+	return defaultMaxIterations
+}
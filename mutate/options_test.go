@@ -0,0 +1,43 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	meta := world.NewMetadata(300, 400, 0.0, 0.0, 1.0, 1.0)
+
+	options := new(Options)
+	require.NoError(t, options.check())
+	require.Equal(t, 1, options.minConnections())
+	require.Equal(t, math.Inf(1), options.maxLength())
+	require.Equal(t, math.MaxInt32, options.maxRadius(nil))
+	require.Equal(t, 400, options.maxRadius(meta))
+	require.Equal(t, defaultMaxIterations, options.maxIterations())
+	require.Equal(t, DefaultStrategy, options.strategy())
+
+	WithMinConnections(2)(options)
+	WithMaxConnections(3)(options)
+	WithMaxRadius(50)(options)
+	WithMaxIterations(3)(options)
+	WithStrategy(NewAngularStrategy())(options)
+	require.NoError(t, options.check())
+	require.Equal(t, 2, options.minConnections())
+	require.Equal(t, 50, options.maxRadius(meta))
+	require.Equal(t, 3, options.maxIterations())
+	require.Equal(t, NewAngularStrategy(), options.strategy())
+
+	WithMaxLength(20.4)(options)
+	require.Equal(t, 20.4, options.maxLength())
+	require.Equal(t, 21, options.maxRadius(meta), "the radius only needs to reach the longest connection")
+
+	WithMaxConnections(1)(options)
+	require.Error(t, options.check())
+
+	WithMaxConnections(0)(options)
+	WithTargetAverage(math.NaN())(options)
+	require.Error(t, options.check())
+}
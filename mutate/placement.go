@@ -0,0 +1,340 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/world-generator/graph"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/pathfind"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Returned when no base has enough spawn points far enough away from it
+var ErrNoPlacement = errors.New("no base has enough spawn points")
+
+const defaultSpawns = 3
+
+const defaultBaseCandidates = 10
+
+// Most graph nodes that shortest paths are found from when estimating how many paths pass through each node
+const betweennessSamples = 32
+
+type PlacementOptions struct {
+	// Values less than 1 use the default
+	Spawns int
+	// Shortest distance along the roads from every spawn point to the base
+	MinPathLength float64
+	// Road nodes this close to the edge of the world can be spawns. Values less than 1 use a twentieth of the world.
+	EdgeMargin int
+	// Puts the base in a building, at the road node it is connected to
+	BuildingBase bool
+	// Values less than 1 use the default
+	BaseCandidates int
+	Seed           int64
+}
+
+type PlacementOption func(o *PlacementOptions)
+
+func WithSpawns(spawns int) PlacementOption {
+	return func(o *PlacementOptions) {
+		o.Spawns = spawns
+	}
+}
+
+func WithMinPathLength(length float64) PlacementOption {
+	return func(o *PlacementOptions) {
+		o.MinPathLength = length
+	}
+}
+
+func WithEdgeMargin(margin int) PlacementOption {
+	return func(o *PlacementOptions) {
+		o.EdgeMargin = margin
+	}
+}
+
+func WithBuildingBase() PlacementOption {
+	return func(o *PlacementOptions) {
+		o.BuildingBase = true
+	}
+}
+
+func WithBaseCandidates(candidates int) PlacementOption {
+	return func(o *PlacementOptions) {
+		o.BaseCandidates = candidates
+	}
+}
+
+func WithSeed(seed int64) PlacementOption {
+	return func(o *PlacementOptions) {
+		o.Seed = seed
+	}
+}
+
+type PlacementReport struct {
+	// In the same order as the spawns
+	PathLengths []float64
+	BasesTried  int
+}
+
+// Picks spawn points at the edge of the world and a base near the middle that many paths pass through, with every
+// spawn at least the minimum path length from the base
+func PlaceSpawnsAndBase(w *layers.World, opts ...PlacementOption) (*PlacementReport, error) {
+	options := new(PlacementOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if math.IsNaN(options.MinPathLength) {
+		return nil, errors.New("min path length must be a number")
+	}
+
+	spawns := options.Spawns
+	if spawns < 1 {
+		spawns = defaultSpawns
+	}
+
+	maxCandidates := options.BaseCandidates
+	if maxCandidates < 1 {
+		maxCandidates = defaultBaseCandidates
+	}
+
+	roads := w.Roads()
+	random := rand.New(rand.NewSource(options.Seed))
+	bounds := worldBounds(w.Meta(), roads)
+	edge := edgeRoads(roads, bounds, options.EdgeMargin)
+
+	// Shuffled once so that the order that spawns are picked in only depends on the seed
+	random.Shuffle(len(edge), func(i, j int) {
+		edge[i], edge[j] = edge[j], edge[i]
+	})
+
+	g := graph.Contract(w)
+	score := roadScore(g, bounds, random)
+
+	var candidates []*layers.Base
+	if options.BuildingBase {
+		candidates = buildingBases(w.Buildings(), score)
+	} else {
+		candidates = roadBases(g, roads, score)
+	}
+
+	report := new(PlacementReport)
+	for _, base := range candidates {
+		if report.BasesTried == maxCandidates {
+			break
+		}
+
+		report.BasesTried++
+		field, err := pathfind.DistanceField(roads, []*world.Road{base.Road})
+		if err != nil {
+			return nil, err
+		}
+
+		picked := pickSpawns(edge, spawns, func(r *world.Road) bool {
+			cost := field.Cost(r)
+			return r != base.Road && !math.IsInf(cost, 1) && cost >= options.MinPathLength
+		})
+
+		if len(picked) < spawns {
+			continue
+		}
+
+		w.Spawns = picked
+		w.Base = base
+
+		report.PathLengths = make([]float64, len(picked))
+		for i, r := range picked {
+			report.PathLengths[i] = field.Cost(r)
+		}
+
+		return report, nil
+	}
+
+	return report, fmt.Errorf("%w after trying %d bases", ErrNoPlacement, report.BasesTried)
+}
+
+// Size of the world, or of the roads when the world has no metadata
+func worldBounds(meta *world.Metadata, roads []*world.Road) *primitives.Rectangle {
+	if meta != nil {
+		return primitives.NewRectangle(0, 0, meta.Width(), meta.Height())
+	}
+
+	return roadBounds(roads)
+}
+
+// Road nodes within the margin of the edge of the bounds
+func edgeRoads(roads []*world.Road, bounds *primitives.Rectangle, margin int) []*world.Road {
+	if margin < 1 {
+		margin = maxInt(maxInt(bounds.Width(), bounds.Height())/20, 1)
+	}
+
+	edge := make([]*world.Road, 0)
+	for _, r := range roads {
+		if r.X()-bounds.X1() < margin || bounds.X2()-r.X() < margin ||
+			r.Y()-bounds.Y1() < margin || bounds.Y2()-r.Y() < margin {
+			edge = append(edge, r)
+		}
+	}
+
+	return edge
+}
+
+// Picks the first allowed candidate, then each time the allowed candidate furthest from the spawns picked so far
+func pickSpawns(candidates []*world.Road, count int, allowed func(r *world.Road) bool) []*world.Road {
+	remaining := make([]*world.Road, 0, len(candidates))
+	for _, c := range candidates {
+		if allowed(c) {
+			remaining = append(remaining, c)
+		}
+	}
+
+	picked := make([]*world.Road, 0, count)
+	closest := make([]float64, len(remaining))
+	for i := range closest {
+		closest[i] = math.Inf(1)
+	}
+
+	for len(picked) < count && len(picked) < len(remaining) {
+		best := -1
+		for i := range remaining {
+			if closest[i] >= 0 && (best == -1 || closest[i] > closest[best]) {
+				best = i
+			}
+		}
+
+		next := remaining[best]
+		picked = append(picked, next)
+		closest[best] = -1
+		for i, r := range remaining {
+			if closest[i] >= 0 {
+				closest[i] = math.Min(closest[i], pointDistance(r.Point, next.Point))
+			}
+		}
+	}
+
+	return picked
+}
+
+// Scores road nodes as bases by how many shortest paths pass through them and how close they are to the middle
+func roadScore(g *graph.Graph, bounds *primitives.Rectangle, random *rand.Rand) func(r *world.Road) float64 {
+	centerX := float64(bounds.X1()+bounds.X2()) / 2
+	centerY := float64(bounds.Y1()+bounds.Y2()) / 2
+	farthest := math.Hypot(float64(bounds.Width()), float64(bounds.Height())) / 2
+
+	sources := make([]*graph.Node, minInt(len(g.Nodes), betweennessSamples))
+	for i, j := range random.Perm(len(g.Nodes))[:len(sources)] {
+		sources[i] = g.Nodes[j]
+	}
+
+	nodePaths, edgePaths := betweenness(sources)
+	return func(r *world.Road) float64 {
+		centrality := 1.0
+		if farthest > 0 {
+			centrality = math.Max(0, 1-math.Hypot(float64(r.X())-centerX, float64(r.Y())-centerY)/farthest)
+		}
+
+		paths := 0.0
+		if n := g.NodeAt(r); n != nil {
+			paths = nodePaths[n]
+		} else if e := g.EdgeThrough(r); e != nil {
+			paths = edgePaths[e]
+		}
+
+		return (paths + 1) * centrality
+	}
+}
+
+// Road node bases from the best to the worst, using only intersections unless there are none
+func roadBases(g *graph.Graph, roads []*world.Road, score func(r *world.Road) float64) []*layers.Base {
+	candidates := make([]*world.Road, 0)
+	for _, n := range g.Nodes {
+		if len(n.Edges) >= 3 {
+			candidates = append(candidates, n.Road)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = append(candidates, roads...)
+	}
+
+	scores := make(map[*world.Road]float64, len(candidates))
+	for _, r := range candidates {
+		scores[r] = score(r)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+
+	bases := make([]*layers.Base, len(candidates))
+	for i, r := range candidates {
+		bases[i] = &layers.Base{Road: r}
+	}
+
+	return bases
+}
+
+// Building bases from the best to the worst, each at the best scoring road node the building is connected to
+func buildingBases(buildings []*world.Building, score func(r *world.Road) float64) []*layers.Base {
+	bases := make([]*layers.Base, 0, len(buildings))
+	scores := make(map[*layers.Base]float64, len(buildings))
+	distances := make(map[*layers.Base]float64, len(buildings))
+	for _, b := range buildings {
+		var best *world.Connection
+		bestScore := 0.0
+		for _, c := range b.Connections() {
+			s := score(c.Road())
+			if best == nil || s > bestScore || (s == bestScore && c.Distance() < best.Distance()) {
+				best, bestScore = c, s
+			}
+		}
+
+		if best != nil {
+			base := &layers.Base{Road: best.Road(), Building: b}
+			bases = append(bases, base)
+			scores[base] = bestScore
+			distances[base] = best.Distance()
+		}
+	}
+
+	sort.SliceStable(bases, func(i, j int) bool {
+		if scores[bases[i]] != scores[bases[j]] {
+			return scores[bases[i]] > scores[bases[j]]
+		}
+
+		return distances[bases[i]] < distances[bases[j]]
+	})
+
+	return bases
+}
+
+// How many shortest paths from the sources pass through each node and along each edge, using Brandes' algorithm. The
+// sources and ends of each path aren't counted for the nodes.
+func betweenness(sources []*graph.Node) (nodes map[*graph.Node]float64, edges map[*graph.Edge]float64) {
+	nodes = make(map[*graph.Node]float64)
+	edges = make(map[*graph.Edge]float64)
+	for _, s := range sources {
+		tree := graph.ShortestTree(s)
+
+		// Goes back from the furthest nodes adding up how much each node depends on the ones further along
+		dependency := make(map[*graph.Node]float64, len(tree.Order))
+		for i := len(tree.Order) - 1; i > 0; i-- {
+			n := tree.Order[i]
+			for _, e := range tree.Previous[n] {
+				p := e.Other(n)
+				paths := tree.Counts[p] / tree.Counts[n] * (1 + dependency[n])
+				dependency[p] += paths
+				edges[e] += paths
+			}
+
+			nodes[n] += dependency[n]
+		}
+	}
+
+	return nodes, edges
+}
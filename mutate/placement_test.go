@@ -0,0 +1,232 @@
+package mutate
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/graph"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+// A square grid of road nodes with each one joined to the ones beside it. The grid fills a world that is
+// (size-1)*spacing across.
+func roadGrid(size, spacing int) (*layers.World, [][]*world.Road) {
+	grid := make([][]*world.Road, size)
+	roads := make([]*world.Road, 0, size*size)
+	for y := range grid {
+		grid[y] = make([]*world.Road, size)
+		for x := range grid[y] {
+			id := world.Id(2 * len(roads))
+			grid[y][x] = world.NewRoad(id, world.NewNode(id+1, x*spacing, y*spacing))
+			roads = append(roads, grid[y][x])
+
+			if x > 0 {
+				testutil.Chain(grid[y][x-1], grid[y][x])
+			}
+
+			if y > 0 {
+				testutil.Chain(grid[y-1][x], grid[y][x])
+			}
+		}
+	}
+
+	width := (size - 1) * spacing
+	meta := world.NewMetadata(width, width, 0.0, 0.0, 1.0, 1.0)
+	return layers.NewWorld(world.NewContainer(meta, roads, []*world.Building{})), grid
+}
+
+func manhattan(a, b *world.Road) float64 {
+	return math.Abs(float64(a.X()-b.X())) + math.Abs(float64(a.Y()-b.Y()))
+}
+
+func onEdge(r *world.Road, width int) bool {
+	return r.X() == 0 || r.Y() == 0 || r.X() == width || r.Y() == width
+}
+
+func TestPlaceSpawnsAndBase(t *testing.T) {
+	w, grid := roadGrid(5, 100)
+	center := grid[2][2]
+
+	report, err := PlaceSpawnsAndBase(w, WithSeed(48))
+	require.NoError(t, err)
+	require.Equal(t, 1, report.BasesTried)
+	require.Equal(t, &layers.Base{Road: center}, w.Base, "the middle of the grid has the most paths through it")
+	require.Len(t, w.Spawns, defaultSpawns)
+
+	seen := make(map[*world.Road]bool)
+	for i, r := range w.Spawns {
+		require.True(t, onEdge(r, 400), "spawns should be at the edge of the world")
+		require.False(t, seen[r], "spawns should not repeat")
+		require.Equal(t, manhattan(r, center), report.PathLengths[i])
+		seen[r] = true
+	}
+
+	// The same seed always places the same spawns
+	spawns := w.Spawns
+	_, err = PlaceSpawnsAndBase(w, WithSeed(48))
+	require.NoError(t, err)
+	require.Equal(t, spawns, w.Spawns)
+
+	differs := false
+	for seed := int64(0); seed < 10; seed++ {
+		_, err = PlaceSpawnsAndBase(w, WithSeed(seed))
+		require.NoError(t, err)
+		differs = differs || w.Spawns[0] != spawns[0]
+	}
+
+	require.True(t, differs, "other seeds should place the spawns elsewhere")
+}
+
+func TestPlaceSpawnsAndBase_Options(t *testing.T) {
+	w, grid := roadGrid(5, 100)
+	corners := []*world.Road{grid[0][0], grid[0][4], grid[4][0], grid[4][4]}
+
+	// Only the corners are far enough from the middle
+	report, err := PlaceSpawnsAndBase(w, WithSpawns(4), WithMinPathLength(400))
+	require.NoError(t, err)
+	require.ElementsMatch(t, corners, w.Spawns)
+	require.Equal(t, []float64{400, 400, 400, 400}, report.PathLengths)
+
+	// Spawns are spread out so the second spawn is in the opposite corner to the first
+	_, err = PlaceSpawnsAndBase(w, WithSpawns(2), WithMinPathLength(400))
+	require.NoError(t, err)
+	require.Equal(t, 800.0, manhattan(w.Spawns[0], w.Spawns[1]))
+
+	// A wider margin lets the roads one step in from the edge be spawns
+	_, err = PlaceSpawnsAndBase(w, WithSpawns(24), WithEdgeMargin(150))
+	require.NoError(t, err)
+	require.Len(t, w.Spawns, 24)
+
+	// Bases that are further out are tried when the middle doesn't have enough spawns far enough away
+	report, err = PlaceSpawnsAndBase(w, WithSpawns(1), WithMinPathLength(600))
+	require.NoError(t, err)
+	require.Greater(t, report.BasesTried, 1)
+	require.GreaterOrEqual(t, report.PathLengths[0], 600.0)
+
+	report, err = PlaceSpawnsAndBase(w, WithMinPathLength(900))
+	require.True(t, errors.Is(err, ErrNoPlacement))
+	require.Equal(t, defaultBaseCandidates, report.BasesTried)
+
+	report, err = PlaceSpawnsAndBase(w, WithMinPathLength(900), WithBaseCandidates(2))
+	require.True(t, errors.Is(err, ErrNoPlacement))
+	require.Equal(t, 2, report.BasesTried)
+
+	_, err = PlaceSpawnsAndBase(w, WithMinPathLength(math.NaN()))
+	require.Error(t, err)
+}
+
+func TestPlaceSpawnsAndBase_BuildingBase(t *testing.T) {
+	w, grid := roadGrid(5, 100)
+
+	// Two buildings beside the same road, the second one is closer
+	b1 := newSquareBuilding(100, 105, 130)
+	b2 := newSquareBuilding(101, 105, 110)
+	b1.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{closestConnection(b1, grid[1][1])}})
+	b2.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{closestConnection(b2, grid[1][1])}})
+	w.Container = world.NewContainer(w.Meta(), w.Roads(), []*world.Building{b1, b2})
+
+	_, err := PlaceSpawnsAndBase(w, WithBuildingBase())
+	require.NoError(t, err)
+	require.Equal(t, &layers.Base{Road: grid[1][1], Building: b2}, w.Base)
+
+	w.Container = world.NewContainer(w.Meta(), w.Roads(), []*world.Building{})
+	_, err = PlaceSpawnsAndBase(w, WithBuildingBase())
+	require.True(t, errors.Is(err, ErrNoPlacement), "there are no buildings to put the base in")
+}
+
+// Replaces the road between a and b with two roads that meet at a new road node halfway between them
+func splitRoad(a, b *world.Road, id world.Id) *world.Road {
+	middle := world.NewRoad(id, world.NewNode(id+1, (a.X()+b.X())/2, (a.Y()+b.Y())/2))
+	for _, pair := range [][2]*world.Road{{a, b}, {b, a}} {
+		connections := make([]*world.Road, 0, len(pair[0].Connections()))
+		for _, c := range pair[0].Connections() {
+			if c == pair[1] {
+				c = middle
+			}
+
+			connections = append(connections, c)
+		}
+
+		pair[0].InitOperation(&world.RoadInitOperation{NewConnections: connections})
+	}
+
+	testutil.Chain(a, middle, b)
+	return middle
+}
+
+func TestPlaceSpawnsAndBase_BuildingOnEdge(t *testing.T) {
+	w, grid := roadGrid(5, 100)
+
+	// The building near the middle is connected to a road node between two intersections. The other building is at an
+	// intersection on the edge of the world.
+	junction := splitRoad(grid[2][1], grid[2][2], 100)
+	central := newSquareBuilding(200, 145, 205)
+	outer := newSquareBuilding(300, 95, 5)
+	central.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{
+		closestConnection(central, junction)}})
+	outer.InitOperation(&world.BuildingInitOperation{NewConnections: []*world.Connection{
+		closestConnection(outer, grid[0][1])}})
+	w.Container = world.NewContainer(w.Meta(), append(w.Roads(), junction), []*world.Building{outer, central})
+
+	report, err := PlaceSpawnsAndBase(w, WithBuildingBase())
+	require.NoError(t, err)
+	require.Equal(t, 1, report.BasesTried)
+	require.Equal(t, &layers.Base{Road: junction, Building: central}, w.Base)
+
+	w.Container = world.NewContainer(w.Meta(), w.Roads(), []*world.Building{central})
+	_, err = PlaceSpawnsAndBase(w, WithBuildingBase())
+	require.NoError(t, err)
+	require.Equal(t, &layers.Base{Road: junction, Building: central}, w.Base, "the only building can be the base")
+}
+
+func TestPlaceSpawnsAndBase_NoRoads(t *testing.T) {
+	w := layers.NewWorld(world.NewContainer(nil, []*world.Road{}, []*world.Building{}))
+	report, err := PlaceSpawnsAndBase(w)
+	require.True(t, errors.Is(err, ErrNoPlacement))
+	require.Equal(t, 0, report.BasesTried)
+	require.Empty(t, w.Spawns)
+	require.Nil(t, w.Base)
+}
+
+func TestBetweenness(t *testing.T) {
+	// A star with a long arm, so every path between the arms goes through the middle
+	middle := world.NewRoad(0, world.NewNode(1, 0, 0))
+	a := world.NewRoad(2, world.NewNode(3, 10, 0))
+	b := world.NewRoad(4, world.NewNode(5, 0, 10))
+	c := world.NewRoad(6, world.NewNode(7, -10, 0))
+	c2 := world.NewRoad(8, world.NewNode(9, -20, 0))
+	testutil.Chain(a, middle, b)
+	testutil.Chain(middle, c, c2)
+
+	g := graph.Contract(layers.NewWorld(world.NewContainer(nil, []*world.Road{middle, a, b, c, c2},
+		[]*world.Building{})))
+	require.Len(t, g.Nodes, 4)
+
+	paths, edgePaths := betweenness(g.Nodes)
+	require.Equal(t, 6.0, paths[g.NodeAt(middle)], "each ordered pair of arms goes through the middle")
+	require.Equal(t, 0.0, paths[g.NodeAt(a)])
+	require.Equal(t, 0.0, paths[g.NodeAt(c2)])
+	require.Equal(t, 6.0, edgePaths[g.EdgeThrough(c)], "every path to or from the end of the long arm goes along it")
+
+	paths, edgePaths = betweenness([]*graph.Node{g.NodeAt(a)})
+	require.Equal(t, 2.0, paths[g.NodeAt(middle)])
+	require.Equal(t, 1.0, edgePaths[g.EdgeThrough(c)])
+}
+
+func TestPickSpawns(t *testing.T) {
+	roads := []*world.Road{
+		world.NewRoad(0, world.NewNode(1, 0, 0)),
+		world.NewRoad(2, world.NewNode(3, 10, 0)),
+		world.NewRoad(4, world.NewNode(5, 100, 0)),
+		world.NewRoad(6, world.NewNode(7, 50, 0)),
+	}
+
+	all := func(*world.Road) bool { return true }
+	require.Equal(t, []*world.Road{roads[0], roads[2], roads[3]}, pickSpawns(roads, 3, all))
+	require.Equal(t, []*world.Road{roads[1], roads[2]}, pickSpawns(roads, 2, func(r *world.Road) bool { return r != roads[0] }))
+	require.Len(t, pickSpawns(roads, 10, all), 4)
+	require.Empty(t, pickSpawns(roads, 2, func(*world.Road) bool { return false }))
+}
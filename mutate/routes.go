@@ -0,0 +1,258 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/pathfind"
+	"math"
+)
+
+// Returned when routes are generated for a world that has no base to route to
+var ErrNoBase = errors.New("the world has no base")
+
+const defaultRoutes = 3
+
+const defaultRoutePenalty = 2.0
+
+const defaultMaxOverlap = 0.8
+
+type RouteOptions struct {
+	// Routes from each spawn point. Values less than 1 use the default.
+	Routes int
+	// Multiplies the cost of a step each time an earlier route used it. Values that are not more than 1 use the default.
+	Penalty float64
+	// Largest share of a route that can overlap any one earlier route. Values not between 0 and 1 use the default.
+	MaxOverlap float64
+	// Paths searched from each spawn point. Values less than 1 use four times the number of routes.
+	MaxAttempts int
+	Weights     map[layers.RoadClass]float64
+}
+
+type RouteOption func(o *RouteOptions)
+
+func WithRoutes(routes int) RouteOption {
+	return func(o *RouteOptions) {
+		o.Routes = routes
+	}
+}
+
+func WithRoutePenalty(penalty float64) RouteOption {
+	return func(o *RouteOptions) {
+		o.Penalty = penalty
+	}
+}
+
+func WithMaxOverlap(overlap float64) RouteOption {
+	return func(o *RouteOptions) {
+		o.MaxOverlap = overlap
+	}
+}
+
+func WithMaxAttempts(attempts int) RouteOption {
+	return func(o *RouteOptions) {
+		o.MaxAttempts = attempts
+	}
+}
+
+func WithRouteWeights(weights map[layers.RoadClass]float64) RouteOption {
+	return func(o *RouteOptions) {
+		o.Weights = weights
+	}
+}
+
+func (o *RouteOptions) routes() int {
+	if o.Routes > 0 {
+		return o.Routes
+	}
+
+	return defaultRoutes
+}
+
+func (o *RouteOptions) penalty() float64 {
+	if o.Penalty > 1 {
+		return o.Penalty
+	}
+
+	return defaultRoutePenalty
+}
+
+func (o *RouteOptions) maxOverlap() float64 {
+	if o.MaxOverlap > 0 && o.MaxOverlap <= 1 {
+		return o.MaxOverlap
+	}
+
+	return defaultMaxOverlap
+}
+
+func (o *RouteOptions) maxAttempts() int {
+	if o.MaxAttempts > 0 {
+		return o.MaxAttempts
+	}
+
+	return 4 * o.routes()
+}
+
+// A step between two road nodes in either direction
+type routeStep struct{ a, b *world.Road }
+
+func newRouteStep(from, to *world.Road) routeStep {
+	if to.Id() < from.Id() {
+		from, to = to, from
+	}
+
+	return routeStep{from, to}
+}
+
+// Finds a few different routes from each spawn point to the base, each the cheapest path once the steps of earlier
+// routes are made more expensive. Spawn points that can't reach the base are left without routes.
+func GenerateRoutes(w *layers.World, opts ...RouteOption) error {
+	options := new(RouteOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if w.Base == nil {
+		return ErrNoBase
+	}
+
+	routes := make([]*layers.Route, 0, len(w.Spawns)*options.routes())
+	for _, spawn := range w.Spawns {
+		found, err := spawnRoutes(w, spawn, options)
+		if err != nil {
+			return fmt.Errorf("routes from spawn %d: %w", spawn.Id(), err)
+		}
+
+		routes = append(routes, found...)
+	}
+
+	w.Routes = routes
+	return nil
+}
+
+func spawnRoutes(w *layers.World, spawn *world.Road, options *RouteOptions) ([]*layers.Route, error) {
+	uses := make(map[routeStep]int)
+	penalty := options.penalty()
+	search := []pathfind.Option{
+		pathfind.WithPenalty(func(from, to *world.Road) float64 {
+			return math.Pow(penalty, float64(uses[newRouteStep(from, to)]))
+		}),
+	}
+
+	if options.Weights != nil {
+		search = append(search, pathfind.WithClassWeights(w.RoadClasses, options.Weights))
+	}
+
+	routes := make([]*layers.Route, 0, options.routes())
+	steps := make([]map[routeStep]bool, 0, options.routes())
+	var chokePoints []*world.Road
+
+	for attempt := 0; attempt < options.maxAttempts() && len(routes) < options.routes(); attempt++ {
+		path, err := pathfind.Shortest([]*world.Road{spawn}, []*world.Road{w.Base.Road}, search...)
+		if errors.Is(err, pathfind.ErrNoPath) {
+			return routes, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pathSteps := make(map[routeStep]bool, len(path.Roads))
+		for i := 1; i < len(path.Roads); i++ {
+			step := newRouteStep(path.Roads[i-1], path.Roads[i])
+			pathSteps[step] = true
+			uses[step]++
+		}
+
+		if !distinctRoute(path, pathSteps, steps, options.maxOverlap()) {
+			continue
+		}
+
+		if chokePoints == nil {
+			// Every path between the same ends passes through the same choke points
+			chokePoints = routeChokePoints(path.Roads)
+		}
+
+		steps = append(steps, pathSteps)
+		routes = append(routes, &layers.Route{Spawn: spawn, Roads: path.Roads, Length: path.Length,
+			ChokePoints: chokePoints})
+	}
+
+	return routes, nil
+}
+
+// Whether the path is different from every earlier route and doesn't overlap any of them by too much
+func distinctRoute(path *pathfind.Path, pathSteps map[routeStep]bool, earlier []map[routeStep]bool, maxOverlap float64) bool {
+	for _, other := range earlier {
+		if sameSteps(pathSteps, other) {
+			return false
+		}
+
+		if path.Length > 0 && overlapLength(path.Roads, other)/path.Length > maxOverlap {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameSteps(a, b map[routeStep]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for step := range a {
+		if !b[step] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Length of the steps along the roads that are also in the other steps
+func overlapLength(roads []*world.Road, other map[routeStep]bool) float64 {
+	overlap := 0.0
+	for i := 1; i < len(roads); i++ {
+		if other[newRouteStep(roads[i-1], roads[i])] {
+			overlap += pointDistance(roads[i-1].Point, roads[i].Point)
+		}
+	}
+
+	return overlap
+}
+
+// Road nodes along the path, other than its ends, that every path between its ends has to pass through. These are the
+// road nodes that nothing before them on the path can reach past without going along the path.
+func routeChokePoints(path []*world.Road) []*world.Road {
+	index := make(map[*world.Road]int, len(path))
+	for i, r := range path {
+		index[r] = i
+	}
+
+	chokePoints := make([]*world.Road, 0)
+	visited := make(map[*world.Road]bool)
+	furthest := 0
+	for i, r := range path {
+		if i > 0 && i < len(path)-1 && furthest == i {
+			chokePoints = append(chokePoints, r)
+		}
+
+		// Each road node off the path is only searched from the first road node on the path that reaches it
+		toVisit := []*world.Road{r}
+		for len(toVisit) > 0 {
+			cur := toVisit[len(toVisit)-1]
+			toVisit = toVisit[:len(toVisit)-1]
+
+			for _, next := range cur.Connections() {
+				if j, onPath := index[next]; onPath {
+					furthest = maxInt(furthest, j)
+				} else if !visited[next] {
+					visited[next] = true
+					toVisit = append(toVisit, next)
+				}
+			}
+		}
+	}
+
+	return chokePoints
+}
@@ -0,0 +1,155 @@
+package mutate
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/pathfind"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerateRoutes(t *testing.T) {
+	// A road from the spawn that splits in two around a block and joins back up before reaching the base
+	spawn := world.NewRoad(0, world.NewNode(1, 0, 0))
+	a := world.NewRoad(2, world.NewNode(3, 10, 0))
+	north := world.NewRoad(4, world.NewNode(5, 20, -10))
+	south := world.NewRoad(6, world.NewNode(7, 20, 10))
+	c := world.NewRoad(8, world.NewNode(9, 30, 0))
+	d := world.NewRoad(10, world.NewNode(11, 40, 0))
+	base := world.NewRoad(12, world.NewNode(13, 50, 0))
+	testutil.Chain(spawn, a, north, c, d, base)
+	testutil.Chain(a, south, c)
+
+	w := layers.NewWorld(world.NewContainer(nil, []*world.Road{spawn, a, north, south, c, d, base}, []*world.Building{}))
+	w.Spawns = []*world.Road{spawn}
+	w.Base = &layers.Base{Road: base}
+
+	require.NoError(t, GenerateRoutes(w))
+
+	length := 30 + 2*pointDistance(a.Point, north.Point)
+	chokePoints := []*world.Road{a, c, d}
+	require.Equal(t, []*layers.Route{
+		{Spawn: spawn, Roads: []*world.Road{spawn, a, north, c, d, base}, Length: length, ChokePoints: chokePoints},
+		{Spawn: spawn, Roads: []*world.Road{spawn, a, south, c, d, base}, Length: length, ChokePoints: chokePoints},
+	}, w.Routes, "there are only two different ways to the base")
+
+	// The second route shares the roads before and after the block with the first
+	require.NoError(t, GenerateRoutes(w, WithMaxOverlap(0.4)))
+	require.Len(t, w.Routes, 1)
+
+	require.NoError(t, GenerateRoutes(w, WithRoutes(1)))
+	require.Len(t, w.Routes, 1)
+
+	// The south road is avoided when it is expensive, so it is the second route
+	w.RoadClasses[south.Id()] = layers.PathRoad
+	require.NoError(t, GenerateRoutes(w, WithRouteWeights(map[layers.RoadClass]float64{layers.PathRoad: 1.1})))
+	require.Equal(t, south, w.Routes[1].Roads[2])
+
+	require.Error(t, GenerateRoutes(w, WithRouteWeights(map[layers.RoadClass]float64{layers.PathRoad: -1})))
+}
+
+func TestGenerateRoutes_Spawns(t *testing.T) {
+	// A street leading to the base and a lone road node that can't reach it
+	d := world.NewRoad(0, world.NewNode(1, 40, 0))
+	base := world.NewRoad(2, world.NewNode(3, 50, 0))
+	lonely := world.NewRoad(4, world.NewNode(5, 100, 100))
+	testutil.Chain(d, base)
+
+	w := layers.NewWorld(world.NewContainer(nil, []*world.Road{d, base}, []*world.Building{}))
+	w.Spawns = []*world.Road{lonely, base, d}
+	w.Base = &layers.Base{Road: base}
+
+	require.NoError(t, GenerateRoutes(w))
+	require.Equal(t, []*layers.Route{
+		{Spawn: base, Roads: []*world.Road{base}, Length: 0, ChokePoints: []*world.Road{}},
+		{Spawn: d, Roads: []*world.Road{d, base}, Length: 10, ChokePoints: []*world.Road{}},
+	}, w.Routes, "spawns that can't reach the base should have no routes")
+
+	w.Base = nil
+	require.True(t, errors.Is(GenerateRoutes(w), ErrNoBase))
+}
+
+func TestGenerateRoutes_Grid(t *testing.T) {
+	w, grid := roadGrid(5, 100)
+	w.Spawns = []*world.Road{grid[0][0], grid[4][2]}
+	w.Base = &layers.Base{Road: grid[2][2]}
+
+	require.NoError(t, GenerateRoutes(w, WithRoutes(4), WithMaxOverlap(0.5)))
+	require.NotEmpty(t, w.Routes)
+
+	earlier := make(map[*world.Road][]map[routeStep]bool)
+	for _, r := range w.Routes {
+		require.Equal(t, r.Spawn, r.Roads[0])
+		require.Equal(t, grid[2][2], r.Roads[len(r.Roads)-1])
+		require.Empty(t, r.ChokePoints, "there is always another way around the grid")
+
+		steps := make(map[routeStep]bool)
+		for i := 1; i < len(r.Roads); i++ {
+			require.Contains(t, r.Roads[i-1].Connections(), r.Roads[i])
+			steps[newRouteStep(r.Roads[i-1], r.Roads[i])] = true
+		}
+
+		for _, other := range earlier[r.Spawn] {
+			require.LessOrEqual(t, overlapLength(r.Roads, other)/r.Length, 0.5)
+		}
+
+		earlier[r.Spawn] = append(earlier[r.Spawn], steps)
+	}
+
+	require.Len(t, earlier[grid[0][0]], 4)
+	require.Equal(t, 200.0, w.Routes[len(earlier[grid[0][0]])].Length, "the first route should be the shortest")
+}
+
+// Whether the end of the path can be reached from the start without going through the road
+func reachableWithout(path []*world.Road, without *world.Road) bool {
+	visited := map[*world.Road]bool{path[0]: true, without: true}
+	toVisit := []*world.Road{path[0]}
+	for len(toVisit) > 0 {
+		cur := toVisit[0]
+		toVisit = toVisit[1:]
+		if cur == path[len(path)-1] {
+			return true
+		}
+
+		for _, next := range cur.Connections() {
+			if !visited[next] {
+				visited[next] = true
+				toVisit = append(toVisit, next)
+			}
+		}
+	}
+
+	return false
+}
+
+func TestRouteChokePoints(t *testing.T) {
+	random := rand.New(rand.NewSource(49))
+	for i := 0; i < 200; i++ {
+		roads := randomRoads(random, 60, 200)
+		for j := 0; j < random.Intn(30); j++ {
+			a, b := roads[random.Intn(len(roads))], roads[random.Intn(len(roads))]
+			a.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{b}})
+			if random.Intn(3) != 0 {
+				b.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{a}})
+			}
+		}
+
+		path, err := pathfind.Shortest([]*world.Road{roads[random.Intn(len(roads))]},
+			[]*world.Road{roads[random.Intn(len(roads))]})
+		if err != nil {
+			continue
+		}
+
+		expected := make([]*world.Road, 0)
+		for j := 1; j < len(path.Roads)-1; j++ {
+			if !reachableWithout(path.Roads, path.Roads[j]) {
+				expected = append(expected, path.Roads[j])
+			}
+		}
+
+		require.Equal(t, expected, routeChokePoints(path.Roads))
+	}
+}
@@ -0,0 +1,211 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/real-life-td/world-generator/pathfind"
+	"math"
+)
+
+// Decides which connections a building keeps
+type ConnectionStrategy interface {
+	// Picks the connections to keep from the ones found within reach of the building
+	Cull(b *world.Building, candidates []*world.Connection) []*world.Connection
+	// Scores the building's connections in the same order as b.Connections(), where higher scores are removed first
+	Score(b *world.Building) []float64
+}
+
+// Keeps the closest connection out of those whose roads are only a few roads apart
+type DistanceStrategy struct {
+	// Connections to roads within this many roads of a closer connection are culled
+	Hops int
+}
+
+// The strategy used when none is given
+var DefaultStrategy ConnectionStrategy = &DistanceStrategy{Hops: 5}
+
+func (s *DistanceStrategy) Cull(_ *world.Building, candidates []*world.Connection) []*world.Connection {
+	return cullPaths(candidates, s.Hops)
+}
+
+func (s *DistanceStrategy) Score(b *world.Building) []float64 {
+	return scoreByCost(b, func(c *world.Connection) float64 {
+		return c.Distance()
+	})
+}
+
+// Weights the distance of connections by the class of road so that buildings prefer quieter roads
+type RoadClassStrategy struct {
+	DistanceStrategy
+	Classes map[world.Id]layers.RoadClass
+	// Classes that are missing have a weight of 1
+	Weights map[layers.RoadClass]float64
+}
+
+// Weights that make connecting to a major road four times worse than connecting to a local road
+var DefaultRoadClassWeights = map[layers.RoadClass]float64{
+	layers.MajorRoad:    4,
+	layers.ArterialRoad: 2,
+	layers.LocalRoad:    1,
+	layers.ServiceRoad:  1,
+	layers.PathRoad:     1.5,
+}
+
+func NewRoadClassStrategy(classes map[world.Id]layers.RoadClass) *RoadClassStrategy {
+	return &RoadClassStrategy{DistanceStrategy: DistanceStrategy{Hops: 5}, Classes: classes, Weights: DefaultRoadClassWeights}
+}
+
+func (s *RoadClassStrategy) Score(b *world.Building) []float64 {
+	return scoreByCost(b, func(c *world.Connection) float64 {
+		weight, ok := s.Weights[s.Classes[c.Road().Id()]]
+		if !ok {
+			weight = 1
+		}
+
+		return c.Distance() * weight
+	})
+}
+
+// Keeps connections that leave the middle of the building in different directions
+type AngularStrategy struct {
+	DistanceStrategy
+	// Smallest angle in radians between the directions of two connections that are both kept
+	MinSeparation float64
+}
+
+func NewAngularStrategy() *AngularStrategy {
+	return &AngularStrategy{DistanceStrategy: DistanceStrategy{Hops: 5}, MinSeparation: math.Pi / 3}
+}
+
+func (s *AngularStrategy) Cull(b *world.Building, candidates []*world.Connection) []*world.Connection {
+	x, y := buildingCenter(b)
+	return keepClosestApart(s.DistanceStrategy.Cull(b, candidates), func(kept, candidate *world.Connection) bool {
+		return angleBetween(connectionAngle(x, y, kept), connectionAngle(x, y, candidate)) >= s.MinSeparation
+	})
+}
+
+// Connections that share a direction with another connection score up to twice as badly as ones on their own
+func (s *AngularStrategy) Score(b *world.Building) []float64 {
+	x, y := buildingCenter(b)
+	return scoreByCost(b, func(c *world.Connection) float64 {
+		smallest := math.Pi
+		for _, other := range b.Connections() {
+			if other != c {
+				smallest = math.Min(smallest, angleBetween(connectionAngle(x, y, c), connectionAngle(x, y, other)))
+			}
+		}
+
+		return c.Distance() * (2 - smallest/math.Pi)
+	})
+}
+
+// Keeps connections that lead to different parts of the road network
+type PathDiversityStrategy struct {
+	DistanceStrategy
+	// Connections with roads joined by a path shorter than this are considered to lead to the same place
+	MinPathLength float64
+}
+
+func NewPathDiversityStrategy() *PathDiversityStrategy {
+	return &PathDiversityStrategy{DistanceStrategy: DistanceStrategy{Hops: 5}, MinPathLength: 100}
+}
+
+func (s *PathDiversityStrategy) Cull(b *world.Building, candidates []*world.Connection) []*world.Connection {
+	return keepClosestApart(s.DistanceStrategy.Cull(b, candidates), func(kept, candidate *world.Connection) bool {
+		return pathLength(kept.Road(), candidate.Road(), s.MinPathLength) >= s.MinPathLength
+	})
+}
+
+// Connections with a short path to another connection score up to twice as badly as ones on their own
+func (s *PathDiversityStrategy) Score(b *world.Building) []float64 {
+	return scoreByCost(b, func(c *world.Connection) float64 {
+		if s.MinPathLength <= 0 {
+			return c.Distance()
+		}
+
+		nearest := s.MinPathLength
+		for _, other := range b.Connections() {
+			if other != c {
+				nearest = math.Min(nearest, pathLength(c.Road(), other.Road(), nearest))
+			}
+		}
+
+		return c.Distance() * (2 - nearest/s.MinPathLength)
+	})
+}
+
+// Multiplies the cost by the number of connections, except for the cheapest which is never removed
+func scoreByCost(b *world.Building, cost func(c *world.Connection) float64) []float64 {
+	scores := make([]float64, len(b.Connections()))
+	if len(scores) == 0 {
+		return scores
+	}
+
+	cheapest := 0
+	for i, c := range b.Connections() {
+		scores[i] = cost(c)
+		if scores[i] < scores[cheapest] {
+			cheapest = i
+		}
+	}
+
+	for i := range scores {
+		scores[i] *= float64(len(scores))
+	}
+
+	scores[cheapest] = 0
+	return scores
+}
+
+// Keeps each connection, from closest to furthest, that is apart from all of the ones already kept
+func keepClosestApart(connections []*world.Connection, apart func(kept, candidate *world.Connection) bool) []*world.Connection {
+	ordered := make([]*world.Connection, len(connections))
+	copy(ordered, connections)
+	sortByDistance(ordered)
+
+	kept := make([]*world.Connection, 0, len(ordered))
+	for _, candidate := range ordered {
+		keep := true
+		for _, k := range kept {
+			if !apart(k, candidate) {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			kept = append(kept, candidate)
+		}
+	}
+
+	return kept
+}
+
+func buildingCenter(b *world.Building) (x, y float64) {
+	for _, p := range b.Points() {
+		x += float64(p.X())
+		y += float64(p.Y())
+	}
+
+	return x / float64(len(b.Points())), y / float64(len(b.Points()))
+}
+
+func connectionAngle(x, y float64, c *world.Connection) float64 {
+	return math.Atan2(float64(c.Road().Y())-y, float64(c.Road().X())-x)
+}
+
+// Smallest angle between two directions, from 0 to pi
+func angleBetween(a, b float64) float64 {
+	difference := math.Mod(math.Abs(a-b), 2*math.Pi)
+	return math.Min(difference, 2*math.Pi-difference)
+}
+
+// Length of the shortest path along the roads between two road nodes, or +Inf if it isn't under the limit
+func pathLength(from, to *world.Road, limit float64) float64 {
+	path, err := pathfind.Shortest([]*world.Road{from}, []*world.Road{to}, pathfind.WithMaxCost(limit))
+	if err != nil {
+		return math.Inf(1)
+	}
+
+	return path.Length
+}
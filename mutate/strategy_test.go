@@ -0,0 +1,123 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+// Connections from the building to each of the roads
+func connectionsTo(b *world.Building, roads ...*world.Road) []*world.Connection {
+	connections := make([]*world.Connection, len(roads))
+	for i, r := range roads {
+		connections[i] = closestConnection(b, r)
+	}
+
+	return connections
+}
+
+// Roads on each side of a square building at the origin. The two roads to the north are joined together.
+func strategyRoads() (north, north2, east, west *world.Road) {
+	north = world.NewRoad(100, world.NewNode(101, 5, -5))
+	north2 = world.NewRoad(102, world.NewNode(103, 8, -6))
+	east = world.NewRoad(104, world.NewNode(105, 20, 5))
+	west = world.NewRoad(106, world.NewNode(107, -12, 5))
+	north.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{north2}})
+	north2.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{north}})
+	return north, north2, east, west
+}
+
+func TestConnectionStrategy_Cull(t *testing.T) {
+	building := newSquareBuilding(0, 0, 0)
+	north, north2, east, west := strategyRoads()
+	all := connectionsTo(building, west, east, north2, north)
+
+	tests := []struct {
+		name     string
+		strategy ConnectionStrategy
+		expected []*world.Road
+	}{
+		{"default", DefaultStrategy, []*world.Road{north, east, west}},
+		{"distance without hops", &DistanceStrategy{Hops: 0}, []*world.Road{north, north2, east, west}},
+		{"road class", NewRoadClassStrategy(nil), []*world.Road{north, east, west}},
+		{"angular", &AngularStrategy{MinSeparation: math.Pi / 3}, []*world.Road{north, east, west}},
+		{"angular with wide separation", &AngularStrategy{MinSeparation: 2}, []*world.Road{north}},
+		{"path diversity", &PathDiversityStrategy{MinPathLength: 100}, []*world.Road{north, east, west}},
+		{"path diversity with short paths", &PathDiversityStrategy{MinPathLength: 2},
+			[]*world.Road{north, north2, east, west}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, connectionsTo(building, test.expected...), test.strategy.Cull(building, all))
+			require.Empty(t, test.strategy.Cull(building, []*world.Connection{}))
+		})
+	}
+}
+
+func TestConnectionStrategy_Score(t *testing.T) {
+	north, north2, east, west := strategyRoads()
+
+	northAngle := math.Atan2(-10, 0)
+	north2Angle := math.Atan2(-11, 3)
+	northGap := north2Angle - northAngle
+	northPath := pointDistance(north.Point, north2.Point)
+
+	tests := []struct {
+		name     string
+		strategy ConnectionStrategy
+		roads    []*world.Road
+		expected []float64
+	}{
+		{"distance", DefaultStrategy, []*world.Road{east, north, west}, []float64{30, 0, 36}},
+		{"single connection", DefaultStrategy, []*world.Road{west}, []float64{0}},
+		{"no connections", DefaultStrategy, []*world.Road{}, []float64{}},
+		{"road class", NewRoadClassStrategy(map[world.Id]layers.RoadClass{
+			east.Id(): layers.LocalRoad, north.Id(): layers.MajorRoad}),
+			[]*world.Road{east, north, west}, []float64{0, 60, 36}},
+		{"angular", NewAngularStrategy(), []*world.Road{north, north2, east}, []float64{
+			0,
+			3 * 6 * (2 - northGap/math.Pi),
+			3 * 10 * (2 - math.Abs(north2Angle)/math.Pi),
+		}},
+		{"path diversity", NewPathDiversityStrategy(), []*world.Road{north, north2, east}, []float64{
+			0,
+			3 * 6 * (2 - northPath/100),
+			3 * 10,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			building := newSquareBuilding(0, 0, 0)
+			building.InitOperation(&world.BuildingInitOperation{NewConnections: connectionsTo(building, test.roads...)})
+			require.InDeltaSlice(t, test.expected, test.strategy.Score(building), 1e-9)
+		})
+	}
+}
+
+func TestAngleBetween(t *testing.T) {
+	require.InDelta(t, math.Pi/2, angleBetween(0, math.Pi/2), 1e-9)
+	require.InDelta(t, math.Pi/2, angleBetween(-3*math.Pi/4, 3*math.Pi/4), 1e-9, "the angle should wrap around")
+	require.InDelta(t, math.Pi, angleBetween(-math.Pi/2, math.Pi/2), 1e-9)
+}
+
+func TestPathLength(t *testing.T) {
+	// Two ways from a to d, the one through b is shorter
+	a := world.NewRoad(0, world.NewNode(1, 0, 0))
+	b := world.NewRoad(2, world.NewNode(3, 3, 4))
+	c := world.NewRoad(4, world.NewNode(5, 0, 20))
+	d := world.NewRoad(6, world.NewNode(7, 6, 8))
+	e := world.NewRoad(8, world.NewNode(9, 50, 50))
+	a.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{b, c}})
+	b.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{a, d}})
+	c.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{a, d}})
+	d.InitOperation(&world.RoadInitOperation{NewConnections: []*world.Road{b, c}})
+
+	require.Equal(t, 0.0, pathLength(a, a, 100))
+	require.Equal(t, 10.0, pathLength(a, d, 100))
+	require.Equal(t, math.Inf(1), pathLength(a, d, 10), "paths as long as the limit shouldn't be found")
+	require.Equal(t, math.Inf(1), pathLength(a, e, 100), "there is no path to a road that isn't connected")
+}
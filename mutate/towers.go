@@ -0,0 +1,432 @@
+package mutate
+
+import (
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/math/raycast"
+	"github.com/real-life-td/world-generator/layers"
+	"math"
+	"sort"
+)
+
+const defaultTowerRadius = 50.0
+
+const defaultFrontageOffset = 8.0
+
+// Pieces that each radius of route within range is split into when checking what a slot can see
+const sightSamplesPerRadius = 8
+
+type TowerOptions struct {
+	// How far a tower can see. Values that are not positive use the default.
+	Radius float64
+	// Values that are not positive use half of the radius
+	MinSeparation float64
+	// Distance between candidate slots. Values that are not positive use the min separation.
+	Spacing float64
+	// Distance of frontage slots from the middle of the road. Ground slots are at least twice this far from every road.
+	FrontageOffset float64
+	// Values less than 1 keep every slot
+	MaxSlots int
+}
+
+type TowerOption func(o *TowerOptions)
+
+func WithTowerRadius(radius float64) TowerOption {
+	return func(o *TowerOptions) {
+		o.Radius = radius
+	}
+}
+
+func WithMinSeparation(separation float64) TowerOption {
+	return func(o *TowerOptions) {
+		o.MinSeparation = separation
+	}
+}
+
+func WithSlotSpacing(spacing float64) TowerOption {
+	return func(o *TowerOptions) {
+		o.Spacing = spacing
+	}
+}
+
+func WithFrontageOffset(offset float64) TowerOption {
+	return func(o *TowerOptions) {
+		o.FrontageOffset = offset
+	}
+}
+
+func WithMaxSlots(slots int) TowerOption {
+	return func(o *TowerOptions) {
+		o.MaxSlots = slots
+	}
+}
+
+func (o *TowerOptions) check() error {
+	if math.IsNaN(o.Radius) || math.IsNaN(o.MinSeparation) || math.IsNaN(o.Spacing) || math.IsNaN(o.FrontageOffset) {
+		return errors.New("radius, min separation, spacing and frontage offset must be numbers")
+	}
+
+	return nil
+}
+
+func (o *TowerOptions) radius() float64 {
+	if o.Radius > 0 {
+		return o.Radius
+	}
+
+	return defaultTowerRadius
+}
+
+func (o *TowerOptions) minSeparation() float64 {
+	if o.MinSeparation > 0 {
+		return o.MinSeparation
+	}
+
+	return o.radius() / 2
+}
+
+func (o *TowerOptions) spacing() float64 {
+	if o.Spacing > 0 {
+		return o.Spacing
+	}
+
+	return o.minSeparation()
+}
+
+func (o *TowerOptions) frontageOffset() float64 {
+	if o.FrontageOffset > 0 {
+		return o.FrontageOffset
+	}
+
+	return defaultFrontageOffset
+}
+
+type TowerReport struct {
+	// Legal places for a tower before they were spaced out
+	Candidates int
+}
+
+// Finds places to build towers on roofs, beside roads and on open land, and keeps the ones that can see the most of
+// the enemy routes while staying apart from each other
+func PlaceTowerSlots(w *layers.World, opts ...TowerOption) (*TowerReport, error) {
+	options := new(TowerOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := options.check(); err != nil {
+		return nil, err
+	}
+
+	buildings := NewBuildingIndex(w.Buildings())
+	sites := &slotSites{world: w, index: NewRoadIndex(w.Roads()), buildings: buildings, areas: layers.NewAreaIndex(w.Areas),
+		options: options}
+	candidates := sites.rooftops()
+	candidates = append(candidates, sites.frontage()...)
+	candidates = append(candidates, sites.ground()...)
+
+	steps := routeSteps(w.Routes)
+	for _, c := range candidates {
+		c.Score = visibleRouteLength(c, steps, buildings, w.Holes, options.radius())
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	w.TowerSlots = spaceOut(candidates, options.minSeparation(), options.MaxSlots)
+	return &TowerReport{Candidates: len(candidates)}, nil
+}
+
+// Finds the legal places for towers in the world
+type slotSites struct {
+	world     *layers.World
+	index     *RoadIndex
+	buildings *BuildingIndex
+	areas     *layers.AreaIndex
+	options   *TowerOptions
+}
+
+// The middle of each building that has its middle inside of it
+func (s *slotSites) rooftops() []*layers.TowerSlot {
+	slots := make([]*layers.TowerSlot, 0, len(s.world.Buildings()))
+	for _, b := range s.world.Buildings() {
+		x, y := buildingCenter(b)
+		p := primitives.NewPoint(int(math.Round(x)), int(math.Round(y)))
+		if s.world.InBuilding(b, p) {
+			slots = append(slots, &layers.TowerSlot{Point: p, Kind: layers.RooftopSlot, Building: b})
+		}
+	}
+
+	return slots
+}
+
+// Points on both sides of each road segment, one for each spacing along it
+func (s *slotSites) frontage() []*layers.TowerSlot {
+	offset, spacing := s.options.frontageOffset(), s.options.spacing()
+	slots := make([]*layers.TowerSlot, 0)
+	for _, segment := range s.index.Segments() {
+		from, to := segment.From.Point, segment.To.Point
+		dx, dy := float64(to.X()-from.X()), float64(to.Y()-from.Y())
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+
+		pieces := math.Max(1, math.Floor(length/spacing))
+		for i := 0.0; i < pieces; i++ {
+			t := (i + 0.5) / pieces
+			x, y := float64(from.X())+t*dx, float64(from.Y())+t*dy
+			for _, side := range []float64{1, -1} {
+				p := primitives.NewPoint(int(math.Round(x-side*dy/length*offset)),
+					int(math.Round(y+side*dx/length*offset)))
+				if s.open(p) && !s.nearRoad(p, offset/2) {
+					slots = append(slots, &layers.TowerSlot{Point: p, Kind: layers.FrontageSlot})
+				}
+			}
+		}
+	}
+
+	return slots
+}
+
+// Points on a grid across the world that are away from the roads
+func (s *slotSites) ground() []*layers.TowerSlot {
+	slots := make([]*layers.TowerSlot, 0)
+	if len(s.world.Roads()) == 0 && s.world.Meta() == nil {
+		return slots
+	}
+
+	bounds := worldBounds(s.world.Meta(), s.world.Roads())
+	spacing := s.options.spacing()
+
+	for y := float64(bounds.Y1()) + spacing/2; y < float64(bounds.Y2()); y += spacing {
+		for x := float64(bounds.X1()) + spacing/2; x < float64(bounds.X2()); x += spacing {
+			p := primitives.NewPoint(int(math.Round(x)), int(math.Round(y)))
+			if s.open(p) && !s.nearRoad(p, 2*s.options.frontageOffset()) {
+				slots = append(slots, &layers.TowerSlot{Point: p, Kind: layers.GroundSlot})
+			}
+		}
+	}
+
+	return slots
+}
+
+// Whether the point is outside of every building and on land that can be built on
+func (s *slotSites) open(p *primitives.Point) bool {
+	for _, b := range s.buildings.Within(primitives.NewRectangle(p.X(), p.Y(), p.X(), p.Y())) {
+		if s.world.InBuilding(b, p) {
+			return false
+		}
+	}
+
+	for _, a := range s.areas.At(p) {
+		if !a.Class.Buildable() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether any road segment is closer than the distance to the point
+func (s *slotSites) nearRoad(p *primitives.Point, distance float64) bool {
+	reach := int(math.Ceil(distance))
+	bounds := primitives.NewRectangle(p.X()-reach, p.Y()-reach, p.X()+reach, p.Y()+reach)
+	for _, segment := range s.index.SegmentsWithin(bounds) {
+		if _, d := raycast.ClosestPointTo(segment.From.Point, segment.To.Point, p); d < distance {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Every step of every route, repeated for each route that takes it so that busier places score higher
+func routeSteps(routes []*layers.Route) []*Segment {
+	steps := make([]*Segment, 0)
+	for _, r := range routes {
+		for i := 1; i < len(r.Roads); i++ {
+			steps = append(steps, &Segment{From: r.Roads[i-1], To: r.Roads[i]})
+		}
+	}
+
+	return steps
+}
+
+// Length of the route steps within the radius of the slot that can be seen past the buildings other than its own
+func visibleRouteLength(slot *layers.TowerSlot, steps []*Segment, buildings *BuildingIndex,
+	holes map[world.Id][][]*world.Node, radius float64) float64 {
+	reach := int(math.Ceil(radius))
+	inRange := primitives.NewRectangle(slot.X()-reach, slot.Y()-reach, slot.X()+reach, slot.Y()+reach)
+	blockers := make([]*world.Building, 0)
+	for _, b := range buildings.Within(inRange) {
+		if b != slot.Building {
+			blockers = append(blockers, b)
+		}
+	}
+
+	x, y := float64(slot.X()), float64(slot.Y())
+	visible := 0.0
+	for _, step := range steps {
+		if _, d := raycast.ClosestPointTo(step.From.Point, step.To.Point, slot.Point); d > radius {
+			continue
+		}
+
+		ax, ay := float64(step.From.X()), float64(step.From.Y())
+		bx, by := float64(step.To.X()), float64(step.To.Y())
+		start, end := clipToCircle(ax-x, ay-y, bx-x, by-y, radius)
+		length := (end - start) * math.Hypot(bx-ax, by-ay)
+		if length <= 0 {
+			continue
+		}
+
+		// Each piece counts when the line of sight to its middle is clear
+		pieces := math.Ceil(length / radius * sightSamplesPerRadius)
+		seen := 0.0
+		for i := 0.0; i < pieces; i++ {
+			t := start + (end-start)*(i+0.5)/pieces
+			if lineOfSight(x, y, ax+t*(bx-ax), ay+t*(by-ay), blockers, holes) {
+				seen++
+			}
+		}
+
+		visible += length * seen / pieces
+	}
+
+	return visible
+}
+
+// The fractions of the way along the segment from a to b where it enters and leaves a circle around the origin. The
+// end is before the start when the segment misses the circle.
+func clipToCircle(ax, ay, bx, by, radius float64) (start, end float64) {
+	dx, dy := bx-ax, by-ay
+	a := dx*dx + dy*dy
+	if a == 0 {
+		if ax*ax+ay*ay <= radius*radius {
+			return 0, 0
+		}
+
+		return 1, 0
+	}
+
+	// Solves |a + t(b-a)| = radius for t
+	b := 2 * (ax*dx + ay*dy)
+	c := ax*ax + ay*ay - radius*radius
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return 1, 0
+	}
+
+	root := math.Sqrt(discriminant)
+	return math.Max(0, (-b-root)/(2*a)), math.Min(1, (-b+root)/(2*a))
+}
+
+// Whether the line between the two points stays out of the inside of every building. The line can only cross into a
+// building at one of its edges, so the middle of each piece between the crossings decides whether that piece is inside.
+func lineOfSight(x1, y1, x2, y2 float64, buildings []*world.Building, holes map[world.Id][][]*world.Node) bool {
+	for _, b := range buildings {
+		rings := append([][]*world.Node{b.Points()}, holes[b.Id()]...)
+		hits := []float64{0, 1}
+		for _, ring := range rings {
+			for i, p := range ring {
+				q := ring[(i+1)%len(ring)]
+				hits = edgeHits(hits, x1, y1, x2, y2, float64(p.X()), float64(p.Y()), float64(q.X()), float64(q.Y()))
+			}
+		}
+
+		sort.Float64s(hits)
+		for i := 1; i < len(hits); i++ {
+			if hits[i] > hits[i-1] {
+				t := (hits[i-1] + hits[i]) / 2
+				if ringsContain(rings, x1+t*(x2-x1), y1+t*(y2-y1)) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// Adds the fractions of the way along the segment from a to b where it meets the edge from c to d
+func edgeHits(hits []float64, ax, ay, bx, by, cx, cy, dx, dy float64) []float64 {
+	rx, ry := bx-ax, by-ay
+	sx, sy := dx-cx, dy-cy
+	qx, qy := cx-ax, cy-ay
+	denominator := rx*sy - ry*sx
+	if denominator == 0 {
+		length := rx*rx + ry*ry
+		if qx*ry-qy*rx != 0 || length == 0 {
+			// Parallel but not on the same line
+			return hits
+		}
+
+		from := (qx*rx + qy*ry) / length
+		to := ((dx-ax)*rx + (dy-ay)*ry) / length
+		return append(hits, math.Max(0, math.Min(1, from)), math.Max(0, math.Min(1, to)))
+	}
+
+	t := (qx*sy - qy*sx) / denominator
+	u := (qx*ry - qy*rx) / denominator
+	if t >= 0 && t <= 1 && u >= 0 && u <= 1 {
+		hits = append(hits, t)
+	}
+
+	return hits
+}
+
+// Whether the point is strictly inside an odd number of the rings, which is inside a polygon and outside its holes
+func ringsContain(rings [][]*world.Node, x, y float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, a := range ring {
+			b := ring[(i+1)%len(ring)]
+			ax, ay, bx, by := float64(a.X()), float64(a.Y()), float64(b.X()), float64(b.Y())
+
+			if roughlyOnSegment(ax, ay, bx, by, x, y) {
+				return false
+			}
+
+			if (ay > y) != (by > y) && x < ax+(y-ay)*(bx-ax)/(by-ay) {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+// Whether the point is on the segment from a to b, allowing for floating point rounding errors
+func roughlyOnSegment(ax, ay, bx, by, x, y float64) bool {
+	length := math.Hypot(bx-ax, by-ay)
+	cross := (bx-ax)*(y-ay) - (by-ay)*(x-ax)
+	return math.Abs(cross) <= 1e-9*math.Max(length, 1) &&
+		math.Min(ax, bx)-1e-9 <= x && x <= math.Max(ax, bx)+1e-9 &&
+		math.Min(ay, by)-1e-9 <= y && y <= math.Max(ay, by)+1e-9
+}
+
+// Keeps each slot in order that is at least the separation from every slot kept so far
+func spaceOut(slots []*layers.TowerSlot, separation float64, maxSlots int) []*layers.TowerSlot {
+	kept := make([]*layers.TowerSlot, 0)
+	for _, s := range slots {
+		if maxSlots > 0 && len(kept) == maxSlots {
+			break
+		}
+
+		apart := true
+		for _, k := range kept {
+			if pointDistance(s.Point, k.Point) < separation {
+				apart = false
+				break
+			}
+		}
+
+		if apart {
+			kept = append(kept, s)
+		}
+	}
+
+	return kept
+}
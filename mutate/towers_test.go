@@ -0,0 +1,208 @@
+package mutate
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/math/primitives"
+	"github.com/real-life-td/math/raycast"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+// A rectangular building from (x1, y1) to (x2, y2)
+func newRectangleBuilding(id world.Id, x1, y1, x2, y2 int) *world.Building {
+	return world.NewBuilding(id, []*world.Node{
+		world.NewNode(id+1, x1, y1),
+		world.NewNode(id+2, x2, y1),
+		world.NewNode(id+3, x2, y2),
+		world.NewNode(id+4, x1, y2),
+	})
+}
+
+func TestClipToCircle(t *testing.T) {
+	tests := []struct {
+		name           string
+		ax, ay, bx, by float64
+		start, end     float64
+	}{
+		{"inside", -3, 0, 3, 0, 0, 1},
+		{"through", -20, 0, 20, 0, 0.25, 0.75},
+		{"from inside", 0, 0, 20, 0, 0, 0.5},
+		{"above", -20, 15, 20, 15, 1, 0},
+		{"point inside", 1, 1, 1, 1, 0, 0},
+		{"point outside", 20, 20, 20, 20, 1, 0},
+	}
+
+	for _, test := range tests {
+		start, end := clipToCircle(test.ax, test.ay, test.bx, test.by, 10)
+		if test.end < test.start {
+			require.Less(t, end, start, test.name)
+		} else {
+			require.InDelta(t, test.start, start, 1e-9, test.name)
+			require.InDelta(t, test.end, end, 1e-9, test.name)
+		}
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	building := newRectangleBuilding(0, 10, -5, 20, 5)
+	buildings := []*world.Building{building}
+
+	require.False(t, lineOfSight(0, 0, 30, 0, buildings, nil), "the building is in the way")
+	require.True(t, lineOfSight(0, 10, 30, 10, buildings, nil), "the line passes beside the building")
+	require.True(t, lineOfSight(0, 5, 30, 5, buildings, nil), "lines along an edge only touch the building")
+	require.True(t, lineOfSight(0, 0, 10, 0, buildings, nil), "lines that end at an edge only touch the building")
+	require.True(t, lineOfSight(0, -5, 10, 5, buildings, nil), "lines that meet a corner only touch the building")
+	require.False(t, lineOfSight(0, -15, 30, 15, buildings, nil), "the diagonal goes through the inside")
+	require.False(t, lineOfSight(10, -5, 20, 5, buildings, nil), "the diagonal goes through the inside")
+	require.False(t, lineOfSight(15, 0, 30, 0, buildings, nil), "lines from inside leave through an edge")
+	require.True(t, lineOfSight(0, 0, 30, 0, []*world.Building{}, nil))
+
+	// A courtyard can be seen across from inside of it but not from outside of the building
+	courtyard := newRectangleBuilding(10, 0, 0, 30, 30)
+	holes := map[world.Id][][]*world.Node{courtyard.Id(): {newRectangleBuilding(20, 10, 10, 20, 20).Points()}}
+	require.True(t, lineOfSight(12, 12, 18, 15, []*world.Building{courtyard}, holes))
+	require.False(t, lineOfSight(12, 12, 18, 15, []*world.Building{courtyard}, nil))
+	require.True(t, lineOfSight(10, 10, 20, 20, []*world.Building{courtyard}, holes), "the diagonal of the courtyard")
+	require.False(t, lineOfSight(-5, 15, 15, 15, []*world.Building{courtyard}, holes))
+}
+
+func TestVisibleRouteLength(t *testing.T) {
+	from := world.NewRoad(0, world.NewNode(1, 0, 0))
+	to := world.NewRoad(2, world.NewNode(3, 200, 0))
+	steps := []*Segment{{From: from, To: to}}
+	_, distance := raycast.ClosestPointTo(from.Point, to.Point, primitives.NewPoint(100, 30))
+	require.Equal(t, 30.0, distance)
+
+	open := &layers.TowerSlot{Point: primitives.NewPoint(100, 30)}
+	require.InDelta(t, 80, visibleRouteLength(open, steps, NewBuildingIndex(nil), nil, 50), 1e-9,
+		"a chord 30 from the middle of a circle with a radius of 50 is 80 long")
+	require.Equal(t, 0.0, visibleRouteLength(open, steps, NewBuildingIndex(nil), nil, 20), "the route is out of range")
+
+	// A building between the slot and the route hides the middle of the route
+	wall := newRectangleBuilding(10, 90, 10, 110, 20)
+	hidden := visibleRouteLength(open, steps, NewBuildingIndex([]*world.Building{wall}), nil, 50)
+	require.Greater(t, hidden, 10.0)
+	require.Less(t, hidden, 30.0)
+
+	// A tower on top of the building can see over it
+	rooftop := &layers.TowerSlot{Point: primitives.NewPoint(100, 15), Kind: layers.RooftopSlot, Building: wall}
+	require.InDelta(t, 2*math.Sqrt(50*50-15*15),
+		visibleRouteLength(rooftop, steps, NewBuildingIndex([]*world.Building{wall}), nil, 50), 1e-9)
+
+	// A long thin building whose first point is its furthest to the right, reaching in front of the slot from outside of
+	// the range
+	sliver := world.NewBuilding(20, []*world.Node{
+		world.NewNode(21, 95, 15), world.NewNode(22, 45, 12), world.NewNode(23, -5, 15), world.NewNode(24, 45, 18),
+	})
+	require.Less(t, visibleRouteLength(open, steps, NewBuildingIndex([]*world.Building{sliver}), nil, 50), 60.0)
+
+	// Steps that several routes take count once for each of them
+	require.InDelta(t, 160, visibleRouteLength(open, append(steps, steps...), NewBuildingIndex(nil), nil, 50), 1e-9)
+}
+
+func TestPlaceTowerSlots(t *testing.T) {
+	// A road across the middle of a 200 by 100 world that enemies follow, with a building above it and a lake below it
+	roads := make([]*world.Road, 5)
+	for i := range roads {
+		roads[i] = world.NewRoad(world.Id(2*i), world.NewNode(world.Id(2*i+1), 50*i, 50))
+	}
+	testutil.Chain(roads...)
+
+	building := newRectangleBuilding(100, 40, 70, 60, 90)
+	lake := &layers.Area{Class: layers.WaterArea, Outer: []*world.Node{
+		world.NewNode(200, 120, 0), world.NewNode(201, 200, 0), world.NewNode(202, 200, 35), world.NewNode(203, 120, 35),
+	}}
+
+	w := layers.NewWorld(world.NewContainer(world.NewMetadata(200, 100, 0.0, 0.0, 1.0, 1.0), roads,
+		[]*world.Building{building}))
+	w.Areas = append(w.Areas, lake)
+	w.Spawns = []*world.Road{roads[0]}
+	w.Base = &layers.Base{Road: roads[4]}
+	w.Routes = append(w.Routes, &layers.Route{Spawn: roads[0], Roads: roads, Length: 200})
+
+	report, err := PlaceTowerSlots(w, WithTowerRadius(40), WithMinSeparation(15), WithFrontageOffset(6))
+	require.NoError(t, err)
+	require.NotEmpty(t, w.TowerSlots)
+	require.Greater(t, report.Candidates, len(w.TowerSlots))
+
+	kinds := make(map[layers.SlotKind]int)
+	for i, s := range w.TowerSlots {
+		kinds[s.Kind]++
+		if i > 0 {
+			require.LessOrEqual(t, s.Score, w.TowerSlots[i-1].Score, "slots should be ordered by score")
+		}
+
+		for _, other := range w.TowerSlots[:i] {
+			require.GreaterOrEqual(t, pointDistance(s.Point, other.Point), 15.0, "slots should be spaced out")
+		}
+
+		require.False(t, lake.Contains(s.Point), "slots can't be built on water")
+		distance := math.Abs(float64(s.Y() - 50))
+		switch s.Kind {
+		case layers.RooftopSlot:
+			require.Equal(t, building, s.Building)
+			require.Equal(t, primitives.NewPoint(50, 80), s.Point)
+		case layers.FrontageSlot:
+			require.Nil(t, s.Building)
+			require.Equal(t, 6.0, distance)
+			require.False(t, w.InBuilding(building, s.Point))
+		case layers.GroundSlot:
+			require.Nil(t, s.Building)
+			require.GreaterOrEqual(t, distance, 12.0)
+			require.False(t, w.InBuilding(building, s.Point))
+		}
+	}
+
+	require.Equal(t, 1, kinds[layers.RooftopSlot])
+	require.NotZero(t, kinds[layers.FrontageSlot])
+	require.NotZero(t, kinds[layers.GroundSlot])
+	require.Equal(t, layers.FrontageSlot, w.TowerSlots[0].Kind, "slots beside the route see the most of it")
+
+	_, err = PlaceTowerSlots(w, WithTowerRadius(40), WithMaxSlots(3))
+	require.NoError(t, err)
+	require.Len(t, w.TowerSlots, 3)
+
+	_, err = PlaceTowerSlots(w, WithTowerRadius(math.NaN()))
+	require.Error(t, err)
+}
+
+func TestPlaceTowerSlots_NoRoutes(t *testing.T) {
+	// A road across the middle of a 200 by 100 world with a building above it
+	roads := make([]*world.Road, 5)
+	for i := range roads {
+		roads[i] = world.NewRoad(world.Id(2*i), world.NewNode(world.Id(2*i+1), 50*i, 50))
+	}
+	testutil.Chain(roads...)
+
+	w := layers.NewWorld(world.NewContainer(world.NewMetadata(200, 100, 0.0, 0.0, 1.0, 1.0), roads,
+		[]*world.Building{newRectangleBuilding(100, 40, 70, 60, 90)}))
+
+	_, err := PlaceTowerSlots(w)
+	require.NoError(t, err)
+	require.NotEmpty(t, w.TowerSlots, "slots are still legal places to build without any routes")
+	for _, s := range w.TowerSlots {
+		require.Equal(t, 0.0, s.Score)
+	}
+
+	w = layers.NewWorld(world.NewContainer(nil, []*world.Road{}, []*world.Building{}))
+	_, err = PlaceTowerSlots(w)
+	require.NoError(t, err)
+	require.Empty(t, w.TowerSlots)
+}
+
+func TestSpaceOut(t *testing.T) {
+	slots := []*layers.TowerSlot{
+		{Point: primitives.NewPoint(0, 0)},
+		{Point: primitives.NewPoint(5, 0)},
+		{Point: primitives.NewPoint(10, 0)},
+		{Point: primitives.NewPoint(20, 0)},
+	}
+
+	require.Equal(t, []*layers.TowerSlot{slots[0], slots[2], slots[3]}, spaceOut(slots, 10, 0))
+	require.Equal(t, []*layers.TowerSlot{slots[0], slots[3]}, spaceOut(slots, 11, 0))
+	require.Equal(t, []*layers.TowerSlot{slots[0], slots[2]}, spaceOut(slots, 10, 2))
+	require.Equal(t, slots, spaceOut(slots, 0, 0))
+}
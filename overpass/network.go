@@ -11,7 +11,13 @@ import (
 
 var overpassEndpoint = "https://overpass-api.de/api/interpreter"
 
-const query = "[bbox:%f,%f,%f,%f][out:json];way[highway]->.h;(way.h[!area];way[building];);out geom;"
+// Multipolygons are fetched with their member ways so the rings can be stitched together. Amenity ways aren't
+// classified by default but are fetched for custom classifiers.
+const query = "[bbox:%f,%f,%f,%f][out:json];way[highway]->.h;" +
+	"relation[type=multipolygon][~\"^(building|landuse|leisure|natural|waterway)$\"~\".\"]->.m;" +
+	"(way.h[!area];way[building];way[landuse];way[leisure];way[natural];way[waterway];way[railway];way[amenity];.m;" +
+	"way(r.m);" +
+	"node[amenity];node[shop];node[tourism];node[historic];node[natural=tree];node[highway=bus_stop];);out geom;"
 
 func call(query string) (body io.ReadCloser, err error) {
 	req, err := http.NewRequest("GET", overpassEndpoint, nil)
@@ -1,7 +1,11 @@
 package overpass
 
+import "encoding/json"
+
 type Result struct {
-	Elements []*Way
+	Elements  []*Way
+	Relations []*Relation
+	Nodes     []*Node
 }
 
 type LatLon struct {
@@ -10,8 +14,23 @@ type LatLon struct {
 }
 
 type Tags struct {
-	Highway  string
-	Building string
+	Highway   string
+	Building  string
+	Type      string
+	Natural   string
+	Waterway  string
+	Leisure   string
+	Landuse   string
+	Railway   string
+	Amenity   string
+	Shop      string
+	Tourism   string
+	Historic  string
+	Name      string
+	Height    string
+	MinHeight string `json:"min_height"`
+	Levels    string `json:"building:levels"`
+	RoofShape string `json:"roof:shape"`
 }
 
 type Bounds struct {
@@ -25,3 +44,83 @@ type Way struct {
 	Geometry []*LatLon
 	Tags     *Tags
 }
+
+type Node struct {
+	Id   uint64
+	Lat  float64
+	Lon  float64
+	Tags *Tags
+}
+
+type Member struct {
+	Type string
+	Ref  uint64
+	Role string
+}
+
+type Relation struct {
+	Id      uint64
+	Bounds  *Bounds
+	Members []*Member
+	Tags    *Tags
+}
+
+// Overpass mixes nodes, ways and relations in one array, told apart by the type field. Untyped elements are ways.
+type element struct {
+	Type     string    `json:"type"`
+	Id       uint64    `json:"id"`
+	Bounds   *Bounds   `json:"bounds,omitempty"`
+	Nodes    []uint64  `json:"nodes,omitempty"`
+	Geometry []*LatLon `json:"geometry,omitempty"`
+	Members  []*Member `json:"members,omitempty"`
+	Lat      float64   `json:"lat,omitempty"`
+	Lon      float64   `json:"lon,omitempty"`
+	Tags     *Tags     `json:"tags,omitempty"`
+}
+
+type rawResult struct {
+	Elements []*element `json:"elements"`
+}
+
+func (r *Result) UnmarshalJSON(data []byte) error {
+	raw := new(rawResult)
+	err := json.Unmarshal(data, raw)
+	if err != nil {
+		return err
+	}
+
+	r.Elements, r.Relations, r.Nodes = nil, nil, nil
+	for _, e := range raw.Elements {
+		if e == nil {
+			continue
+		}
+
+		switch e.Type {
+		case "relation":
+			r.Relations = append(r.Relations, &Relation{Id: e.Id, Bounds: e.Bounds, Members: e.Members, Tags: e.Tags})
+		case "node":
+			r.Nodes = append(r.Nodes, &Node{Id: e.Id, Lat: e.Lat, Lon: e.Lon, Tags: e.Tags})
+		case "way", "":
+			r.Elements = append(r.Elements, &Way{Id: e.Id, Bounds: e.Bounds, Nodes: e.Nodes, Geometry: e.Geometry, Tags: e.Tags})
+		}
+	}
+
+	return nil
+}
+
+func (r *Result) MarshalJSON() ([]byte, error) {
+	raw := &rawResult{Elements: make([]*element, 0, len(r.Nodes)+len(r.Elements)+len(r.Relations))}
+	for _, n := range r.Nodes {
+		raw.Elements = append(raw.Elements, &element{Type: "node", Id: n.Id, Lat: n.Lat, Lon: n.Lon, Tags: n.Tags})
+	}
+
+	for _, w := range r.Elements {
+		raw.Elements = append(raw.Elements, &element{Type: "way", Id: w.Id, Bounds: w.Bounds, Nodes: w.Nodes, Geometry: w.Geometry, Tags: w.Tags})
+	}
+
+	for _, rel := range r.Relations {
+		raw.Elements = append(raw.Elements, &element{Type: "relation", Id: rel.Id, Bounds: rel.Bounds, Members: rel.Members, Tags: rel.Tags})
+	}
+
+	return json.Marshal(raw)
+}
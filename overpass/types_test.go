@@ -0,0 +1,90 @@
+package overpass
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResult_UnmarshalJSON(t *testing.T) {
+	body := `{
+		"version": 0.6,
+		"elements": [
+			{
+				"type": "way",
+				"id": 1,
+				"bounds": {"minlat": 0.1, "minlon": 0.2, "maxlat": 0.3, "maxlon": 0.4},
+				"nodes": [2, 3],
+				"geometry": [{"lat": 0.1, "lon": 0.2}, {"lat": 0.3, "lon": 0.4}],
+				"tags": {"highway": "residential", "name": "Main Street"}
+			},
+			{
+				"type": "relation",
+				"id": 4,
+				"members": [
+					{"type": "way", "ref": 5, "role": "outer", "geometry": [{"lat": 0.1, "lon": 0.2}]},
+					{"type": "way", "ref": 6, "role": "inner"}
+				],
+				"tags": {"building": "school", "type": "multipolygon", "height": "12 m", "min_height": "2",
+					"building:levels": "3", "roof:shape": "flat"}
+			},
+			{
+				"type": "node",
+				"id": 7,
+				"lat": 0.5,
+				"lon": 0.6,
+				"tags": {"amenity": "cafe", "name": "Corner Cafe"}
+			},
+			{
+				"type": "area",
+				"id": 8
+			}
+		]
+	}`
+
+	result := new(Result)
+	require.NoError(t, json.Unmarshal([]byte(body), result))
+
+	expected := &Result{
+		Elements: []*Way{
+			{
+				Id:       1,
+				Bounds:   &Bounds{MinLat: 0.1, MinLon: 0.2, MaxLat: 0.3, MaxLon: 0.4},
+				Nodes:    []uint64{2, 3},
+				Geometry: []*LatLon{{Lat: 0.1, Lon: 0.2}, {Lat: 0.3, Lon: 0.4}},
+				Tags:     &Tags{Highway: "residential", Name: "Main Street"},
+			},
+		},
+		Relations: []*Relation{
+			{
+				Id: 4,
+				Members: []*Member{
+					{Type: "way", Ref: 5, Role: "outer"},
+					{Type: "way", Ref: 6, Role: "inner"},
+				},
+				Tags: &Tags{Building: "school", Type: "multipolygon", Height: "12 m", MinHeight: "2", Levels: "3", RoofShape: "flat"},
+			},
+		},
+		Nodes: []*Node{
+			{Id: 7, Lat: 0.5, Lon: 0.6, Tags: &Tags{Amenity: "cafe", Name: "Corner Cafe"}},
+		},
+	}
+	require.Equal(t, expected, result)
+
+	require.Error(t, json.Unmarshal([]byte(`{"elements": 5}`), result))
+}
+
+func TestResult_MarshalJSON(t *testing.T) {
+	result := &Result{
+		Elements:  []*Way{{Id: 1, Nodes: []uint64{2, 3}, Tags: &Tags{Building: "yes"}}},
+		Relations: []*Relation{{Id: 4, Members: []*Member{{Type: "way", Ref: 1, Role: "outer"}}}},
+		Nodes:     []*Node{{Id: 5, Lat: 0.5, Lon: 0.6, Tags: &Tags{Shop: "bakery"}}},
+	}
+
+	body, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	decoded := new(Result)
+	require.NoError(t, json.Unmarshal(body, decoded))
+	require.Equal(t, result, decoded)
+}
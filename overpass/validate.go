@@ -0,0 +1,59 @@
+package overpass
+
+import (
+	"fmt"
+	"math"
+)
+
+// A problem with a way or node that stops it from being converted
+type ValidationError struct {
+	Element string
+	Id      uint64
+	Field   string
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %d: %s: %s", e.Element, e.Id, e.Field, e.Problem)
+}
+
+// Every problem with the nodes and geometry of the way. Ways without tags are fine as relation members don't need them.
+func (w *Way) Validate() []*ValidationError {
+	problems := make([]*ValidationError, 0)
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, &ValidationError{Element: "way", Id: w.Id, Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if len(w.Nodes) < 2 {
+		problem("nodes", "has %d nodes but a way needs at least 2", len(w.Nodes))
+	}
+
+	if len(w.Geometry) != len(w.Nodes) {
+		problem("geometry", "has %d points for %d nodes", len(w.Geometry), len(w.Nodes))
+	}
+
+	for i, p := range w.Geometry {
+		if p == nil {
+			problem("geometry", "point %d is missing", i)
+		} else if !validLatLon(p.Lat, p.Lon) {
+			problem("geometry", "point %d (%v, %v) is not a valid coordinate", i, p.Lat, p.Lon)
+		}
+	}
+
+	return problems
+}
+
+// Every problem with the position of the node
+func (n *Node) Validate() []*ValidationError {
+	problems := make([]*ValidationError, 0)
+	if !validLatLon(n.Lat, n.Lon) {
+		problems = append(problems, &ValidationError{Element: "node", Id: n.Id, Field: "position",
+			Problem: fmt.Sprintf("(%v, %v) is not a valid coordinate", n.Lat, n.Lon)})
+	}
+
+	return problems
+}
+
+func validLatLon(lat, lon float64) bool {
+	return !math.IsNaN(lat) && !math.IsNaN(lon) && -90 <= lat && lat <= 90 && -180 <= lon && lon <= 180
+}
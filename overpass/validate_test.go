@@ -0,0 +1,47 @@
+package overpass
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestWay_Validate(t *testing.T) {
+	valid := &Way{Id: 1, Nodes: []uint64{1, 2}, Geometry: []*LatLon{{Lat: 0.1, Lon: 0.2}, {Lat: 0.3, Lon: 0.4}},
+		Tags: &Tags{Highway: "primary"}}
+	require.Empty(t, valid.Validate())
+
+	untagged := &Way{Id: 1, Nodes: []uint64{1, 2}, Geometry: []*LatLon{{Lat: 0.1, Lon: 0.2}, {Lat: 0.3, Lon: 0.4}}}
+	require.Empty(t, untagged.Validate(), "ways without tags are valid")
+
+	empty := &Way{Id: 2}
+	require.Equal(t, []*ValidationError{
+		{Element: "way", Id: 2, Field: "nodes", Problem: "has 0 nodes but a way needs at least 2"},
+	}, empty.Validate())
+
+	truncated := &Way{Id: 3, Nodes: []uint64{1, 2, 3}, Geometry: []*LatLon{{Lat: 0.1, Lon: 0.2}, nil}}
+	require.Equal(t, []*ValidationError{
+		{Element: "way", Id: 3, Field: "geometry", Problem: "has 2 points for 3 nodes"},
+		{Element: "way", Id: 3, Field: "geometry", Problem: "point 1 is missing"},
+	}, truncated.Validate())
+
+	outOfRange := &Way{Id: 4, Nodes: []uint64{1, 2}, Geometry: []*LatLon{{Lat: 91, Lon: 0}, {Lat: math.NaN(), Lon: 0}}}
+	problems := outOfRange.Validate()
+	require.Len(t, problems, 2)
+	require.Equal(t, "way 4: geometry: point 0 (91, 0) is not a valid coordinate", problems[0].Error())
+}
+
+func TestNode_Validate(t *testing.T) {
+	valid := &Node{Id: 1, Lat: 0.1, Lon: 0.2}
+	require.Empty(t, valid.Validate())
+
+	outOfRange := &Node{Id: 2, Lat: 0, Lon: 181}
+	require.Equal(t, []*ValidationError{
+		{Element: "node", Id: 2, Field: "position", Problem: "(0, 181) is not a valid coordinate"},
+	}, outOfRange.Validate())
+
+	notANumber := &Node{Id: 3, Lat: math.NaN(), Lon: 0}
+	problems := notANumber.Validate()
+	require.Len(t, problems, 1)
+	require.Equal(t, "node 3: position: (NaN, 0) is not a valid coordinate", problems[0].Error())
+}
@@ -0,0 +1,96 @@
+package pathfind
+
+import (
+	"container/heap"
+	"github.com/real-life-td/game-core/world"
+	"math"
+)
+
+// The cheapest cost from every road node to the closest goal, along with the next road node to move to
+type Field struct {
+	costs map[*world.Road]float64
+	next  map[*world.Road]*world.Road
+}
+
+// Computes the field over the roads towards the goals, leaving out connections to roads that aren't in the list
+func DistanceField(roads, goals []*world.Road, opts ...Option) (*Field, error) {
+	options, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[*world.Road]bool, len(roads))
+	for _, r := range roads {
+		included[r] = true
+	}
+
+	// The search goes outwards from the goals so every connection is followed backwards
+	incoming := make(map[*world.Road][]*world.Road, len(roads))
+	for _, r := range roads {
+		for _, c := range r.Connections() {
+			if included[c] {
+				incoming[c] = append(incoming[c], r)
+			}
+		}
+	}
+
+	field := &Field{
+		costs: make(map[*world.Road]float64, len(roads)),
+		next:  make(map[*world.Road]*world.Road, len(roads)),
+	}
+
+	queue := new(searchQueue)
+	for _, g := range goals {
+		if _, ok := field.costs[g]; included[g] && !ok {
+			field.costs[g] = 0
+			queue.push(g, 0, 0)
+		}
+	}
+
+	for queue.Len() > 0 {
+		cur := heap.Pop(queue).(*searchStep)
+		if cur.cost > field.costs[cur.road] {
+			continue
+		}
+
+		for _, from := range incoming[cur.road] {
+			cost := cur.cost + options.cost(from, cur.road)
+			if known, ok := field.costs[from]; options.withinLimit(cost) && (!ok || cost < known) {
+				field.costs[from] = cost
+				field.next[from] = cur.road
+				queue.push(from, cost, cost)
+			}
+		}
+	}
+
+	return field, nil
+}
+
+// Cheapest cost from the road node to a goal, or +Inf if no goal can be reached
+func (f *Field) Cost(r *world.Road) float64 {
+	if cost, ok := f.costs[r]; ok {
+		return cost
+	}
+
+	return math.Inf(1)
+}
+
+// The road node to move to from r to get closer to a goal. Nil for goals and roads that can't reach a goal.
+func (f *Field) Next(r *world.Road) *world.Road {
+	return f.next[r]
+}
+
+// Follows the field from the road node to a goal. Returns nil if no goal can be reached.
+func (f *Field) PathFrom(r *world.Road) *Path {
+	if _, ok := f.costs[r]; !ok {
+		return nil
+	}
+
+	path := &Path{Roads: []*world.Road{r}, Cost: f.costs[r]}
+	for cur := r; f.next[cur] != nil; cur = f.next[cur] {
+		path.Roads = append(path.Roads, f.next[cur])
+		path.Length += length(cur, f.next[cur])
+	}
+
+	return path
+}
@@ -0,0 +1,85 @@
+package pathfind
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistanceField(t *testing.T) {
+	// Two routes from a to d. The one through b is shorter but is along a major road. A one way road leads from d
+	// to e.
+	a := world.NewRoad(0, world.NewNode(1, 0, 0))
+	b := world.NewRoad(2, world.NewNode(3, 30, 40))
+	c := world.NewRoad(4, world.NewNode(5, 0, 80))
+	d := world.NewRoad(6, world.NewNode(7, 60, 80))
+	e := world.NewRoad(8, world.NewNode(9, 60, 90))
+	lonely := world.NewRoad(10, world.NewNode(11, 500, 500))
+	testutil.Chain(a, b, d)
+	testutil.Chain(a, c, d)
+	d.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{e}})
+	classes := map[world.Id]layers.RoadClass{
+		a.Id(): layers.MajorRoad, b.Id(): layers.MajorRoad, c.Id(): layers.LocalRoad, d.Id(): layers.MajorRoad,
+		e.Id(): layers.LocalRoad,
+	}
+
+	field, err := DistanceField([]*world.Road{a, b, c, d, e, lonely}, []*world.Road{d})
+	require.NoError(t, err)
+
+	require.Equal(t, 0.0, field.Cost(d))
+	require.Equal(t, 50.0, field.Cost(b))
+	require.Equal(t, 100.0, field.Cost(a))
+	require.Equal(t, math.Inf(1), field.Cost(e), "the one way road shouldn't lead back to the goal")
+	require.Equal(t, math.Inf(1), field.Cost(lonely))
+
+	require.Equal(t, b, field.Next(a))
+	require.Nil(t, field.Next(d))
+	require.Nil(t, field.Next(lonely))
+
+	require.Equal(t, &Path{Roads: []*world.Road{a, b, d}, Cost: 100, Length: 100}, field.PathFrom(a))
+	require.Equal(t, &Path{Roads: []*world.Road{d}}, field.PathFrom(d))
+	require.Nil(t, field.PathFrom(e))
+
+	// Avoiding the major roads sends a the other way
+	field, err = DistanceField([]*world.Road{a, b, c, d, e, lonely}, []*world.Road{d},
+		WithClassWeights(classes, map[layers.RoadClass]float64{layers.MajorRoad: 2}))
+	require.NoError(t, err)
+	require.Equal(t, c, field.Next(a))
+	require.Equal(t, 140.0, field.Cost(a))
+
+	// Goals that aren't in the roads are left out
+	field, err = DistanceField([]*world.Road{a, b}, []*world.Road{d})
+	require.NoError(t, err)
+	require.Equal(t, math.Inf(1), field.Cost(a))
+}
+
+func TestDistanceField_MatchesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(47))
+	weights := map[layers.RoadClass]float64{layers.MajorRoad: 3, layers.PathRoad: 0.5}
+
+	for i := 0; i < 50; i++ {
+		roads := randomNetwork(random, 50, 200)
+		opts := []Option{WithClassWeights(randomClasses(random, roads), weights)}
+		goals := pickRoads(random, roads, random.Intn(3)+1)
+
+		field, err := DistanceField(roads, goals, opts...)
+		require.NoError(t, err)
+
+		for _, r := range roads {
+			path, err := Shortest([]*world.Road{r}, goals, opts...)
+			if err == ErrNoPath {
+				require.Equal(t, math.Inf(1), field.Cost(r))
+				require.Nil(t, field.PathFrom(r))
+				continue
+			}
+
+			require.InDelta(t, path.Cost, field.Cost(r), 1e-9)
+			require.InDelta(t, path.Cost, field.PathFrom(r).Cost, 1e-9)
+			require.Contains(t, goals, field.PathFrom(r).Roads[len(field.PathFrom(r).Roads)-1])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package pathfind
+
+import (
+	"fmt"
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"math"
+)
+
+// Decides how expensive it is to travel between road nodes
+type Options struct {
+	// A step between two road nodes has the class of the less important end
+	Classes map[world.Id]layers.RoadClass
+	// Classes that are missing have a weight of 1
+	Weights map[layers.RoadClass]float64
+	// Multiplies the cost of each step. Values less than 1 are treated as 1 so that AStar never overestimates the cost.
+	Penalty func(from, to *world.Road) float64
+	// Paths that cost at least this much aren't followed. Zero searches without a limit.
+	MaxCost float64
+}
+
+type Option func(o *Options)
+
+// Weights the length of each step by the class of the roads it is along
+func WithClassWeights(classes map[world.Id]layers.RoadClass, weights map[layers.RoadClass]float64) Option {
+	return func(o *Options) {
+		o.Classes = classes
+		o.Weights = weights
+	}
+}
+
+// Makes some steps more expensive, for example to steer a search away from roads that have already been used
+func WithPenalty(penalty func(from, to *world.Road) float64) Option {
+	return func(o *Options) {
+		o.Penalty = penalty
+	}
+}
+
+// Only follows paths that cost less than the limit
+func WithMaxCost(cost float64) Option {
+	return func(o *Options) {
+		o.MaxCost = cost
+	}
+}
+
+func newOptions(opts []Option) (*Options, error) {
+	options := new(Options)
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	for class, weight := range options.Weights {
+		if !(weight > 0) || math.IsInf(weight, 1) {
+			return nil, fmt.Errorf("weight of %v for %s roads must be positive and finite", weight, class)
+		}
+	}
+
+	if !(options.MaxCost >= 0) {
+		return nil, fmt.Errorf("max cost of %v must not be negative", options.MaxCost)
+	}
+
+	return options, nil
+}
+
+// Cost of travelling from one road node to the next
+func (o *Options) cost(from, to *world.Road) float64 {
+	cost := length(from, to) * o.weight(from, to)
+	if o.Penalty != nil {
+		if penalty := o.Penalty(from, to); penalty > 1 {
+			cost *= penalty
+		}
+	}
+
+	return cost
+}
+
+// Whether a path that costs this much is under the limit
+func (o *Options) withinLimit(cost float64) bool {
+	return o.MaxCost == 0 || cost < o.MaxCost
+}
+
+func (o *Options) weight(from, to *world.Road) float64 {
+	if o.Weights == nil {
+		return 1
+	}
+
+	class := o.Classes[from.Id()]
+	if toClass := o.Classes[to.Id()]; toClass.Importance() < class.Importance() {
+		class = toClass
+	}
+
+	if weight, ok := o.Weights[class]; ok {
+		return weight
+	}
+
+	return 1
+}
+
+// Smallest weight of any step, which keeps the straight line distance from overestimating the cost
+func (o *Options) minWeight() float64 {
+	smallest := 1.0
+	for _, weight := range o.Weights {
+		smallest = math.Min(smallest, weight)
+	}
+
+	return smallest
+}
+
+func length(from, to *world.Road) float64 {
+	return math.Hypot(float64(to.X()-from.X()), float64(to.Y()-from.Y()))
+}
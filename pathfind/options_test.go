@@ -0,0 +1,49 @@
+package pathfind
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	a := world.NewRoad(0, world.NewNode(1, 0, 0))
+	b := world.NewRoad(2, world.NewNode(3, 3, 4))
+	c := world.NewRoad(4, world.NewNode(5, 0, 4))
+
+	options, err := newOptions(nil)
+	require.NoError(t, err)
+	require.Equal(t, 5.0, options.cost(a, b))
+	require.Equal(t, 1.0, options.minWeight())
+
+	classes := map[world.Id]layers.RoadClass{a.Id(): layers.MajorRoad, b.Id(): layers.LocalRoad, c.Id(): layers.PathRoad}
+	weights := map[layers.RoadClass]float64{layers.LocalRoad: 2, layers.PathRoad: 0.5}
+	options, err = newOptions([]Option{WithClassWeights(classes, weights)})
+	require.NoError(t, err)
+	require.Equal(t, 10.0, options.cost(a, b), "steps should take the class of the less important end")
+	require.Equal(t, 10.0, options.cost(b, a))
+	require.Equal(t, 2.0, options.cost(a, c))
+	require.Equal(t, 0.5, options.minWeight())
+
+	delete(classes, b.Id())
+	require.Equal(t, 5.0, options.cost(a, b), "roads without a class should have a weight of 1")
+
+	options, err = newOptions([]Option{WithPenalty(func(from, to *world.Road) float64 {
+		if from == a {
+			return 3
+		}
+
+		return 0.5
+	})})
+	require.NoError(t, err)
+	require.Equal(t, 15.0, options.cost(a, b))
+	require.Equal(t, 5.0, options.cost(b, a), "penalties less than 1 should not make a step cheaper")
+	require.Equal(t, 1.0, options.minWeight())
+
+	for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		_, err = newOptions([]Option{WithClassWeights(classes, map[layers.RoadClass]float64{layers.MajorRoad: weight})})
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,142 @@
+package pathfind
+
+import (
+	"container/heap"
+	"errors"
+	"github.com/real-life-td/game-core/world"
+	"math"
+)
+
+// Returned when none of the targets can be reached from any of the sources
+var ErrNoPath = errors.New("no path between the roads")
+
+// A route along the roads from one of the sources to one of the targets
+type Path struct {
+	// Road nodes from the source to the target, including both
+	Roads []*world.Road
+	// Length weighted by the class of each step
+	Cost float64
+	// Straight line length of every step added together
+	Length float64
+}
+
+// Finds the cheapest path from any of the sources to any of the targets using Dijkstra's algorithm
+func Shortest(sources, targets []*world.Road, opts ...Option) (*Path, error) {
+	options, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return search(sources, targets, options, func(*world.Road) float64 { return 0 })
+}
+
+// Finds the same path as Shortest, using the straight line distance to the closest target to search towards it first
+func AStar(sources, targets []*world.Road, opts ...Option) (*Path, error) {
+	options, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	weight := options.minWeight()
+	return search(sources, targets, options, func(r *world.Road) float64 {
+		closest := math.Inf(1)
+		for _, t := range targets {
+			closest = math.Min(closest, length(r, t))
+		}
+
+		return closest * weight
+	})
+}
+
+// Dijkstra's algorithm ordered by the cost so far plus an estimate of the rest, which must never be too high
+func search(sources, targets []*world.Road, options *Options, estimate func(r *world.Road) float64) (*Path, error) {
+	isTarget := make(map[*world.Road]bool, len(targets))
+	for _, t := range targets {
+		isTarget[t] = true
+	}
+
+	costs := make(map[*world.Road]float64)
+	previous := make(map[*world.Road]*world.Road)
+	queue := new(searchQueue)
+	for _, s := range sources {
+		if _, ok := costs[s]; !ok {
+			costs[s] = 0
+			queue.push(s, 0, estimate(s))
+		}
+	}
+
+	for queue.Len() > 0 {
+		cur := heap.Pop(queue).(*searchStep)
+		if cur.cost > costs[cur.road] {
+			// A cheaper way to this road has already been followed
+			continue
+		}
+
+		if isTarget[cur.road] {
+			return buildPath(cur.road, previous, cur.cost), nil
+		}
+
+		for _, next := range cur.road.Connections() {
+			cost := cur.cost + options.cost(cur.road, next)
+			if known, ok := costs[next]; options.withinLimit(cost) && (!ok || cost < known) {
+				costs[next] = cost
+				previous[next] = cur.road
+				queue.push(next, cost, cost+estimate(next))
+			}
+		}
+	}
+
+	return nil, ErrNoPath
+}
+
+func buildPath(target *world.Road, previous map[*world.Road]*world.Road, cost float64) *Path {
+	path := &Path{Roads: []*world.Road{target}, Cost: cost}
+	for r := target; previous[r] != nil; r = previous[r] {
+		path.Roads = append(path.Roads, previous[r])
+		path.Length += length(previous[r], r)
+	}
+
+	for i, j := 0, len(path.Roads)-1; i < j; i, j = i+1, j-1 {
+		path.Roads[i], path.Roads[j] = path.Roads[j], path.Roads[i]
+	}
+
+	return path
+}
+
+type searchStep struct {
+	road     *world.Road
+	cost     float64
+	priority float64
+	// Breaks ties between steps with the same priority so that searches are repeatable
+	order int
+}
+
+type searchQueue struct {
+	steps []*searchStep
+	count int
+}
+
+func (q *searchQueue) push(road *world.Road, cost, priority float64) {
+	heap.Push(q, &searchStep{road: road, cost: cost, priority: priority, order: q.count})
+	q.count++
+}
+
+func (q *searchQueue) Len() int { return len(q.steps) }
+
+func (q *searchQueue) Less(i, j int) bool {
+	if q.steps[i].priority != q.steps[j].priority {
+		return q.steps[i].priority < q.steps[j].priority
+	}
+
+	return q.steps[i].order < q.steps[j].order
+}
+
+func (q *searchQueue) Swap(i, j int)      { q.steps[i], q.steps[j] = q.steps[j], q.steps[i] }
+func (q *searchQueue) Push(x interface{}) { q.steps = append(q.steps, x.(*searchStep)) }
+
+func (q *searchQueue) Pop() interface{} {
+	n := len(q.steps)
+	x := q.steps[n-1]
+	q.steps = q.steps[:n-1]
+	return x
+}
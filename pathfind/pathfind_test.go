@@ -0,0 +1,151 @@
+package pathfind
+
+import (
+	"github.com/real-life-td/game-core/world"
+	"github.com/real-life-td/world-generator/internal/testutil"
+	"github.com/real-life-td/world-generator/layers"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSearches(t *testing.T) {
+	searches := map[string]func(sources, targets []*world.Road, opts ...Option) (*Path, error){
+		"shortest": Shortest,
+		"a star":   AStar,
+	}
+
+	for name, search := range searches {
+		t.Run(name, func(t *testing.T) {
+			// Two routes from a to d. The one through b is shorter but is along a major road. A one way road leads from d
+			// to e.
+			a := world.NewRoad(0, world.NewNode(1, 0, 0))
+			b := world.NewRoad(2, world.NewNode(3, 30, 40))
+			c := world.NewRoad(4, world.NewNode(5, 0, 80))
+			d := world.NewRoad(6, world.NewNode(7, 60, 80))
+			e := world.NewRoad(8, world.NewNode(9, 60, 90))
+			lonely := world.NewRoad(10, world.NewNode(11, 500, 500))
+			testutil.Chain(a, b, d)
+			testutil.Chain(a, c, d)
+			d.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{e}})
+			classes := map[world.Id]layers.RoadClass{
+				a.Id(): layers.MajorRoad, b.Id(): layers.MajorRoad, c.Id(): layers.LocalRoad, d.Id(): layers.MajorRoad,
+				e.Id(): layers.LocalRoad,
+			}
+
+			avoidMajor := WithClassWeights(classes, map[layers.RoadClass]float64{layers.MajorRoad: 2})
+
+			tests := []struct {
+				name             string
+				sources, targets []*world.Road
+				opts             []Option
+				expected         *Path
+			}{
+				{"shortest route", []*world.Road{a}, []*world.Road{d}, nil,
+					&Path{Roads: []*world.Road{a, b, d}, Cost: 100, Length: 100}},
+				{"class weights", []*world.Road{a}, []*world.Road{d}, []Option{avoidMajor},
+					&Path{Roads: []*world.Road{a, c, d}, Cost: 140, Length: 140}},
+				{"same road", []*world.Road{a}, []*world.Road{a}, nil,
+					&Path{Roads: []*world.Road{a}, Cost: 0, Length: 0}},
+				{"closest of several targets", []*world.Road{a}, []*world.Road{d, c}, nil,
+					&Path{Roads: []*world.Road{a, c}, Cost: 80, Length: 80}},
+				{"closest of several sources", []*world.Road{a, c}, []*world.Road{d}, nil,
+					&Path{Roads: []*world.Road{c, d}, Cost: 60, Length: 60}},
+				{"with the one way road", []*world.Road{c}, []*world.Road{e}, nil,
+					&Path{Roads: []*world.Road{c, d, e}, Cost: 70, Length: 70}},
+				{"under the cost limit", []*world.Road{a}, []*world.Road{d}, []Option{WithMaxCost(101)},
+					&Path{Roads: []*world.Road{a, b, d}, Cost: 100, Length: 100}},
+			}
+
+			for _, test := range tests {
+				path, err := search(test.sources, test.targets, test.opts...)
+				require.NoError(t, err, test.name)
+				require.Equal(t, test.expected, path, test.name)
+			}
+
+			_, err := search([]*world.Road{e}, []*world.Road{d})
+			require.Equal(t, ErrNoPath, err, "the one way road shouldn't be followed backwards")
+			_, err = search([]*world.Road{a}, []*world.Road{lonely})
+			require.Equal(t, ErrNoPath, err)
+			_, err = search([]*world.Road{}, []*world.Road{a})
+			require.Equal(t, ErrNoPath, err)
+			_, err = search([]*world.Road{a}, []*world.Road{d},
+				WithClassWeights(classes, map[layers.RoadClass]float64{layers.MajorRoad: -1}))
+			require.Error(t, err)
+			_, err = search([]*world.Road{a}, []*world.Road{d}, WithMaxCost(100))
+			require.Equal(t, ErrNoPath, err, "paths that cost as much as the limit shouldn't be followed")
+			_, err = search([]*world.Road{a}, []*world.Road{d}, WithMaxCost(-1))
+			require.Error(t, err)
+		})
+	}
+}
+
+// Roads scattered over a square with random connections between them, some only in one direction
+func randomNetwork(random *rand.Rand, count, size int) []*world.Road {
+	roads := make([]*world.Road, count)
+	for i := range roads {
+		roads[i] = world.NewRoad(world.Id(2*i), world.NewNode(world.Id(2*i+1), random.Intn(size), random.Intn(size)))
+	}
+
+	for i := 0; i < 2*count; i++ {
+		a, b := roads[random.Intn(count)], roads[random.Intn(count)]
+		if random.Intn(4) == 0 {
+			a.InitOperation(&world.RoadInitOperation{AdditionalConnections: []*world.Road{b}})
+		} else {
+			testutil.Chain(a, b)
+		}
+	}
+
+	return roads
+}
+
+func randomClasses(random *rand.Rand, roads []*world.Road) map[world.Id]layers.RoadClass {
+	classes := make(map[world.Id]layers.RoadClass, len(roads))
+	for _, r := range roads {
+		classes[r.Id()] = layers.RoadClass(random.Intn(6))
+	}
+
+	return classes
+}
+
+func pickRoads(random *rand.Rand, roads []*world.Road, count int) []*world.Road {
+	picked := make([]*world.Road, count)
+	for i := range picked {
+		picked[i] = roads[random.Intn(len(roads))]
+	}
+
+	return picked
+}
+
+func TestAStar_MatchesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(47))
+	weights := map[layers.RoadClass]float64{layers.MajorRoad: 3, layers.PathRoad: 0.5}
+
+	for i := 0; i < 200; i++ {
+		roads := randomNetwork(random, 50, 200)
+		opts := []Option{WithClassWeights(randomClasses(random, roads), weights)}
+		sources, targets := pickRoads(random, roads, random.Intn(3)+1), pickRoads(random, roads, random.Intn(3)+1)
+
+		expected, expectedErr := Shortest(sources, targets, opts...)
+		actual, err := AStar(sources, targets, opts...)
+		require.Equal(t, expectedErr, err)
+		if err != nil {
+			continue
+		}
+
+		require.InDelta(t, expected.Cost, actual.Cost, 1e-9)
+		require.Contains(t, sources, actual.Roads[0])
+		require.Contains(t, targets, actual.Roads[len(actual.Roads)-1])
+
+		options, _ := newOptions(opts)
+		cost := 0.0
+		for j := 1; j < len(actual.Roads); j++ {
+			require.Contains(t, actual.Roads[j-1].Connections(), actual.Roads[j])
+			cost += options.cost(actual.Roads[j-1], actual.Roads[j])
+		}
+
+		require.InDelta(t, actual.Cost, cost, 1e-9)
+		require.False(t, math.IsInf(actual.Length, 0))
+	}
+}